@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+	"PE-Parser/internal/reporthtml"
+)
+
+// dirList collects repeated -dllpath flags; each value may itself be a
+// list separated by the OS path-list separator.
+type dirList []string
+
+func (d *dirList) String() string { return strings.Join(*d, string(os.PathListSeparator)) }
+
+func (d *dirList) Set(v string) error {
+	for _, p := range filepath.SplitList(v) {
+		if p != "" {
+			*d = append(*d, p)
+		}
+	}
+	return nil
+}
+
+// runDeps implements `peview deps`: resolve the import tree of a file
+// against local copies of system DLLs.
+func runDeps(args []string) {
+	fs := flag.NewFlagSet("deps", flag.ExitOnError)
+	pePath := fs.String("file", "", "Path to the PE file")
+	var dirs dirList
+	fs.Var(&dirs, "dllpath", "Directory of reference DLLs (repeatable, or a path list)")
+	depth := fs.Int("depth", 0, "Maximum import depth (0 = unlimited)")
+	apiSet := fs.String("apiset", "", "Resolve API sets with the schema from this apisetschema.dll")
+	dotPath := fs.String("dot", "", "Write the dependency graph as Graphviz DOT to this path")
+	writeHTML := fs.Bool("html", false, "Write an HTML report next to the target file instead of printing the tree")
+	fs.Parse(args)
+
+	if *pePath == "" || len(dirs) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: peview deps -file <path-to-pe-file> -dllpath <dir> [-dllpath <dir> ...] [flags]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	opts := peparse.Options{
+		MinStrLen:  4,
+		APISetPath: *apiSet,
+		DepDirs:    dirs,
+		DepDepth:   *depth,
+		Quiet:      true,
+	}
+	report, err := peparse.Parse(*pePath, opts)
+	if err != nil {
+		log.Fatalf("Parse error: %v", err)
+	}
+
+	if *dotPath != "" {
+		if err := writeGraph(*dotPath, report.Deps.WriteDOT); err != nil {
+			log.Fatalf("DOT write error: %v", err)
+		}
+	}
+	if *writeHTML {
+		if err := reporthtml.WriteHTML(htmlOutPath(*pePath), *pePath, report, reporthtml.Params{}); err != nil {
+			log.Fatalf("HTML write error: %v", err)
+		}
+		return
+	}
+	report.Deps.PrintConsole()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+// peExtensions are the file types `peview harden` picks up when walking a
+// directory.
+var peExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".sys": true, ".ocx": true, ".cpl": true, ".scr": true, ".efi": true,
+}
+
+type hardenResult struct {
+	Path   string                   `json:"path"`
+	Checks []peparse.HardeningCheck `json:"checks,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// runHarden implements `peview harden <dir>`: run the hardening checklist
+// over every PE file under dir and write one row per file.
+func runHarden(args []string) {
+	fset := flag.NewFlagSet("harden", flag.ExitOnError)
+	format := fset.String("format", "csv", "Output format: csv or json")
+	outPath := fset.String("o", "", "Write to this path instead of stdout")
+	fset.Parse(args)
+
+	if fset.NArg() != 1 || (*format != "csv" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: peview harden [-format csv|json] [-o out] <dir-or-file>")
+		fset.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var results []hardenResult
+	err := filepath.WalkDir(fset.Arg(0), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			results = append(results, hardenResult{Path: path, Error: err.Error()})
+			return nil
+		}
+		if d.IsDir() || (path != fset.Arg(0) && !peExtensions[strings.ToLower(filepath.Ext(path))]) {
+			return nil
+		}
+		res := hardenResult{Path: path}
+		h, err := peparse.Harden(path)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Checks = h.Checks
+		}
+		results = append(results, res)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Walk error: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" {
+		fh, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("Output error: %v", err)
+		}
+		defer fh.Close()
+		w = fh
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = writeHardenCSV(w, results)
+	}
+	if err != nil {
+		log.Fatalf("Output error: %v", err)
+	}
+}
+
+func writeHardenCSV(w io.Writer, results []hardenResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append(append([]string{"path"}, peparse.HardeningChecks...), "error"))
+	for _, res := range results {
+		h := peparse.HardeningReport{Checks: res.Checks}
+		row := []string{res.Path}
+		for _, name := range peparse.HardeningChecks {
+			row = append(row, h.Outcome(name))
+		}
+		cw.Write(append(row, res.Error))
+	}
+	cw.Flush()
+	return cw.Error()
+}
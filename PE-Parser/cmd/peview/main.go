@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,6 +13,23 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "deps":
+			runDeps(os.Args[2:])
+			return
+		case "harden":
+			runHarden(os.Args[2:])
+			return
+		case "map":
+			runMap(os.Args[2:])
+			return
+		case "unmap":
+			runUnmap(os.Args[2:])
+			return
+		}
+	}
+
 	pePath := flag.String("file", "", "Path to the PE file")
 
 	dumpHex := flag.Bool("dump", true, "Hex-dump section data (applies to console and HTML)")
@@ -25,27 +43,67 @@ func main() {
 	autoInstall := flag.Bool("install", false, "If rank_strings is missing, offer to install StringSifter")
 	assumeYes := flag.Bool("y", false, "Assume yes to install prompt (non-interactive)")
 
+	iocs := flag.Bool("iocs", true, "Extract indicators (URLs, IPs, domains, registry keys, ...) from strings and resources")
+
+	decode := flag.Bool("decode", true, "Detect and decode Base64/Base32/hex/URL-encoded and XOR/ADD-obfuscated strings")
+
+	stackStrings := flag.Bool("stackstrings", true, "Recover strings built on the stack by immediate mov sequences in code sections")
+
+	disasmCount := flag.Int("disasm", 32, "Instructions to disassemble at the entry point, TLS callbacks and exports (0 = off)")
+
+	ordinalDir := flag.String("ordinals", "", "Directory of DLLs or .def files whose exports extend the built-in ordinal-to-name maps")
+
+	apiSet := flag.String("apiset", "", "Resolve api-ms-win-*/ext-ms-* imports with the schema from this apisetschema.dll instead of the built-in table")
+
+	hijack := flag.Bool("hijack", true, "Report imported DLLs that would be loaded from the application directory")
+	knownDLLs := flag.String("knowndlls", "", "File with one KnownDLLs entry per line, replacing the built-in list")
+	refDLL := flag.String("refdll", "", "Legitimate DLL (or directory of DLLs) to compare the exports of a DLL input against")
+	symbols := flag.String("symbols", "", "Local symbol directory (symstore layout or flat) to load the matching PDB from")
+
+	functions := flag.Bool("functions", true, "Discover functions by recursive descent and map the imported APIs and strings each one uses")
+	graphDOT := flag.String("graphdot", "", "Write the call graph as Graphviz DOT to this path")
+	graphJSON := flag.String("graphjson", "", "Write the functions and call graph as JSON to this path")
+	relocJSON := flag.String("relocjson", "", "Write the full base relocation listing as JSON to this path")
+	dotnetJSON := flag.String("dotnetjson", "", "Write the .NET metadata (references, types, methods, member refs, resources) as JSON to this path")
+	pdataJSON := flag.String("pdatajson", "", "Write the exception directory function table with unwind info as JSON to this path")
+
 	writeHTML := flag.Bool("html", true, "Write an HTML report next to the target file and suppress console output")
 
 	flag.Parse()
 
 	if *pePath == "" {
 		fmt.Fprintln(os.Stderr, "Usage: peview -file <path-to-pe-file> [flags]")
+		fmt.Fprintln(os.Stderr, "       peview deps -file <path-to-pe-file> -dllpath <dir> [flags]")
+		fmt.Fprintln(os.Stderr, "       peview harden [-format csv|json] [-o out] <dir-or-file>")
+		fmt.Fprintln(os.Stderr, "       peview map -file <path-to-pe-file> [-base 0x...] [-o out]")
+		fmt.Fprintln(os.Stderr, "       peview unmap -file <dump> [-rebase] [-base 0x...] [-loadbase 0x...] [-o out]")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
 	opts := peparse.Options{
-		DumpHex:     *dumpHex,
-		MaxDump:     *maxDump,
-		ShowStrings: *showStrings,
-		MinStrLen:   *minStrLen,
-		UseSifter:   *useSifter,
-		RankLimit:   *rankLimit,
-		RankMin:     *rankMin,
-		AutoInstall: *autoInstall,
-		AssumeYes:   *assumeYes,
-		Quiet:       *writeHTML,
+		DumpHex:      *dumpHex,
+		MaxDump:      *maxDump,
+		ShowStrings:  *showStrings,
+		MinStrLen:    *minStrLen,
+		UseSifter:    *useSifter,
+		RankLimit:    *rankLimit,
+		RankMin:      *rankMin,
+		AutoInstall:  *autoInstall,
+		AssumeYes:    *assumeYes,
+		Indicators:   *iocs,
+		Decode:       *decode,
+		StackStrings: *stackStrings,
+		DisasmCount:  *disasmCount,
+		OrdinalDir:   *ordinalDir,
+		APISetPath:   *apiSet,
+
+		Hijack:        *hijack,
+		KnownDLLsPath: *knownDLLs,
+		RefDLLPath:    *refDLL,
+		SymbolDir:     *symbols,
+		Functions:     *functions || *graphDOT != "" || *graphJSON != "",
+		Quiet:         *writeHTML,
 	}
 
 	report, err := peparse.Parse(*pePath, opts)
@@ -53,6 +111,32 @@ func main() {
 		log.Fatalf("Parse error: %v", err)
 	}
 
+	if *graphDOT != "" {
+		if err := writeGraph(*graphDOT, report.Functions.WriteDOT); err != nil {
+			log.Fatalf("DOT write error: %v", err)
+		}
+	}
+	if *graphJSON != "" {
+		if err := writeGraph(*graphJSON, report.Functions.WriteJSON); err != nil {
+			log.Fatalf("JSON write error: %v", err)
+		}
+	}
+	if *relocJSON != "" {
+		if err := writeGraph(*relocJSON, report.Relocs.WriteJSON); err != nil {
+			log.Fatalf("JSON write error: %v", err)
+		}
+	}
+	if *pdataJSON != "" {
+		if err := writeGraph(*pdataJSON, report.Exception.WriteJSON); err != nil {
+			log.Fatalf("JSON write error: %v", err)
+		}
+	}
+	if *dotnetJSON != "" {
+		if err := writeGraph(*dotnetJSON, report.DotNet.WriteJSON); err != nil {
+			log.Fatalf("JSON write error: %v", err)
+		}
+	}
+
 	if *writeHTML {
 		out := htmlOutPath(*pePath)
 		if err := reporthtml.WriteHTML(out, *pePath, report, reporthtml.Params{
@@ -67,6 +151,18 @@ func main() {
 	report.PrintConsole()
 }
 
+func writeGraph(path string, write func(io.Writer) error) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
 func htmlOutPath(target string) string {
 	abs, err := filepath.Abs(target)
 	if err != nil {
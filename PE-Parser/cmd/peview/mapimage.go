@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"PE-Parser/internal/peparse"
+)
+
+// runMap implements `peview map`: write the image as the loader would lay
+// it out in memory, optionally rebased, for diffing against memory dumps.
+func runMap(args []string) {
+	fs := flag.NewFlagSet("map", flag.ExitOnError)
+	pePath := fs.String("file", "", "Path to the PE file")
+	outPath := fs.String("o", "", "Output path (default <file>.mapped)")
+	base := fs.String("base", "", "Apply base relocations for this load address (hex, e.g. 0x7FF600000000)")
+	fs.Parse(args)
+
+	if *pePath == "" {
+		fmt.Fprintln(os.Stderr, "Usage: peview map -file <path-to-pe-file> [-base 0x...] [-o out]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var opts peparse.MapOptions
+	if *base != "" {
+		v, err := strconv.ParseUint(*base, 0, 64)
+		if err != nil {
+			log.Fatalf("Bad -base %q: %v", *base, err)
+		}
+		opts.Base, opts.Rebase = v, true
+	}
+	m, err := peparse.MapImage(*pePath, opts)
+	if err != nil {
+		log.Fatalf("Map error: %v", err)
+	}
+	out := *outPath
+	if out == "" {
+		out = *pePath + ".mapped"
+	}
+	if err := os.WriteFile(out, m.Data, 0o644); err != nil {
+		log.Fatalf("Write error: %v", err)
+	}
+	fmt.Printf("Mapped %d bytes at ImageBase 0x%X to %s\n", len(m.Data), m.ImageBase, out)
+	if opts.Rebase {
+		fmt.Printf("Applied %d relocations\n", m.Applied)
+	}
+	for _, n := range m.Notes {
+		fmt.Println("Note:", n)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"PE-Parser/internal/peparse"
+)
+
+// runUnmap implements `peview unmap`: realign a dump of a loaded module to
+// file layout, optionally undoing the loader's relocations.
+func runUnmap(args []string) {
+	fs := flag.NewFlagSet("unmap", flag.ExitOnError)
+	pePath := fs.String("file", "", "Path to the memory dump")
+	outPath := fs.String("o", "", "Output path (default <file>.unmapped)")
+	rebase := fs.Bool("rebase", false, "Restore ImageBase by undoing the relocations applied at load time")
+	base := fs.String("base", "", "Original ImageBase to restore (hex; implies -rebase, default is the ImageBase in the dumped headers)")
+	loadBase := fs.String("loadbase", "", "Address the module was dumped from (hex; default is inferred from relocations)")
+	force := fs.Bool("force", false, "Unmap even if the input does not look like a mapped image")
+	fs.Parse(args)
+
+	if *pePath == "" {
+		fmt.Fprintln(os.Stderr, "Usage: peview unmap -file <dump> [-rebase] [-base 0x...] [-loadbase 0x...] [-o out]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	opts := peparse.UnmapOptions{Rebase: *rebase, Force: *force}
+	if *base != "" {
+		v, err := strconv.ParseUint(*base, 0, 64)
+		if err != nil {
+			log.Fatalf("Bad -base %q: %v", *base, err)
+		}
+		opts.Base, opts.Rebase = v, true
+	}
+	if *loadBase != "" {
+		v, err := strconv.ParseUint(*loadBase, 0, 64)
+		if err != nil {
+			log.Fatalf("Bad -loadbase %q: %v", *loadBase, err)
+		}
+		opts.LoadBase, opts.Rebase = v, true
+	}
+	u, err := peparse.Unmap(*pePath, opts)
+	if err != nil {
+		log.Fatalf("Unmap error: %v", err)
+	}
+	out := *outPath
+	if out == "" {
+		out = *pePath + ".unmapped"
+	}
+	if err := os.WriteFile(out, u.Data, 0o644); err != nil {
+		log.Fatalf("Write error: %v", err)
+	}
+	for _, e := range u.Layout.Evidence {
+		fmt.Println("Mapped layout:", e)
+	}
+	fmt.Printf("Wrote %d bytes with ImageBase 0x%X to %s\n", len(u.Data), u.ImageBase, out)
+	if opts.Rebase && u.LoadBase != 0 {
+		fmt.Printf("Load base 0x%X, %d relocations undone\n", u.LoadBase, u.Applied)
+	}
+	for _, n := range u.Notes {
+		fmt.Println("Note:", n)
+	}
+}
@@ -0,0 +1,1404 @@
+// Package disasm is a small x86/x64 instruction decoder. It aims for exact
+// instruction lengths across the whole opcode space (legacy, SSE, VEX, EVEX
+// and x87) and Intel-syntax text for the instructions that show up in
+// compiler output; rarely seen encodings still decode with a generic name.
+package disasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrTruncated = errors.New("truncated instruction")
+	ErrInvalid   = errors.New("invalid instruction")
+)
+
+// Flow classifies how an instruction transfers control.
+type Flow int
+
+const (
+	FlowNone Flow = iota
+	FlowJump
+	FlowCondJump
+	FlowCall
+	FlowRet
+	FlowHalt
+)
+
+type Inst struct {
+	Addr     uint64
+	Len      int
+	Bytes    []byte
+	Mnemonic string
+	Operands []string
+
+	Flow     Flow
+	Indirect bool // call/jmp through a register or memory operand
+
+	// Target is the destination of a relative branch or call.
+	Target    uint64
+	HasTarget bool
+
+	// Mem is the absolute address of a RIP-relative, moffs or base-less
+	// disp32 memory operand. RIPRel tells the first kind apart.
+	Mem    uint64
+	HasMem bool
+	RIPRel bool
+
+	// Imm is the first immediate operand as encoded after sign extension.
+	Imm    uint64
+	HasImm bool
+}
+
+func (i Inst) String() string {
+	if len(i.Operands) == 0 {
+		return i.Mnemonic
+	}
+	return i.Mnemonic + " " + strings.Join(i.Operands, ", ")
+}
+
+var (
+	reg8Legacy = [8]string{"al", "cl", "dl", "bl", "ah", "ch", "dh", "bh"}
+	reg8       = [16]string{"al", "cl", "dl", "bl", "spl", "bpl", "sil", "dil", "r8b", "r9b", "r10b", "r11b", "r12b", "r13b", "r14b", "r15b"}
+	reg16      = [16]string{"ax", "cx", "dx", "bx", "sp", "bp", "si", "di", "r8w", "r9w", "r10w", "r11w", "r12w", "r13w", "r14w", "r15w"}
+	reg32      = [16]string{"eax", "ecx", "edx", "ebx", "esp", "ebp", "esi", "edi", "r8d", "r9d", "r10d", "r11d", "r12d", "r13d", "r14d", "r15d"}
+	reg64      = [16]string{"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi", "r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15"}
+	segRegs    = [8]string{"es", "cs", "ss", "ds", "fs", "gs", "?", "?"}
+	addr16     = [8]string{"bx+si", "bx+di", "bp+si", "bp+di", "si", "di", "bp", "bx"}
+)
+
+// Two-byte opcodes that carry an imm8 even when no table entry names them.
+var twoByteImm8 = map[byte]bool{0x70: true, 0x71: true, 0x72: true, 0x73: true, 0xA4: true, 0xAC: true, 0xBA: true, 0xC2: true, 0xC4: true, 0xC5: true, 0xC6: true}
+
+// VEX forms of these 0F opcodes take no VEX.vvvv source operand.
+var vexTwoOperand = map[byte]bool{
+	0x10: true, 0x11: true, 0x13: true, 0x17: true, 0x28: true, 0x29: true, 0x2B: true, 0x2C: true, 0x2D: true, 0x2E: true, 0x2F: true,
+	0x50: true, 0x51: true, 0x52: true, 0x53: true, 0x5A: true, 0x5B: true, 0x6E: true, 0x6F: true, 0x70: true, 0x7E: true, 0x7F: true,
+	0xD6: true, 0xD7: true, 0xE6: true, 0xE7: true, 0xF0: true, 0xF7: true,
+}
+
+type decoder struct {
+	code []byte
+	pos  int
+	bits int
+	op   byte
+
+	opsize16 bool
+	addrOvr  bool
+	seg      string
+	lock     bool
+	rep      byte // 0xF3 or 0xF2 when present
+	mand     byte // first of 66/F2/F3 that can act as an SSE mandatory prefix
+
+	rex                    byte
+	rexW, rexR, rexX, rexB bool
+
+	vex       bool
+	evex      bool
+	vexL      int
+	vexV      byte
+	rHi       bool // EVEX.R'
+	dispScale int64
+
+	hasModrm     bool
+	mod, reg, rm byte
+	isMem        bool
+	memText      string
+	ripRel       bool
+	absDisp      bool
+	disp         int64
+
+	inst Inst
+}
+
+// Decode decodes one instruction from code, which starts at virtual address
+// addr. bits is 32 or 64.
+func Decode(code []byte, addr uint64, bits int) (Inst, error) {
+	d := &decoder{code: code, bits: bits, dispScale: 1}
+	d.inst.Addr = addr
+	if err := d.decode(); err != nil {
+		return Inst{Addr: addr}, err
+	}
+	d.inst.Len = d.pos
+	d.inst.Bytes = code[:d.pos]
+	end := addr + uint64(d.pos)
+	switch {
+	case d.ripRel:
+		d.inst.Mem = end + uint64(d.disp)
+		if bits == 32 || d.addrOvr {
+			d.inst.Mem &= 0xFFFFFFFF
+		}
+		d.inst.HasMem = true
+		d.inst.RIPRel = true
+		rip := "rip"
+		if d.addrOvr {
+			rip = "eip"
+		}
+		for i, o := range d.inst.Operands {
+			d.inst.Operands[i] = strings.Replace(o, "$rip", rip+signedHexNZ(d.disp), 1)
+		}
+	case d.absDisp:
+		d.inst.Mem = uint64(uint32(d.disp))
+		d.inst.HasMem = true
+	}
+	return d.inst, nil
+}
+
+func (d *decoder) next() (byte, error) {
+	if d.pos >= len(d.code) || d.pos >= 15 {
+		return 0, ErrTruncated
+	}
+	b := d.code[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if d.pos+n > len(d.code) || d.pos+n > 15 {
+		return nil, ErrTruncated
+	}
+	b := d.code[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.pos >= len(d.code) {
+		return 0, ErrTruncated
+	}
+	return d.code[d.pos], nil
+}
+
+func isLegacyPrefix(b byte) bool {
+	switch b {
+	case 0xF0, 0xF2, 0xF3, 0x66, 0x67, 0x2E, 0x36, 0x3E, 0x26, 0x64, 0x65:
+		return true
+	}
+	return false
+}
+
+func (d *decoder) decode() error {
+	for {
+		b, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case 0xF0:
+			d.lock = true
+		case 0xF2, 0xF3:
+			d.rep = b
+			d.mand = b
+		case 0x66:
+			d.opsize16 = true
+			if d.mand == 0 {
+				d.mand = b
+			}
+		case 0x67:
+			d.addrOvr = true
+		case 0x26, 0x2E, 0x36, 0x3E:
+			d.seg = segRegs[(b>>3)&3]
+		case 0x64:
+			d.seg = "fs"
+		case 0x65:
+			d.seg = "gs"
+		default:
+			if d.bits == 64 && b&0xF0 == 0x40 {
+				nb, err := d.peek()
+				if err != nil {
+					return err
+				}
+				if isLegacyPrefix(nb) {
+					// REX only counts when it directly precedes the opcode.
+					continue
+				}
+				d.rex = b
+				d.rexW = b&8 != 0
+				d.rexR = b&4 != 0
+				d.rexX = b&2 != 0
+				d.rexB = b&1 != 0
+				continue
+			}
+			d.op = b
+			return d.oneByte(b)
+		}
+	}
+}
+
+func (d *decoder) mandIndex() int {
+	switch d.mand {
+	case 0x66:
+		return 1
+	case 0xF3:
+		return 2
+	case 0xF2:
+		return 3
+	}
+	return 0
+}
+
+func (d *decoder) oneByte(b byte) error {
+	if (b == 0xC4 || b == 0xC5 || b == 0x62) && d.rex == 0 {
+		nb, err := d.peek()
+		if err != nil {
+			return err
+		}
+		// In 32-bit mode these are LES/LDS/BOUND unless ModRM.mod is 11.
+		if d.bits == 64 || nb&0xC0 == 0xC0 {
+			if b == 0x62 {
+				return d.evexInst()
+			}
+			return d.vexInst(b)
+		}
+	}
+	if b == 0x0F {
+		return d.twoByte()
+	}
+	if b >= 0xD8 && b <= 0xDF {
+		return d.x87(b)
+	}
+	sp := oneByteSpecs[b]
+	if sp.mnemonic == "" {
+		return ErrInvalid
+	}
+	if d.bits == 64 {
+		if b == 0x63 {
+			sp = opSpec{mnemonic: "movsxd", ops: []string{"Gv", "Ed"}}
+		}
+		if sp.i64 {
+			return ErrInvalid
+		}
+	}
+	sp.mnemonic = d.sizedMnemonic(b, sp.mnemonic)
+	switch {
+	case b == 0x90 && d.rexB:
+		sp = opSpec{mnemonic: "xchg", ops: []string{"Zv", "rAX"}}
+	case b == 0x90 && d.rep == 0xF3:
+		sp.mnemonic = "pause"
+	case b >= 0x6C && b <= 0x6F, b >= 0xA4 && b <= 0xAF && b != 0xA8 && b != 0xA9:
+		switch {
+		case d.rep == 0xF3 && (b == 0xA6 || b == 0xA7 || b == 0xAE || b == 0xAF):
+			sp.mnemonic = "repe " + sp.mnemonic
+		case d.rep == 0xF3:
+			sp.mnemonic = "rep " + sp.mnemonic
+		case d.rep == 0xF2:
+			sp.mnemonic = "repne " + sp.mnemonic
+		}
+	}
+	if b == 0xC6 || b == 0xC7 {
+		if nb, err := d.peek(); err == nil && nb == 0xF8 {
+			d.pos++
+			if b == 0xC6 {
+				return d.apply(opSpec{mnemonic: "xabort", ops: []string{"Ib"}})
+			}
+			return d.apply(opSpec{mnemonic: "xbegin", ops: []string{"Jz"}})
+		}
+	}
+	if err := d.apply(sp); err != nil {
+		return err
+	}
+	d.setFlow(b)
+	return nil
+}
+
+// sizedMnemonic picks the operand-size specific name for string and
+// conversion instructions.
+func (d *decoder) sizedMnemonic(op byte, mn string) string {
+	pick := func(w, dw, q string) string {
+		switch d.opSize(false) {
+		case 16:
+			return w
+		case 64:
+			return q
+		}
+		return dw
+	}
+	switch op {
+	case 0x98:
+		return pick("cbw", "cwde", "cdqe")
+	case 0x99:
+		return pick("cwd", "cdq", "cqo")
+	case 0xA5:
+		return pick("movsw", "movsd", "movsq")
+	case 0xA7:
+		return pick("cmpsw", "cmpsd", "cmpsq")
+	case 0xAB:
+		return pick("stosw", "stosd", "stosq")
+	case 0xAD:
+		return pick("lodsw", "lodsd", "lodsq")
+	case 0xAF:
+		return pick("scasw", "scasd", "scasq")
+	case 0x6D:
+		return pick("insw", "insd", "insd")
+	case 0x6F:
+		return pick("outsw", "outsd", "outsd")
+	case 0xCF:
+		return pick("iret", "iretd", "iretq")
+	case 0x60:
+		return pick("pusha", "pushad", "pushad")
+	case 0x61:
+		return pick("popa", "popad", "popad")
+	case 0x9C, 0x9D:
+		switch {
+		case d.opsize16:
+			return mn[:len(mn)-1]
+		case d.bits == 64:
+			return mn[:len(mn)-1] + "q"
+		}
+	case 0xE3:
+		if d.bits == 64 && !d.addrOvr {
+			return "jrcxz"
+		}
+	}
+	return mn
+}
+
+func (d *decoder) opSize(d64 bool) int {
+	switch {
+	case d.bits == 64 && d.rexW:
+		return 64
+	case d.opsize16:
+		return 16
+	case d.bits == 64 && d64:
+		return 64
+	}
+	return 32
+}
+
+func (d *decoder) addrSize() int {
+	if d.bits == 64 {
+		if d.addrOvr {
+			return 32
+		}
+		return 64
+	}
+	if d.addrOvr {
+		return 16
+	}
+	return 32
+}
+
+func needsModrm(ops []string) bool {
+	for _, o := range ops {
+		switch o[0] {
+		case 'E', 'G', 'M', 'S', 'C', 'D', 'R', 'V', 'W', 'U', 'P', 'Q', 'N':
+			return true
+		}
+	}
+	return false
+}
+
+// apply reads ModRM when the operands need it, resolves group opcodes and
+// formats the operands.
+func (d *decoder) apply(sp opSpec) error {
+	mn, ops := sp.mnemonic, sp.ops
+	d64 := sp.d64 || sp.f64
+	if needsModrm(ops) || strings.HasPrefix(mn, "grp") {
+		if err := d.modrm(); err != nil {
+			return err
+		}
+	}
+	if strings.HasPrefix(mn, "grp") {
+		name := groups[mn][d.reg]
+		if name == "" {
+			return ErrInvalid
+		}
+		switch mn {
+		case "grp3":
+			if d.reg <= 1 {
+				if d.op == 0xF6 {
+					ops = []string{"Eb", "Ib"}
+				} else {
+					ops = []string{"Ev", "Iz"}
+				}
+			}
+		case "grp5":
+			switch d.reg {
+			case 2, 4, 6:
+				d64 = true
+			case 3, 5:
+				if !d.isMem {
+					return ErrInvalid
+				}
+				name = strings.TrimSuffix(name, " far")
+				ops = []string{"Mp"}
+			}
+		}
+		mn = name
+	}
+	if d.lock {
+		mn = "lock " + mn
+	}
+	d.inst.Mnemonic = mn
+	for _, o := range ops {
+		s, err := d.operand(o, d64)
+		if err != nil {
+			return err
+		}
+		d.inst.Operands = append(d.inst.Operands, s)
+	}
+	return nil
+}
+
+func (d *decoder) modrm() error {
+	if d.hasModrm {
+		return nil
+	}
+	m, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.hasModrm = true
+	d.mod, d.reg, d.rm = m>>6, (m>>3)&7, m&7
+	if d.mod == 3 {
+		return nil
+	}
+	d.isMem = true
+	if d.addrSize() == 16 {
+		return d.modrm16()
+	}
+	return d.modrm32()
+}
+
+func (d *decoder) dispBytes(n int) error {
+	b, err := d.take(n)
+	if err != nil {
+		return err
+	}
+	switch n {
+	case 1:
+		d.disp = int64(int8(b[0])) * d.dispScale
+	case 2:
+		d.disp = int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		d.disp = int64(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return nil
+}
+
+func (d *decoder) modrm16() error {
+	if d.mod == 0 && d.rm == 6 {
+		if err := d.dispBytes(2); err != nil {
+			return err
+		}
+		d.memText = fmt.Sprintf("0x%x", uint16(d.disp))
+		return nil
+	}
+	switch d.mod {
+	case 1:
+		if err := d.dispBytes(1); err != nil {
+			return err
+		}
+	case 2:
+		if err := d.dispBytes(2); err != nil {
+			return err
+		}
+	}
+	d.memText = addr16[d.rm] + signedHexNZ(d.disp)
+	return nil
+}
+
+func (d *decoder) modrm32() error {
+	regs := reg64
+	if d.addrSize() == 32 {
+		regs = reg32
+	}
+	flat := d.seg == "" || d.seg == "ds" || d.seg == "cs"
+	var base, index string
+	scale := 1
+	noBase := false
+	switch {
+	case d.rm == 4:
+		sib, err := d.next()
+		if err != nil {
+			return err
+		}
+		ss, idx, bs := sib>>6, (sib>>3)&7, sib&7
+		scale = 1 << ss
+		if d.rexX {
+			idx += 8
+		}
+		if idx != 4 {
+			index = regs[idx]
+		}
+		if bs == 5 && d.mod == 0 {
+			noBase = true
+		} else {
+			if d.rexB {
+				bs += 8
+			}
+			base = regs[bs]
+		}
+	case d.rm == 5 && d.mod == 0:
+		if err := d.dispBytes(4); err != nil {
+			return err
+		}
+		if d.bits == 64 {
+			d.ripRel = true
+			d.memText = "$rip"
+		} else {
+			d.absDisp = flat
+			d.memText = fmt.Sprintf("0x%x", uint32(d.disp))
+		}
+		return nil
+	default:
+		r := d.rm
+		if d.rexB {
+			r += 8
+		}
+		base = regs[r]
+	}
+
+	var err error
+	switch {
+	case noBase, d.mod == 2:
+		err = d.dispBytes(4)
+	case d.mod == 1:
+		err = d.dispBytes(1)
+	}
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base)
+	if index != "" {
+		if sb.Len() > 0 {
+			sb.WriteByte('+')
+		}
+		sb.WriteString(index)
+		if scale > 1 {
+			fmt.Fprintf(&sb, "*%d", scale)
+		}
+	}
+	if noBase && index == "" {
+		d.absDisp = flat
+		fmt.Fprintf(&sb, "0x%x", uint32(d.disp))
+	} else {
+		sb.WriteString(signedHexNZ(d.disp))
+	}
+	d.memText = sb.String()
+	return nil
+}
+
+func signedHexNZ(v int64) string {
+	switch {
+	case v == 0:
+		return ""
+	case v < 0:
+		return fmt.Sprintf("-0x%x", -v)
+	}
+	return fmt.Sprintf("+0x%x", v)
+}
+
+func sizeKeyword(bits int) string {
+	switch bits {
+	case 8:
+		return "byte"
+	case 16:
+		return "word"
+	case 32:
+		return "dword"
+	case 64:
+		return "qword"
+	case 80:
+		return "tbyte"
+	case 128:
+		return "xmmword"
+	case 256:
+		return "ymmword"
+	case 512:
+		return "zmmword"
+	}
+	return ""
+}
+
+func (d *decoder) mem(bits int) string {
+	s := "[" + d.memText + "]"
+	if d.seg != "" {
+		s = d.seg + ":" + s
+	}
+	if kw := sizeKeyword(bits); kw != "" {
+		s = kw + " ptr " + s
+	}
+	return s
+}
+
+func gpr(bits int, idx byte, hasRex bool) string {
+	switch bits {
+	case 8:
+		if !hasRex && idx < 8 {
+			return reg8Legacy[idx]
+		}
+		return reg8[idx&15]
+	case 16:
+		return reg16[idx&15]
+	case 64:
+		return reg64[idx&15]
+	}
+	return reg32[idx&15]
+}
+
+func (d *decoder) regIdx() byte {
+	r := d.reg
+	if d.rexR {
+		r += 8
+	}
+	if d.rHi {
+		r += 16
+	}
+	return r
+}
+
+func (d *decoder) rmIdx() byte {
+	r := d.rm
+	if d.rexB {
+		r += 8
+	}
+	return r
+}
+
+func (d *decoder) vecReg(idx byte) string {
+	switch d.vexL {
+	case 1:
+		return fmt.Sprintf("ymm%d", idx)
+	case 2:
+		return fmt.Sprintf("zmm%d", idx)
+	}
+	return fmt.Sprintf("xmm%d", idx)
+}
+
+func (d *decoder) vecBits() int {
+	return 128 << uint(d.vexL)
+}
+
+func (d *decoder) imm(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func maskTo(v uint64, bits int) uint64 {
+	if bits >= 64 {
+		return v
+	}
+	return v & (1<<uint(bits) - 1)
+}
+
+func (d *decoder) sizeY() int {
+	if d.bits == 64 && d.rexW {
+		return 64
+	}
+	return 32
+}
+
+func (d *decoder) operand(o string, d64 bool) (string, error) {
+	osz := d.opSize(d64)
+	hasRex := d.rex != 0
+	switch o {
+	case "Eb", "Ew", "Ed", "Ev", "Ey":
+		bits := osz
+		switch o {
+		case "Eb":
+			bits = 8
+		case "Ew":
+			bits = 16
+		case "Ed":
+			bits = 32
+		case "Ey":
+			bits = d.sizeY()
+		}
+		if d.isMem {
+			return d.mem(bits), nil
+		}
+		return gpr(bits, d.rmIdx(), hasRex), nil
+	case "Gb", "Gw", "Gd", "Gv", "Gy", "Gz":
+		bits := osz
+		switch o {
+		case "Gb":
+			bits = 8
+		case "Gw":
+			bits = 16
+		case "Gd":
+			bits = 32
+		case "Gy":
+			bits = d.sizeY()
+		}
+		return gpr(bits, d.regIdx()&15, hasRex), nil
+	case "By":
+		return gpr(d.sizeY(), d.vexV&15, true), nil
+	case "M", "Mp":
+		if !d.isMem {
+			return "", ErrInvalid
+		}
+		return d.mem(0), nil
+	case "Rd", "Rv", "Ry":
+		bits := 32
+		switch {
+		case o == "Rv":
+			bits = osz
+		case o == "Ry":
+			bits = d.sizeY()
+		case d.bits == 64:
+			bits = 64
+		}
+		return gpr(bits, d.rmIdx(), hasRex), nil
+	case "Cd":
+		return fmt.Sprintf("cr%d", d.regIdx()), nil
+	case "Dd":
+		return fmt.Sprintf("dr%d", d.regIdx()), nil
+	case "Sw":
+		return segRegs[d.reg], nil
+	case "Ib", "IbS", "Iw", "Iz", "Iv":
+		n := 1
+		switch o {
+		case "Iw":
+			n = 2
+		case "Iz":
+			n = 4
+			if osz == 16 {
+				n = 2
+			}
+		case "Iv":
+			n = osz / 8
+		}
+		v, err := d.imm(n)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case o == "IbS":
+			v = maskTo(uint64(int64(int8(v))), osz)
+		case o == "Iz" && osz == 64:
+			v = uint64(int64(int32(v)))
+		}
+		if !d.inst.HasImm {
+			d.inst.Imm, d.inst.HasImm = v, true
+		}
+		return fmt.Sprintf("0x%x", v), nil
+	case "Jb", "Jz":
+		n := 1
+		if o == "Jz" {
+			n = 4
+			if d.opsize16 && d.bits == 32 {
+				n = 2
+			}
+		}
+		v, err := d.imm(n)
+		if err != nil {
+			return "", err
+		}
+		rel := int64(int8(v))
+		switch n {
+		case 2:
+			rel = int64(int16(v))
+		case 4:
+			rel = int64(int32(v))
+		}
+		// The relative field is always the last part of a branch, so the
+		// next instruction address is already known here.
+		t := d.inst.Addr + uint64(d.pos) + uint64(rel)
+		if d.bits == 32 {
+			t &= 0xFFFFFFFF
+		}
+		d.inst.Target, d.inst.HasTarget = t, true
+		return fmt.Sprintf("0x%x", t), nil
+	case "Ob", "Ov":
+		v, err := d.imm(d.addrSize() / 8)
+		if err != nil {
+			return "", err
+		}
+		d.inst.Mem, d.inst.HasMem = v, d.seg == "" || d.seg == "ds"
+		bits := osz
+		if o == "Ob" {
+			bits = 8
+		}
+		s := fmt.Sprintf("[0x%x]", v)
+		if d.seg != "" {
+			s = d.seg + ":" + s
+		}
+		return sizeKeyword(bits) + " ptr " + s, nil
+	case "Ap":
+		n := 4
+		if d.opsize16 {
+			n = 2
+		}
+		off, err := d.imm(n)
+		if err != nil {
+			return "", err
+		}
+		sel, err := d.imm(2)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("0x%x:0x%x", sel, off), nil
+	case "AL":
+		return "al", nil
+	case "CL":
+		return "cl", nil
+	case "DX":
+		return "dx", nil
+	case "1":
+		return "1", nil
+	case "rAX":
+		return gpr(osz, 0, hasRex), nil
+	case "eAX":
+		if d.opsize16 {
+			return "ax", nil
+		}
+		return "eax", nil
+	case "ES", "CS", "SS", "DS", "FS", "GS":
+		return strings.ToLower(o), nil
+	case "Zb", "Zv", "Zy":
+		r := d.op & 7
+		if d.rexB {
+			r += 8
+		}
+		bits := osz
+		switch o {
+		case "Zb":
+			bits = 8
+		case "Zy":
+			bits = d.sizeY()
+		}
+		return gpr(bits, r, hasRex), nil
+	case "Vx":
+		return d.vecReg(d.regIdx()), nil
+	case "Hx":
+		return d.vecReg(d.vexV), nil
+	case "Wx", "Ux":
+		if d.isMem {
+			if o == "Ux" {
+				return "", ErrInvalid
+			}
+			return d.mem(d.vecBits()), nil
+		}
+		r := d.rmIdx()
+		if d.evex && d.rexX {
+			r += 16
+		}
+		return d.vecReg(r), nil
+	case "Pq":
+		return fmt.Sprintf("mm%d", d.reg), nil
+	case "Qq", "Nq":
+		if d.isMem {
+			if o == "Nq" {
+				return "", ErrInvalid
+			}
+			return d.mem(64), nil
+		}
+		return fmt.Sprintf("mm%d", d.rm), nil
+	}
+	return "", fmt.Errorf("disasm: unknown operand kind %q", o)
+}
+
+func (d *decoder) setFlow(op byte) {
+	switch {
+	case op >= 0x70 && op <= 0x7F, op >= 0xE0 && op <= 0xE3:
+		d.inst.Flow = FlowCondJump
+	case op == 0xE9 || op == 0xEB || op == 0xEA:
+		d.inst.Flow = FlowJump
+	case op == 0xE8 || op == 0x9A:
+		d.inst.Flow = FlowCall
+	case op == 0xC2 || op == 0xC3 || op == 0xCA || op == 0xCB || op == 0xCF:
+		d.inst.Flow = FlowRet
+	case op == 0xF4 || op == 0xCC:
+		d.inst.Flow = FlowHalt
+	case op == 0xFF && d.reg >= 2 && d.reg <= 5:
+		d.inst.Indirect = true
+		d.inst.Flow = FlowJump
+		if d.reg <= 3 {
+			d.inst.Flow = FlowCall
+		}
+	}
+}
+
+func (d *decoder) twoByte() error {
+	b, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.op = b
+	switch b {
+	case 0x38, 0x3A:
+		return d.threeByte(b)
+	case 0x0F:
+		// 3DNow!: ModRM, then an imm8 that selects the operation.
+		if err := d.apply(opSpec{mnemonic: "3dnow", ops: []string{"Pq", "Qq"}}); err != nil {
+			return err
+		}
+		s, err := d.next()
+		if err != nil {
+			return err
+		}
+		d.inst.Mnemonic = fmt.Sprintf("3dnow.%02x", s)
+		return nil
+	case 0x01:
+		return d.grp7()
+	case 0xAE:
+		return d.grp15()
+	case 0xC7:
+		return d.grp9()
+	case 0x1E:
+		if d.rep == 0xF3 {
+			if nb, err := d.peek(); err == nil && (nb == 0xFA || nb == 0xFB) {
+				d.pos++
+				d.inst.Mnemonic = map[byte]string{0xFA: "endbr64", 0xFB: "endbr32"}[nb]
+				return nil
+			}
+		}
+	case 0x20, 0x21, 0x22, 0x23:
+		// Control/debug register moves ignore ModRM.mod.
+		m, err := d.next()
+		if err != nil {
+			return err
+		}
+		d.hasModrm = true
+		d.mod, d.reg, d.rm = 3, (m>>3)&7, m&7
+	}
+
+	sp := twoByteSpecs[b]
+	if specs, ok := sseSpecs[b]; ok {
+		if s := specs[d.mandIndex()]; s.mnemonic != "" {
+			sp = s
+			if d.mand == 0x66 {
+				d.opsize16 = false
+			}
+		} else if specs[0].mnemonic != "" {
+			sp = specs[0]
+		}
+	}
+	if sp.mnemonic == "" {
+		return d.unknownTwoByte(b)
+	}
+	if (b == 0x6E || b == 0x7E) && d.rexW && sp.mnemonic == "movd" {
+		sp.mnemonic = "movq"
+	}
+	if err := d.apply(sp); err != nil {
+		return err
+	}
+	switch {
+	case b >= 0x80 && b <= 0x8F:
+		d.inst.Flow = FlowCondJump
+	case b == 0x0B || b == 0xB9 || b == 0xFF:
+		d.inst.Flow = FlowHalt
+	}
+	return nil
+}
+
+// unknownTwoByte keeps the instruction length right for encodings that have
+// no name in the tables.
+func (d *decoder) unknownTwoByte(b byte) error {
+	modrm := b <= 0x03 || b == 0x0D || b >= 0x10 && b <= 0x17 || b >= 0x28 && b <= 0x2F ||
+		b >= 0x40 && b <= 0x76 || b >= 0x78 && b <= 0x7F || b >= 0x90 && b <= 0x9F ||
+		b >= 0xA3 && b <= 0xA5 || b >= 0xAB && b <= 0xAF || b >= 0xB0 && b <= 0xC7 || b >= 0xD0
+	if !modrm {
+		return ErrInvalid
+	}
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	if twoByteImm8[b] {
+		if _, err := d.next(); err != nil {
+			return err
+		}
+	}
+	d.inst.Mnemonic = fmt.Sprintf("op0f.%02x", b)
+	return nil
+}
+
+func (d *decoder) grp7() error {
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	if d.mod == 3 {
+		m := 0xC0 | d.reg<<3 | d.rm
+		if name, ok := grp7Reg[m]; ok {
+			d.inst.Mnemonic = name
+			return nil
+		}
+		switch d.reg {
+		case 4:
+			return d.apply(opSpec{mnemonic: "smsw", ops: []string{"Ev"}})
+		case 6:
+			return d.apply(opSpec{mnemonic: "lmsw", ops: []string{"Ew"}})
+		}
+		d.inst.Mnemonic = fmt.Sprintf("op0f01.%02x", m)
+		return nil
+	}
+	name := groups["grp7"][d.reg]
+	if name == "" {
+		return ErrInvalid
+	}
+	return d.apply(opSpec{mnemonic: name, ops: []string{"M"}})
+}
+
+func (d *decoder) grp15() error {
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	if d.mod == 3 {
+		if d.rep == 0xF3 && d.reg <= 3 {
+			name := [4]string{"rdfsbase", "rdgsbase", "wrfsbase", "wrgsbase"}[d.reg]
+			return d.apply(opSpec{mnemonic: name, ops: []string{"Ry"}})
+		}
+		switch d.reg {
+		case 5:
+			d.inst.Mnemonic = "lfence"
+		case 6:
+			d.inst.Mnemonic = "mfence"
+		case 7:
+			d.inst.Mnemonic = "sfence"
+		default:
+			d.inst.Mnemonic = fmt.Sprintf("op0fae.%02x", 0xC0|d.reg<<3|d.rm)
+		}
+		return nil
+	}
+	return d.apply(opSpec{mnemonic: groups["grp15"][d.reg], ops: []string{"M"}})
+}
+
+func (d *decoder) grp9() error {
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	if d.mod == 3 {
+		switch {
+		case d.reg == 6:
+			return d.apply(opSpec{mnemonic: "rdrand", ops: []string{"Rv"}})
+		case d.reg == 7 && d.rep == 0xF3:
+			return d.apply(opSpec{mnemonic: "rdpid", ops: []string{"Rd"}})
+		case d.reg == 7:
+			return d.apply(opSpec{mnemonic: "rdseed", ops: []string{"Rv"}})
+		}
+		return ErrInvalid
+	}
+	name := groups["grp9"][d.reg]
+	if name == "" {
+		return ErrInvalid
+	}
+	if name == "cmpxchg8b" && d.rexW {
+		name = "cmpxchg16b"
+	}
+	return d.apply(opSpec{mnemonic: name, ops: []string{"M"}})
+}
+
+func (d *decoder) threeByte(m byte) error {
+	b, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.op = b
+	if d.mand == 0x66 {
+		d.opsize16 = false
+	}
+	var sp opSpec
+	switch {
+	case m == 0x38 && (b == 0xF0 || b == 0xF1) && d.rep == 0xF2:
+		sp = opSpec{mnemonic: "crc32", ops: []string{"Gy", "Eb"}}
+		if b == 0xF1 {
+			sp.ops[1] = "Ev"
+		}
+	case m == 0x38 && b == 0xF0:
+		sp = opSpec{mnemonic: "movbe", ops: []string{"Gv", "M"}}
+	case m == 0x38 && b == 0xF1:
+		sp = opSpec{mnemonic: "movbe", ops: []string{"M", "Gv"}}
+	case m == 0x38 && b == 0xF6:
+		sp = opSpec{mnemonic: "adcx", ops: []string{"Gy", "Ey"}}
+		if d.rep == 0xF3 {
+			sp.mnemonic = "adox"
+		}
+	case m == 0x38:
+		sp = opSpec{mnemonic: threeByte38[b], ops: []string{"Vx", "Wx"}}
+		if d.mand == 0 && b <= 0x1E {
+			sp.ops = []string{"Pq", "Qq"}
+		}
+	default:
+		sp = opSpec{mnemonic: threeByte3A[b], ops: []string{"Vx", "Wx", "Ib"}}
+		switch b {
+		case 0x14, 0x15, 0x16, 0x17:
+			sp.ops = []string{"Ey", "Vx", "Ib"}
+		case 0x20, 0x22:
+			sp.ops = []string{"Vx", "Ey", "Ib"}
+		case 0x0F:
+			if d.mand == 0 {
+				sp.ops = []string{"Pq", "Qq", "Ib"}
+			}
+		}
+		if b == 0x16 && d.rexW {
+			sp.mnemonic = "pextrq"
+		}
+		if b == 0x22 && d.rexW {
+			sp.mnemonic = "pinsrq"
+		}
+	}
+	if sp.mnemonic == "" {
+		sp.mnemonic = fmt.Sprintf("op0f%02x.%02x", m, b)
+	}
+	return d.apply(sp)
+}
+
+func (d *decoder) x87(b byte) error {
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	esc := b - 0xD8
+	if d.isMem {
+		f := x87Mem[esc][d.reg]
+		if f[0] == "" {
+			return ErrInvalid
+		}
+		d.inst.Mnemonic = f[0]
+		bits := map[string]int{"word": 16, "dword": 32, "qword": 64, "tbyte": 80}[f[1]]
+		d.inst.Operands = []string{d.mem(bits)}
+		return nil
+	}
+	key := uint16(b)<<8 | uint16(0xC0|d.reg<<3|d.rm)
+	if name, ok := x87RegNames[key]; ok {
+		d.inst.Mnemonic = name
+		return nil
+	}
+	name := x87RegForms[esc][d.reg]
+	if name == "" {
+		return ErrInvalid
+	}
+	d.inst.Mnemonic = name
+	sti := fmt.Sprintf("st(%d)", d.rm)
+	switch {
+	case esc == 0 || esc == 2 || esc == 3 || esc == 7 && d.reg >= 5:
+		d.inst.Operands = []string{"st", sti}
+	case esc == 4 || esc == 6:
+		d.inst.Operands = []string{sti, "st"}
+	default:
+		d.inst.Operands = []string{sti}
+	}
+	return nil
+}
+
+// vexInst decodes two- and three-byte VEX encodings.
+func (d *decoder) vexInst(b byte) error {
+	p1, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.vex = true
+	vmap := byte(1)
+	var pp byte
+	if b == 0xC5 {
+		d.rexR = p1&0x80 == 0
+		d.vexV = ^p1 >> 3 & 0xF
+		d.vexL = int(p1>>2) & 1
+		pp = p1 & 3
+	} else {
+		p2, err := d.next()
+		if err != nil {
+			return err
+		}
+		d.rexR, d.rexX, d.rexB = p1&0x80 == 0, p1&0x40 == 0, p1&0x20 == 0
+		vmap = p1 & 0x1F
+		d.rexW = p2&0x80 != 0
+		d.vexV = ^p2 >> 3 & 0xF
+		d.vexL = int(p2>>2) & 1
+		pp = p2 & 3
+	}
+	if d.bits == 32 {
+		d.rexR, d.rexX, d.rexB, d.rexW = false, false, false, false
+		d.vexV &= 7
+	}
+	d.mand = [4]byte{0, 0x66, 0xF3, 0xF2}[pp]
+	d.opsize16 = false
+	op, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.op = op
+	if vmap == 1 && op == 0x77 {
+		d.inst.Mnemonic = "vzeroupper"
+		if d.vexL == 1 {
+			d.inst.Mnemonic = "vzeroall"
+		}
+		return nil
+	}
+	return d.vexBody(vmap, op)
+}
+
+// evexInst decodes the AVX-512 EVEX prefix. Compressed disp8 is scaled by
+// the full vector width, which is right for the common full-vector forms.
+func (d *decoder) evexInst() error {
+	p, err := d.take(3)
+	if err != nil {
+		return err
+	}
+	d.vex, d.evex = true, true
+	d.rexR, d.rexX, d.rexB = p[0]&0x80 == 0, p[0]&0x40 == 0, p[0]&0x20 == 0
+	d.rHi = p[0]&0x10 == 0
+	vmap := p[0] & 7
+	d.rexW = p[1]&0x80 != 0
+	d.vexV = ^p[1] >> 3 & 0xF
+	if p[2]&0x08 == 0 {
+		d.vexV += 16
+	}
+	d.vexL = int(p[2]>>5) & 3
+	if d.vexL == 3 {
+		d.vexL = 2
+	}
+	if d.bits == 32 {
+		d.rexR, d.rexX, d.rexB, d.rHi = false, false, false, false
+		d.vexV &= 7
+	}
+	d.mand = [4]byte{0, 0x66, 0xF3, 0xF2}[p[1]&3]
+	d.opsize16 = false
+	d.dispScale = int64(d.vecBits() / 8)
+	if p[2]&0x10 != 0 {
+		d.dispScale = 4
+		if d.rexW {
+			d.dispScale = 8
+		}
+	}
+	op, err := d.next()
+	if err != nil {
+		return err
+	}
+	d.op = op
+	if err := d.vexBody(vmap, op); err != nil {
+		return err
+	}
+	if k := p[2] & 7; k != 0 && len(d.inst.Operands) > 0 {
+		d.inst.Operands[0] += fmt.Sprintf("{k%d}", k)
+		if p[2]&0x80 != 0 {
+			d.inst.Operands[0] += "{z}"
+		}
+	}
+	return nil
+}
+
+func (d *decoder) vexBody(vmap, op byte) error {
+	var sp opSpec
+	switch vmap {
+	case 1:
+		sp = d.vexMap1(op)
+	case 2:
+		sp = d.vexMap2(op)
+	case 3:
+		name := threeByte3A[op]
+		if name == "" {
+			name = vexOnly3A[op]
+		}
+		sp = opSpec{mnemonic: name, ops: []string{"Vx", "Hx", "Wx", "Ib"}}
+		switch op {
+		case 0xF0:
+			sp = opSpec{mnemonic: "rorx", ops: []string{"Gy", "Ey", "Ib"}}
+		case 0x14, 0x15, 0x16, 0x17, 0x19, 0x39:
+			sp.ops = []string{"Wx", "Vx", "Ib"}
+		}
+		if sp.mnemonic != "" && op != 0xF0 {
+			sp.mnemonic = "v" + sp.mnemonic
+		}
+	case 5, 6:
+		if !d.evex {
+			return ErrInvalid
+		}
+		sp.ops = []string{"Vx", "Hx", "Wx"}
+	default:
+		return ErrInvalid
+	}
+	if sp.mnemonic == "" {
+		sp.mnemonic = fmt.Sprintf("vop%d.%02x", vmap, op)
+		if len(sp.ops) == 0 {
+			sp.ops = []string{"Vx", "Hx", "Wx"}
+		}
+		if vmap == 3 && sp.ops[len(sp.ops)-1] != "Ib" {
+			sp.ops = append(sp.ops, "Ib")
+		}
+	}
+	if err := d.modrm(); err != nil {
+		return err
+	}
+	return d.apply(sp)
+}
+
+func (d *decoder) vexMap1(op byte) opSpec {
+	if op == 0xAE {
+		if err := d.modrm(); err == nil && d.isMem && (d.reg == 2 || d.reg == 3) {
+			return opSpec{mnemonic: "v" + groups["grp15"][d.reg], ops: []string{"M"}}
+		}
+		return opSpec{ops: []string{"Vx", "Hx", "Wx"}}
+	}
+	specs, ok := sseSpecs[op]
+	if !ok {
+		return opSpec{ops: []string{"Vx", "Hx", "Wx"}}
+	}
+	sp := specs[d.mandIndex()]
+	if sp.mnemonic == "" {
+		return opSpec{ops: []string{"Vx", "Hx", "Wx"}}
+	}
+	ops := make([]string, 0, len(sp.ops)+1)
+	for _, o := range sp.ops {
+		switch o {
+		case "Pq":
+			o = "Vx"
+		case "Qq":
+			o = "Wx"
+		case "Nq":
+			o = "Ux"
+		}
+		ops = append(ops, o)
+	}
+	mn := sp.mnemonic
+	if strings.HasPrefix(mn, "grp") {
+		// Immediate shifts: vpsrlw Hx, Ux, Ib.
+		name := ""
+		if err := d.modrm(); err == nil {
+			name = groups[mn][d.reg]
+		}
+		if name == "" {
+			return opSpec{ops: []string{"Hx", "Ux", "Ib"}}
+		}
+		return opSpec{mnemonic: "v" + name, ops: []string{"Hx", "Ux", "Ib"}}
+	}
+	if len(ops) >= 2 && ops[0] == "Vx" && !vexTwoOperand[op] {
+		ops = append([]string{"Vx", "Hx"}, ops[1:]...)
+	}
+	return opSpec{mnemonic: "v" + mn, ops: ops}
+}
+
+func (d *decoder) vexMap2(op byte) opSpec {
+	pp := d.mandIndex()
+	switch op {
+	case 0xF2:
+		return opSpec{mnemonic: "andn", ops: []string{"Gy", "By", "Ey"}}
+	case 0xF3:
+		if err := d.modrm(); err == nil {
+			if name := [8]string{1: "blsr", 2: "blsmsk", 3: "blsi"}[d.reg]; name != "" {
+				return opSpec{mnemonic: name, ops: []string{"By", "Ey"}}
+			}
+		}
+		return opSpec{ops: []string{"By", "Ey"}}
+	case 0xF5:
+		return opSpec{mnemonic: [4]string{"bzhi", "", "pext", "pdep"}[pp], ops: []string{"Gy", "Ey", "By"}}
+	case 0xF6:
+		return opSpec{mnemonic: [4]string{"", "", "", "mulx"}[pp], ops: []string{"Gy", "By", "Ey"}}
+	case 0xF7:
+		return opSpec{mnemonic: [4]string{"bextr", "shlx", "sarx", "shrx"}[pp], ops: []string{"Gy", "Ey", "By"}}
+	}
+	name := threeByte38[op]
+	if name == "" {
+		name = vexOnly38[op]
+	}
+	if name != "" {
+		name = "v" + name
+	}
+	return opSpec{mnemonic: name, ops: []string{"Vx", "Hx", "Wx"}}
+}
@@ -0,0 +1,342 @@
+package disasm
+
+import "strings"
+
+// Opcode tables use the operand notation of the Intel SDM opcode maps:
+//
+//	E = ModRM r/m, G = ModRM reg, I = immediate, J = relative branch,
+//	M = memory-only r/m, O = moffs, Z = register in the low opcode bits,
+//	S = segment register, V/W = xmm reg / r/m, P/Q = mmx reg / r/m,
+//	H = VEX.vvvv register, U = xmm r/m register-only, N = mmx r/m register-only.
+//
+// Size suffixes: b = byte, w = word, d = dword, q = qword, v = operand size,
+// z = 16/32 by operand size, y = 32/64, x = vector width.
+//
+// Trailing flags: d64 = 64-bit default operand size in long mode,
+// f64 = forced 64-bit in long mode, i64 = invalid in long mode,
+// o64 = valid only in long mode.
+
+var oneByte = [256]string{
+	0x00: "add Eb,Gb", 0x01: "add Ev,Gv", 0x02: "add Gb,Eb", 0x03: "add Gv,Ev", 0x04: "add AL,Ib", 0x05: "add rAX,Iz", 0x06: "push ES i64", 0x07: "pop ES i64",
+	0x08: "or Eb,Gb", 0x09: "or Ev,Gv", 0x0A: "or Gb,Eb", 0x0B: "or Gv,Ev", 0x0C: "or AL,Ib", 0x0D: "or rAX,Iz", 0x0E: "push CS i64",
+	0x10: "adc Eb,Gb", 0x11: "adc Ev,Gv", 0x12: "adc Gb,Eb", 0x13: "adc Gv,Ev", 0x14: "adc AL,Ib", 0x15: "adc rAX,Iz", 0x16: "push SS i64", 0x17: "pop SS i64",
+	0x18: "sbb Eb,Gb", 0x19: "sbb Ev,Gv", 0x1A: "sbb Gb,Eb", 0x1B: "sbb Gv,Ev", 0x1C: "sbb AL,Ib", 0x1D: "sbb rAX,Iz", 0x1E: "push DS i64", 0x1F: "pop DS i64",
+	0x20: "and Eb,Gb", 0x21: "and Ev,Gv", 0x22: "and Gb,Eb", 0x23: "and Gv,Ev", 0x24: "and AL,Ib", 0x25: "and rAX,Iz", 0x27: "daa i64",
+	0x28: "sub Eb,Gb", 0x29: "sub Ev,Gv", 0x2A: "sub Gb,Eb", 0x2B: "sub Gv,Ev", 0x2C: "sub AL,Ib", 0x2D: "sub rAX,Iz", 0x2F: "das i64",
+	0x30: "xor Eb,Gb", 0x31: "xor Ev,Gv", 0x32: "xor Gb,Eb", 0x33: "xor Gv,Ev", 0x34: "xor AL,Ib", 0x35: "xor rAX,Iz", 0x37: "aaa i64",
+	0x38: "cmp Eb,Gb", 0x39: "cmp Ev,Gv", 0x3A: "cmp Gb,Eb", 0x3B: "cmp Gv,Ev", 0x3C: "cmp AL,Ib", 0x3D: "cmp rAX,Iz", 0x3F: "aas i64",
+	0x40: "inc Zv i64", 0x41: "inc Zv i64", 0x42: "inc Zv i64", 0x43: "inc Zv i64", 0x44: "inc Zv i64", 0x45: "inc Zv i64", 0x46: "inc Zv i64", 0x47: "inc Zv i64",
+	0x48: "dec Zv i64", 0x49: "dec Zv i64", 0x4A: "dec Zv i64", 0x4B: "dec Zv i64", 0x4C: "dec Zv i64", 0x4D: "dec Zv i64", 0x4E: "dec Zv i64", 0x4F: "dec Zv i64",
+	0x50: "push Zv d64", 0x51: "push Zv d64", 0x52: "push Zv d64", 0x53: "push Zv d64", 0x54: "push Zv d64", 0x55: "push Zv d64", 0x56: "push Zv d64", 0x57: "push Zv d64",
+	0x58: "pop Zv d64", 0x59: "pop Zv d64", 0x5A: "pop Zv d64", 0x5B: "pop Zv d64", 0x5C: "pop Zv d64", 0x5D: "pop Zv d64", 0x5E: "pop Zv d64", 0x5F: "pop Zv d64",
+	0x60: "pushad i64", 0x61: "popad i64", 0x62: "bound Gv,M i64", 0x63: "arpl Ew,Gw",
+	0x68: "push Iz d64", 0x69: "imul Gv,Ev,Iz", 0x6A: "push IbS d64", 0x6B: "imul Gv,Ev,IbS", 0x6C: "insb", 0x6D: "insd", 0x6E: "outsb", 0x6F: "outsd",
+	0x70: "jo Jb f64", 0x71: "jno Jb f64", 0x72: "jb Jb f64", 0x73: "jae Jb f64", 0x74: "je Jb f64", 0x75: "jne Jb f64", 0x76: "jbe Jb f64", 0x77: "ja Jb f64",
+	0x78: "js Jb f64", 0x79: "jns Jb f64", 0x7A: "jp Jb f64", 0x7B: "jnp Jb f64", 0x7C: "jl Jb f64", 0x7D: "jge Jb f64", 0x7E: "jle Jb f64", 0x7F: "jg Jb f64",
+	0x80: "grp1 Eb,Ib", 0x81: "grp1 Ev,Iz", 0x82: "grp1 Eb,Ib i64", 0x83: "grp1 Ev,IbS",
+	0x84: "test Eb,Gb", 0x85: "test Ev,Gv", 0x86: "xchg Eb,Gb", 0x87: "xchg Ev,Gv",
+	0x88: "mov Eb,Gb", 0x89: "mov Ev,Gv", 0x8A: "mov Gb,Eb", 0x8B: "mov Gv,Ev", 0x8C: "mov Ev,Sw", 0x8D: "lea Gv,M", 0x8E: "mov Sw,Ew", 0x8F: "grp1a Ev d64",
+	0x90: "nop", 0x91: "xchg Zv,rAX", 0x92: "xchg Zv,rAX", 0x93: "xchg Zv,rAX", 0x94: "xchg Zv,rAX", 0x95: "xchg Zv,rAX", 0x96: "xchg Zv,rAX", 0x97: "xchg Zv,rAX",
+	0x98: "cwde", 0x99: "cdq", 0x9A: "call Ap i64", 0x9B: "fwait", 0x9C: "pushfd d64", 0x9D: "popfd d64", 0x9E: "sahf", 0x9F: "lahf",
+	0xA0: "mov AL,Ob", 0xA1: "mov rAX,Ov", 0xA2: "mov Ob,AL", 0xA3: "mov Ov,rAX", 0xA4: "movsb", 0xA5: "movsd", 0xA6: "cmpsb", 0xA7: "cmpsd",
+	0xA8: "test AL,Ib", 0xA9: "test rAX,Iz", 0xAA: "stosb", 0xAB: "stosd", 0xAC: "lodsb", 0xAD: "lodsd", 0xAE: "scasb", 0xAF: "scasd",
+	0xB0: "mov Zb,Ib", 0xB1: "mov Zb,Ib", 0xB2: "mov Zb,Ib", 0xB3: "mov Zb,Ib", 0xB4: "mov Zb,Ib", 0xB5: "mov Zb,Ib", 0xB6: "mov Zb,Ib", 0xB7: "mov Zb,Ib",
+	0xB8: "mov Zv,Iv", 0xB9: "mov Zv,Iv", 0xBA: "mov Zv,Iv", 0xBB: "mov Zv,Iv", 0xBC: "mov Zv,Iv", 0xBD: "mov Zv,Iv", 0xBE: "mov Zv,Iv", 0xBF: "mov Zv,Iv",
+	0xC0: "grp2 Eb,Ib", 0xC1: "grp2 Ev,Ib", 0xC2: "ret Iw f64", 0xC3: "ret f64", 0xC4: "les Gz,Mp i64", 0xC5: "lds Gz,Mp i64", 0xC6: "grp11 Eb,Ib", 0xC7: "grp11 Ev,Iz",
+	0xC8: "enter Iw,Ib", 0xC9: "leave d64", 0xCA: "retf Iw", 0xCB: "retf", 0xCC: "int3", 0xCD: "int Ib", 0xCE: "into i64", 0xCF: "iretd",
+	0xD0: "grp2 Eb,1", 0xD1: "grp2 Ev,1", 0xD2: "grp2 Eb,CL", 0xD3: "grp2 Ev,CL", 0xD4: "aam Ib i64", 0xD5: "aad Ib i64", 0xD6: "salc i64", 0xD7: "xlatb",
+	0xE0: "loopne Jb f64", 0xE1: "loope Jb f64", 0xE2: "loop Jb f64", 0xE3: "jecxz Jb f64", 0xE4: "in AL,Ib", 0xE5: "in eAX,Ib", 0xE6: "out Ib,AL", 0xE7: "out Ib,eAX",
+	0xE8: "call Jz f64", 0xE9: "jmp Jz f64", 0xEA: "jmp Ap i64", 0xEB: "jmp Jb f64", 0xEC: "in AL,DX", 0xED: "in eAX,DX", 0xEE: "out DX,AL", 0xEF: "out DX,eAX",
+	0xF1: "int1", 0xF4: "hlt", 0xF5: "cmc", 0xF6: "grp3 Eb", 0xF7: "grp3 Ev",
+	0xF8: "clc", 0xF9: "stc", 0xFA: "cli", 0xFB: "sti", 0xFC: "cld", 0xFD: "std", 0xFE: "grp4 Eb", 0xFF: "grp5 Ev",
+}
+
+var twoByte = [256]string{
+	0x00: "grp6 Ew", 0x01: "grp7", 0x02: "lar Gv,Ew", 0x03: "lsl Gv,Ew", 0x05: "syscall o64", 0x06: "clts", 0x07: "sysret o64",
+	0x08: "invd", 0x09: "wbinvd", 0x0B: "ud2", 0x0D: "prefetchw Ev", 0x0E: "femms", 0x0F: "3dnow Pq,Qq",
+	0x18: "grp16 M", 0x19: "nop Ev", 0x1A: "nop Ev", 0x1B: "nop Ev", 0x1C: "nop Ev", 0x1D: "nop Ev", 0x1E: "nop Ev", 0x1F: "nop Ev",
+	0x20: "mov Rd,Cd", 0x21: "mov Rd,Dd", 0x22: "mov Cd,Rd", 0x23: "mov Dd,Rd",
+	0x30: "wrmsr", 0x31: "rdtsc", 0x32: "rdmsr", 0x33: "rdpmc", 0x34: "sysenter", 0x35: "sysexit", 0x37: "getsec",
+	0x40: "cmovo Gv,Ev", 0x41: "cmovno Gv,Ev", 0x42: "cmovb Gv,Ev", 0x43: "cmovae Gv,Ev", 0x44: "cmove Gv,Ev", 0x45: "cmovne Gv,Ev", 0x46: "cmovbe Gv,Ev", 0x47: "cmova Gv,Ev",
+	0x48: "cmovs Gv,Ev", 0x49: "cmovns Gv,Ev", 0x4A: "cmovp Gv,Ev", 0x4B: "cmovnp Gv,Ev", 0x4C: "cmovl Gv,Ev", 0x4D: "cmovge Gv,Ev", 0x4E: "cmovle Gv,Ev", 0x4F: "cmovg Gv,Ev",
+	0x77: "emms", 0x78: "vmread Ey,Gy", 0x79: "vmwrite Gy,Ey",
+	0x80: "jo Jz f64", 0x81: "jno Jz f64", 0x82: "jb Jz f64", 0x83: "jae Jz f64", 0x84: "je Jz f64", 0x85: "jne Jz f64", 0x86: "jbe Jz f64", 0x87: "ja Jz f64",
+	0x88: "js Jz f64", 0x89: "jns Jz f64", 0x8A: "jp Jz f64", 0x8B: "jnp Jz f64", 0x8C: "jl Jz f64", 0x8D: "jge Jz f64", 0x8E: "jle Jz f64", 0x8F: "jg Jz f64",
+	0x90: "seto Eb", 0x91: "setno Eb", 0x92: "setb Eb", 0x93: "setae Eb", 0x94: "sete Eb", 0x95: "setne Eb", 0x96: "setbe Eb", 0x97: "seta Eb",
+	0x98: "sets Eb", 0x99: "setns Eb", 0x9A: "setp Eb", 0x9B: "setnp Eb", 0x9C: "setl Eb", 0x9D: "setge Eb", 0x9E: "setle Eb", 0x9F: "setg Eb",
+	0xA0: "push FS d64", 0xA1: "pop FS d64", 0xA2: "cpuid", 0xA3: "bt Ev,Gv", 0xA4: "shld Ev,Gv,Ib", 0xA5: "shld Ev,Gv,CL",
+	0xA8: "push GS d64", 0xA9: "pop GS d64", 0xAA: "rsm", 0xAB: "bts Ev,Gv", 0xAC: "shrd Ev,Gv,Ib", 0xAD: "shrd Ev,Gv,CL", 0xAE: "grp15", 0xAF: "imul Gv,Ev",
+	0xB0: "cmpxchg Eb,Gb", 0xB1: "cmpxchg Ev,Gv", 0xB2: "lss Gv,Mp", 0xB3: "btr Ev,Gv", 0xB4: "lfs Gv,Mp", 0xB5: "lgs Gv,Mp", 0xB6: "movzx Gv,Eb", 0xB7: "movzx Gv,Ew",
+	0xB8: "jmpe", 0xB9: "ud1 Gv,Ev", 0xBA: "grp8 Ev,Ib", 0xBB: "btc Ev,Gv", 0xBC: "bsf Gv,Ev", 0xBD: "bsr Gv,Ev", 0xBE: "movsx Gv,Eb", 0xBF: "movsx Gv,Ew",
+	0xC0: "xadd Eb,Gb", 0xC1: "xadd Ev,Gv", 0xC3: "movnti M,Gy", 0xC7: "grp9",
+	0xC8: "bswap Zy", 0xC9: "bswap Zy", 0xCA: "bswap Zy", 0xCB: "bswap Zy", 0xCC: "bswap Zy", 0xCD: "bswap Zy", 0xCE: "bswap Zy", 0xCF: "bswap Zy",
+	0xFF: "ud0 Gv,Ev",
+}
+
+// sseOps maps two-byte opcodes whose meaning depends on the mandatory prefix.
+// Index: 0 = none, 1 = 66, 2 = F3, 3 = F2.
+var sseOps = map[byte][4]string{
+	0x10: {"movups Vx,Wx", "movupd Vx,Wx", "movss Vx,Wx", "movsd Vx,Wx"},
+	0x11: {"movups Wx,Vx", "movupd Wx,Vx", "movss Wx,Vx", "movsd Wx,Vx"},
+	0x12: {"movlps Vx,Wx", "movlpd Vx,M", "movsldup Vx,Wx", "movddup Vx,Wx"},
+	0x13: {"movlps M,Vx", "movlpd M,Vx", "", ""},
+	0x14: {"unpcklps Vx,Wx", "unpcklpd Vx,Wx", "", ""},
+	0x15: {"unpckhps Vx,Wx", "unpckhpd Vx,Wx", "", ""},
+	0x16: {"movhps Vx,Wx", "movhpd Vx,M", "movshdup Vx,Wx", ""},
+	0x17: {"movhps M,Vx", "movhpd M,Vx", "", ""},
+	0x28: {"movaps Vx,Wx", "movapd Vx,Wx", "", ""},
+	0x29: {"movaps Wx,Vx", "movapd Wx,Vx", "", ""},
+	0x2A: {"cvtpi2ps Vx,Qq", "cvtpi2pd Vx,Qq", "cvtsi2ss Vx,Ey", "cvtsi2sd Vx,Ey"},
+	0x2B: {"movntps M,Vx", "movntpd M,Vx", "", ""},
+	0x2C: {"cvttps2pi Pq,Wx", "cvttpd2pi Pq,Wx", "cvttss2si Gy,Wx", "cvttsd2si Gy,Wx"},
+	0x2D: {"cvtps2pi Pq,Wx", "cvtpd2pi Pq,Wx", "cvtss2si Gy,Wx", "cvtsd2si Gy,Wx"},
+	0x2E: {"ucomiss Vx,Wx", "ucomisd Vx,Wx", "", ""},
+	0x2F: {"comiss Vx,Wx", "comisd Vx,Wx", "", ""},
+	0x50: {"movmskps Gd,Ux", "movmskpd Gd,Ux", "", ""},
+	0x51: {"sqrtps Vx,Wx", "sqrtpd Vx,Wx", "sqrtss Vx,Wx", "sqrtsd Vx,Wx"},
+	0x52: {"rsqrtps Vx,Wx", "", "rsqrtss Vx,Wx", ""},
+	0x53: {"rcpps Vx,Wx", "", "rcpss Vx,Wx", ""},
+	0x54: {"andps Vx,Wx", "andpd Vx,Wx", "", ""},
+	0x55: {"andnps Vx,Wx", "andnpd Vx,Wx", "", ""},
+	0x56: {"orps Vx,Wx", "orpd Vx,Wx", "", ""},
+	0x57: {"xorps Vx,Wx", "xorpd Vx,Wx", "", ""},
+	0x58: {"addps Vx,Wx", "addpd Vx,Wx", "addss Vx,Wx", "addsd Vx,Wx"},
+	0x59: {"mulps Vx,Wx", "mulpd Vx,Wx", "mulss Vx,Wx", "mulsd Vx,Wx"},
+	0x5A: {"cvtps2pd Vx,Wx", "cvtpd2ps Vx,Wx", "cvtss2sd Vx,Wx", "cvtsd2ss Vx,Wx"},
+	0x5B: {"cvtdq2ps Vx,Wx", "cvtps2dq Vx,Wx", "cvttps2dq Vx,Wx", ""},
+	0x5C: {"subps Vx,Wx", "subpd Vx,Wx", "subss Vx,Wx", "subsd Vx,Wx"},
+	0x5D: {"minps Vx,Wx", "minpd Vx,Wx", "minss Vx,Wx", "minsd Vx,Wx"},
+	0x5E: {"divps Vx,Wx", "divpd Vx,Wx", "divss Vx,Wx", "divsd Vx,Wx"},
+	0x5F: {"maxps Vx,Wx", "maxpd Vx,Wx", "maxss Vx,Wx", "maxsd Vx,Wx"},
+	0x60: {"punpcklbw Pq,Qq", "punpcklbw Vx,Wx", "", ""},
+	0x61: {"punpcklwd Pq,Qq", "punpcklwd Vx,Wx", "", ""},
+	0x62: {"punpckldq Pq,Qq", "punpckldq Vx,Wx", "", ""},
+	0x63: {"packsswb Pq,Qq", "packsswb Vx,Wx", "", ""},
+	0x64: {"pcmpgtb Pq,Qq", "pcmpgtb Vx,Wx", "", ""},
+	0x65: {"pcmpgtw Pq,Qq", "pcmpgtw Vx,Wx", "", ""},
+	0x66: {"pcmpgtd Pq,Qq", "pcmpgtd Vx,Wx", "", ""},
+	0x67: {"packuswb Pq,Qq", "packuswb Vx,Wx", "", ""},
+	0x68: {"punpckhbw Pq,Qq", "punpckhbw Vx,Wx", "", ""},
+	0x69: {"punpckhwd Pq,Qq", "punpckhwd Vx,Wx", "", ""},
+	0x6A: {"punpckhdq Pq,Qq", "punpckhdq Vx,Wx", "", ""},
+	0x6B: {"packssdw Pq,Qq", "packssdw Vx,Wx", "", ""},
+	0x6C: {"", "punpcklqdq Vx,Wx", "", ""},
+	0x6D: {"", "punpckhqdq Vx,Wx", "", ""},
+	0x6E: {"movd Pq,Ey", "movd Vx,Ey", "", ""},
+	0x6F: {"movq Pq,Qq", "movdqa Vx,Wx", "movdqu Vx,Wx", ""},
+	0x70: {"pshufw Pq,Qq,Ib", "pshufd Vx,Wx,Ib", "pshufhw Vx,Wx,Ib", "pshuflw Vx,Wx,Ib"},
+	0x71: {"grp12 Nq,Ib", "grp12 Ux,Ib", "", ""},
+	0x72: {"grp13 Nq,Ib", "grp13 Ux,Ib", "", ""},
+	0x73: {"grp14 Nq,Ib", "grp14 Ux,Ib", "", ""},
+	0x74: {"pcmpeqb Pq,Qq", "pcmpeqb Vx,Wx", "", ""},
+	0x75: {"pcmpeqw Pq,Qq", "pcmpeqw Vx,Wx", "", ""},
+	0x76: {"pcmpeqd Pq,Qq", "pcmpeqd Vx,Wx", "", ""},
+	0x7C: {"", "haddpd Vx,Wx", "", "haddps Vx,Wx"},
+	0x7D: {"", "hsubpd Vx,Wx", "", "hsubps Vx,Wx"},
+	0x7E: {"movd Ey,Pq", "movd Ey,Vx", "movq Vx,Wx", ""},
+	0x7F: {"movq Qq,Pq", "movdqa Wx,Vx", "movdqu Wx,Vx", ""},
+	0xB8: {"", "", "popcnt Gv,Ev", ""},
+	0xBC: {"", "", "tzcnt Gv,Ev", ""},
+	0xBD: {"", "", "lzcnt Gv,Ev", ""},
+	0xC2: {"cmpps Vx,Wx,Ib", "cmppd Vx,Wx,Ib", "cmpss Vx,Wx,Ib", "cmpsd Vx,Wx,Ib"},
+	0xC4: {"pinsrw Pq,Ey,Ib", "pinsrw Vx,Ey,Ib", "", ""},
+	0xC5: {"pextrw Gd,Nq,Ib", "pextrw Gd,Ux,Ib", "", ""},
+	0xC6: {"shufps Vx,Wx,Ib", "shufpd Vx,Wx,Ib", "", ""},
+	0xD0: {"", "addsubpd Vx,Wx", "", "addsubps Vx,Wx"},
+	0xD1: {"psrlw Pq,Qq", "psrlw Vx,Wx", "", ""},
+	0xD2: {"psrld Pq,Qq", "psrld Vx,Wx", "", ""},
+	0xD3: {"psrlq Pq,Qq", "psrlq Vx,Wx", "", ""},
+	0xD4: {"paddq Pq,Qq", "paddq Vx,Wx", "", ""},
+	0xD5: {"pmullw Pq,Qq", "pmullw Vx,Wx", "", ""},
+	0xD6: {"", "movq Wx,Vx", "movq2dq Vx,Nq", "movdq2q Pq,Ux"},
+	0xD7: {"pmovmskb Gd,Nq", "pmovmskb Gd,Ux", "", ""},
+	0xD8: {"psubusb Pq,Qq", "psubusb Vx,Wx", "", ""},
+	0xD9: {"psubusw Pq,Qq", "psubusw Vx,Wx", "", ""},
+	0xDA: {"pminub Pq,Qq", "pminub Vx,Wx", "", ""},
+	0xDB: {"pand Pq,Qq", "pand Vx,Wx", "", ""},
+	0xDC: {"paddusb Pq,Qq", "paddusb Vx,Wx", "", ""},
+	0xDD: {"paddusw Pq,Qq", "paddusw Vx,Wx", "", ""},
+	0xDE: {"pmaxub Pq,Qq", "pmaxub Vx,Wx", "", ""},
+	0xDF: {"pandn Pq,Qq", "pandn Vx,Wx", "", ""},
+	0xE0: {"pavgb Pq,Qq", "pavgb Vx,Wx", "", ""},
+	0xE1: {"psraw Pq,Qq", "psraw Vx,Wx", "", ""},
+	0xE2: {"psrad Pq,Qq", "psrad Vx,Wx", "", ""},
+	0xE3: {"pavgw Pq,Qq", "pavgw Vx,Wx", "", ""},
+	0xE4: {"pmulhuw Pq,Qq", "pmulhuw Vx,Wx", "", ""},
+	0xE5: {"pmulhw Pq,Qq", "pmulhw Vx,Wx", "", ""},
+	0xE6: {"", "cvttpd2dq Vx,Wx", "cvtdq2pd Vx,Wx", "cvtpd2dq Vx,Wx"},
+	0xE7: {"movntq M,Pq", "movntdq M,Vx", "", ""},
+	0xE8: {"psubsb Pq,Qq", "psubsb Vx,Wx", "", ""},
+	0xE9: {"psubsw Pq,Qq", "psubsw Vx,Wx", "", ""},
+	0xEA: {"pminsw Pq,Qq", "pminsw Vx,Wx", "", ""},
+	0xEB: {"por Pq,Qq", "por Vx,Wx", "", ""},
+	0xEC: {"paddsb Pq,Qq", "paddsb Vx,Wx", "", ""},
+	0xED: {"paddsw Pq,Qq", "paddsw Vx,Wx", "", ""},
+	0xEE: {"pmaxsw Pq,Qq", "pmaxsw Vx,Wx", "", ""},
+	0xEF: {"pxor Pq,Qq", "pxor Vx,Wx", "", ""},
+	0xF0: {"", "", "", "lddqu Vx,M"},
+	0xF1: {"psllw Pq,Qq", "psllw Vx,Wx", "", ""},
+	0xF2: {"pslld Pq,Qq", "pslld Vx,Wx", "", ""},
+	0xF3: {"psllq Pq,Qq", "psllq Vx,Wx", "", ""},
+	0xF4: {"pmuludq Pq,Qq", "pmuludq Vx,Wx", "", ""},
+	0xF5: {"pmaddwd Pq,Qq", "pmaddwd Vx,Wx", "", ""},
+	0xF6: {"psadbw Pq,Qq", "psadbw Vx,Wx", "", ""},
+	0xF7: {"maskmovq Pq,Nq", "maskmovdqu Vx,Ux", "", ""},
+	0xF8: {"psubb Pq,Qq", "psubb Vx,Wx", "", ""},
+	0xF9: {"psubw Pq,Qq", "psubw Vx,Wx", "", ""},
+	0xFA: {"psubd Pq,Qq", "psubd Vx,Wx", "", ""},
+	0xFB: {"psubq Pq,Qq", "psubq Vx,Wx", "", ""},
+	0xFC: {"paddb Pq,Qq", "paddb Vx,Wx", "", ""},
+	0xFD: {"paddw Pq,Qq", "paddw Vx,Wx", "", ""},
+	0xFE: {"paddd Pq,Qq", "paddd Vx,Wx", "", ""},
+}
+
+// Three-byte maps only need names; every entry takes ModRM and the 0F3A map
+// always carries an imm8.
+var threeByte38 = map[byte]string{
+	0x00: "pshufb", 0x01: "phaddw", 0x02: "phaddd", 0x03: "phaddsw", 0x04: "pmaddubsw", 0x05: "phsubw", 0x06: "phsubd", 0x07: "phsubsw",
+	0x08: "psignb", 0x09: "psignw", 0x0A: "psignd", 0x0B: "pmulhrsw", 0x10: "pblendvb", 0x14: "blendvps", 0x15: "blendvpd", 0x17: "ptest",
+	0x1C: "pabsb", 0x1D: "pabsw", 0x1E: "pabsd", 0x20: "pmovsxbw", 0x21: "pmovsxbd", 0x22: "pmovsxbq", 0x23: "pmovsxwd", 0x24: "pmovsxwq",
+	0x25: "pmovsxdq", 0x28: "pmuldq", 0x29: "pcmpeqq", 0x2A: "movntdqa", 0x2B: "packusdw", 0x30: "pmovzxbw", 0x31: "pmovzxbd", 0x32: "pmovzxbq",
+	0x33: "pmovzxwd", 0x34: "pmovzxwq", 0x35: "pmovzxdq", 0x37: "pcmpgtq", 0x38: "pminsb", 0x39: "pminsd", 0x3A: "pminuw", 0x3B: "pminud",
+	0x3C: "pmaxsb", 0x3D: "pmaxsd", 0x3E: "pmaxuw", 0x3F: "pmaxud", 0x40: "pmulld", 0x41: "phminposuw", 0xC8: "sha1nexte", 0xC9: "sha1msg1",
+	0xCA: "sha1msg2", 0xCB: "sha256rnds2", 0xCC: "sha256msg1", 0xCD: "sha256msg2", 0xDB: "aesimc", 0xDC: "aesenc", 0xDD: "aesenclast",
+	0xDE: "aesdec", 0xDF: "aesdeclast", 0xF0: "movbe", 0xF1: "movbe", 0xF2: "andn", 0xF5: "bzhi", 0xF6: "adcx", 0xF7: "bextr",
+}
+
+var threeByte3A = map[byte]string{
+	0x08: "roundps", 0x09: "roundpd", 0x0A: "roundss", 0x0B: "roundsd", 0x0C: "blendps", 0x0D: "blendpd", 0x0E: "pblendw", 0x0F: "palignr",
+	0x14: "pextrb", 0x15: "pextrw", 0x16: "pextrd", 0x17: "extractps", 0x18: "insertf128", 0x19: "extractf128", 0x20: "pinsrb", 0x21: "insertps",
+	0x22: "pinsrd", 0x38: "inserti128", 0x39: "extracti128", 0x40: "dpps", 0x41: "dppd", 0x42: "mpsadbw", 0x44: "pclmulqdq", 0x46: "perm2i128",
+	0x60: "pcmpestrm", 0x61: "pcmpestri", 0x62: "pcmpistrm", 0x63: "pcmpistri", 0xCC: "sha1rnds4", 0xDF: "aeskeygenassist", 0xF0: "rorx",
+}
+
+var groups = map[string][8]string{
+	"grp1":  {"add", "or", "adc", "sbb", "and", "sub", "xor", "cmp"},
+	"grp1a": {"pop", "", "", "", "", "", "", ""},
+	"grp2":  {"rol", "ror", "rcl", "rcr", "shl", "shr", "sal", "sar"},
+	"grp3":  {"test", "test", "not", "neg", "mul", "imul", "div", "idiv"},
+	"grp4":  {"inc", "dec", "", "", "", "", "", ""},
+	"grp5":  {"inc", "dec", "call", "call far", "jmp", "jmp far", "push", ""},
+	"grp6":  {"sldt", "str", "lldt", "ltr", "verr", "verw", "", ""},
+	"grp7":  {"sgdt", "sidt", "lgdt", "lidt", "smsw", "", "lmsw", "invlpg"},
+	"grp8":  {"", "", "", "", "bt", "bts", "btr", "btc"},
+	"grp9":  {"", "cmpxchg8b", "", "xrstors", "xsavec", "xsaves", "vmptrld", "vmptrst"},
+	"grp11": {"mov", "", "", "", "", "", "", ""},
+	"grp12": {"", "", "psrlw", "", "psraw", "", "psllw", ""},
+	"grp13": {"", "", "psrld", "", "psrad", "", "pslld", ""},
+	"grp14": {"", "", "psrlq", "psrldq", "", "", "psllq", "pslldq"},
+	"grp15": {"fxsave", "fxrstor", "ldmxcsr", "stmxcsr", "xsave", "xrstor", "xsaveopt", "clflush"},
+	"grp16": {"prefetchnta", "prefetcht0", "prefetcht1", "prefetcht2", "nop", "nop", "nop", "nop"},
+}
+
+var grp7Reg = map[byte]string{
+	0xC1: "vmcall", 0xC2: "vmlaunch", 0xC3: "vmresume", 0xC4: "vmxoff", 0xC8: "monitor", 0xC9: "mwait", 0xCA: "clac", 0xCB: "stac",
+	0xD0: "xgetbv", 0xD1: "xsetbv", 0xD5: "xend", 0xD6: "xtest", 0xEE: "rdpkru", 0xEF: "wrpkru", 0xF8: "swapgs", 0xF9: "rdtscp",
+}
+
+// x87 memory forms by escape opcode (D8..DF) and ModRM.reg, with the operand
+// size keyword.
+var x87Mem = [8][8][2]string{
+	{{"fadd", "dword"}, {"fmul", "dword"}, {"fcom", "dword"}, {"fcomp", "dword"}, {"fsub", "dword"}, {"fsubr", "dword"}, {"fdiv", "dword"}, {"fdivr", "dword"}},
+	{{"fld", "dword"}, {"", ""}, {"fst", "dword"}, {"fstp", "dword"}, {"fldenv", ""}, {"fldcw", "word"}, {"fnstenv", ""}, {"fnstcw", "word"}},
+	{{"fiadd", "dword"}, {"fimul", "dword"}, {"ficom", "dword"}, {"ficomp", "dword"}, {"fisub", "dword"}, {"fisubr", "dword"}, {"fidiv", "dword"}, {"fidivr", "dword"}},
+	{{"fild", "dword"}, {"fisttp", "dword"}, {"fist", "dword"}, {"fistp", "dword"}, {"", ""}, {"fld", "tbyte"}, {"", ""}, {"fstp", "tbyte"}},
+	{{"fadd", "qword"}, {"fmul", "qword"}, {"fcom", "qword"}, {"fcomp", "qword"}, {"fsub", "qword"}, {"fsubr", "qword"}, {"fdiv", "qword"}, {"fdivr", "qword"}},
+	{{"fld", "qword"}, {"fisttp", "qword"}, {"fst", "qword"}, {"fstp", "qword"}, {"frstor", ""}, {"", ""}, {"fnsave", ""}, {"fnstsw", "word"}},
+	{{"fiadd", "word"}, {"fimul", "word"}, {"ficom", "word"}, {"ficomp", "word"}, {"fisub", "word"}, {"fisubr", "word"}, {"fidiv", "word"}, {"fidivr", "word"}},
+	{{"fild", "word"}, {"fisttp", "word"}, {"fist", "word"}, {"fistp", "word"}, {"fbld", "tbyte"}, {"fild", "qword"}, {"fbstp", "tbyte"}, {"fistp", "qword"}},
+}
+
+var x87RegNames = map[uint16]string{
+	0xD9C9: "fxch", 0xD9D0: "fnop", 0xD9E0: "fchs", 0xD9E1: "fabs", 0xD9E4: "ftst", 0xD9E5: "fxam", 0xD9E8: "fld1", 0xD9E9: "fldl2t",
+	0xD9EA: "fldl2e", 0xD9EB: "fldpi", 0xD9EC: "fldlg2", 0xD9ED: "fldln2", 0xD9EE: "fldz", 0xD9F0: "f2xm1", 0xD9F1: "fyl2x", 0xD9F2: "fptan",
+	0xD9F3: "fpatan", 0xD9F4: "fxtract", 0xD9F5: "fprem1", 0xD9F6: "fdecstp", 0xD9F7: "fincstp", 0xD9F8: "fprem", 0xD9F9: "fyl2xp1",
+	0xD9FA: "fsqrt", 0xD9FB: "fsincos", 0xD9FC: "frndint", 0xD9FD: "fscale", 0xD9FE: "fsin", 0xD9FF: "fcos", 0xDAE9: "fucompp",
+	0xDBE2: "fnclex", 0xDBE3: "fninit", 0xDED9: "fcompp", 0xDFE0: "fnstsw ax",
+}
+
+var x87RegForms = [8][8]string{
+	{"fadd", "fmul", "fcom", "fcomp", "fsub", "fsubr", "fdiv", "fdivr"},
+	{"fld", "fxch", "", "", "", "", "", ""},
+	{"fcmovb", "fcmove", "fcmovbe", "fcmovu", "", "", "", ""},
+	{"fcmovnb", "fcmovne", "fcmovnbe", "fcmovnu", "", "fucomi", "fcomi", ""},
+	{"fadd", "fmul", "fcom", "fcomp", "fsubr", "fsub", "fdivr", "fdiv"},
+	{"ffree", "", "fst", "fstp", "fucom", "fucomp", "", ""},
+	{"faddp", "fmulp", "", "", "fsubrp", "fsubp", "fdivrp", "fdivp"},
+	{"ffreep", "", "", "", "", "fucomip", "fcomip", ""},
+}
+
+type opSpec struct {
+	mnemonic string
+	ops      []string
+	d64      bool
+	f64      bool
+	i64      bool
+	o64      bool
+}
+
+func parseSpec(s string) opSpec {
+	var sp opSpec
+	if s == "" {
+		return sp
+	}
+	fields := strings.Fields(s)
+	sp.mnemonic = fields[0]
+	for _, f := range fields[1:] {
+		switch f {
+		case "d64":
+			sp.d64 = true
+		case "f64":
+			sp.f64 = true
+		case "i64":
+			sp.i64 = true
+		case "o64":
+			sp.o64 = true
+		default:
+			sp.ops = strings.Split(f, ",")
+		}
+	}
+	return sp
+}
+
+var (
+	oneByteSpecs [256]opSpec
+	twoByteSpecs [256]opSpec
+	sseSpecs     = map[byte][4]opSpec{}
+)
+
+func init() {
+	for i, s := range oneByte {
+		oneByteSpecs[i] = parseSpec(s)
+	}
+	for i, s := range twoByte {
+		twoByteSpecs[i] = parseSpec(s)
+	}
+	for op, v := range sseOps {
+		var specs [4]opSpec
+		for i, s := range v {
+			specs[i] = parseSpec(s)
+		}
+		sseSpecs[op] = specs
+	}
+}
+
+// VEX-only opcodes in the 0F38 and 0F3A maps (AVX2, FMA, F16C).
+var vexOnly38 = map[byte]string{
+	0x0C: "permilps", 0x0D: "permilpd", 0x0E: "testps", 0x0F: "testpd", 0x13: "cvtph2ps", 0x16: "permps", 0x18: "broadcastss",
+	0x19: "broadcastsd", 0x1A: "broadcastf128", 0x2C: "maskmovps", 0x2D: "maskmovpd", 0x2E: "maskmovps", 0x2F: "maskmovpd",
+	0x36: "permd", 0x45: "psrlvd", 0x46: "psravd", 0x47: "psllvd", 0x58: "pbroadcastd", 0x59: "pbroadcastq", 0x5A: "broadcasti128",
+	0x78: "pbroadcastb", 0x79: "pbroadcastw", 0x8C: "pmaskmovd", 0x8E: "pmaskmovd", 0x90: "pgatherdd", 0x91: "pgatherqd",
+	0x92: "gatherdps", 0x93: "gatherqps", 0x96: "fmaddsub132ps", 0x97: "fmsubadd132ps", 0x98: "fmadd132ps", 0x99: "fmadd132ss",
+	0x9A: "fmsub132ps", 0x9B: "fmsub132ss", 0x9C: "fnmadd132ps", 0x9D: "fnmadd132ss", 0x9E: "fnmsub132ps", 0x9F: "fnmsub132ss",
+	0xA6: "fmaddsub213ps", 0xA7: "fmsubadd213ps", 0xA8: "fmadd213ps", 0xA9: "fmadd213ss", 0xAA: "fmsub213ps", 0xAB: "fmsub213ss",
+	0xAC: "fnmadd213ps", 0xAD: "fnmadd213ss", 0xAE: "fnmsub213ps", 0xAF: "fnmsub213ss", 0xB6: "fmaddsub231ps", 0xB7: "fmsubadd231ps",
+	0xB8: "fmadd231ps", 0xB9: "fmadd231ss", 0xBA: "fmsub231ps", 0xBB: "fmsub231ss", 0xBC: "fnmadd231ps", 0xBD: "fnmadd231ss",
+	0xBE: "fnmsub231ps", 0xBF: "fnmsub231ss",
+}
+
+var vexOnly3A = map[byte]string{
+	0x00: "permq", 0x01: "permpd", 0x02: "pblendd", 0x04: "permilps", 0x05: "permilpd", 0x06: "perm2f128", 0x1D: "cvtps2ph",
+	0x4A: "blendvps", 0x4B: "blendvpd", 0x4C: "pblendvb",
+}
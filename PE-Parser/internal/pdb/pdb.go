@@ -0,0 +1,341 @@
+// Package pdb reads the parts of an MSF 7.0 program database needed to
+// name code: the PDB info stream (GUID, age), the DBI stream (modules and
+// original section headers), public and global symbol records, and the
+// procedure records in each module's symbol stream.
+package pdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrNotMSF     = errors.New("not an MSF 7.0 program database")
+	ErrOldFormat  = errors.New("PDB 2.0 (JG) format is not supported")
+	ErrCorrupt    = errors.New("corrupt program database")
+	msfMagic      = []byte("Microsoft C/C++ MSF 7.00\r\n\x1aDS\x00\x00\x00")
+	jgMagicPrefix = []byte("Microsoft C/C++ program database 2.00")
+)
+
+const (
+	streamPDBInfo = 1
+	streamDBI     = 3
+
+	// Symbol record kinds.
+	sPub32     = 0x110E
+	sLData32   = 0x110C
+	sGData32   = 0x110D
+	sLProc32   = 0x110F
+	sGProc32   = 0x1110
+	sLProc32ID = 0x1146
+	sGProc32ID = 0x1147
+
+	pubFunction = 0x2 // cvpsfFunction
+
+	dbgHeaderSectionHdr = 5 // index into the DBI optional debug header
+
+	maxStreamSize = 1 << 30
+)
+
+// Kind of a symbol.
+const (
+	KindPublic   = "public"
+	KindFunction = "function"
+	KindData     = "data"
+)
+
+type Symbol struct {
+	Name    string
+	Segment uint16
+	Offset  uint32
+	Size    uint32 // procedure length, 0 when unknown
+	Kind    string
+	Code    bool // public symbols carry a function flag; procedures are always code
+}
+
+type Section struct {
+	Name           string
+	VirtualAddress uint32
+}
+
+type File struct {
+	Signature uint32
+	Age       uint32 // from the DBI stream; this is what CodeView records carry
+	GUID      [16]byte
+	Machine   uint16
+	Sections  []Section // original section headers, used to map segment:offset
+	Symbols   []Symbol
+}
+
+type msf struct {
+	data      []byte
+	blockSize uint32
+	sizes     []uint32
+	blocks    [][]uint32
+}
+
+// Open reads and parses the program database at path.
+func Open(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*File, error) {
+	if bytes.HasPrefix(data, jgMagicPrefix) {
+		return nil, ErrOldFormat
+	}
+	m, err := openMSF(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	info, err := m.stream(streamPDBInfo)
+	if err != nil || len(info) < 28 {
+		return nil, fmt.Errorf("%w: PDB info stream", ErrCorrupt)
+	}
+	f.Signature = binary.LittleEndian.Uint32(info[4:])
+	f.Age = binary.LittleEndian.Uint32(info[8:])
+	copy(f.GUID[:], info[12:28])
+
+	dbi, err := m.stream(streamDBI)
+	if err != nil {
+		return nil, fmt.Errorf("%w: DBI stream", ErrCorrupt)
+	}
+	if len(dbi) < 64 {
+		// A PDB without DBI (e.g. a type-only PDB) has no symbols.
+		return f, nil
+	}
+	if err := f.readDBI(m, dbi); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func openMSF(data []byte) (*msf, error) {
+	if len(data) < 56 || !bytes.HasPrefix(data, msfMagic) {
+		return nil, ErrNotMSF
+	}
+	m := &msf{data: data}
+	m.blockSize = binary.LittleEndian.Uint32(data[32:])
+	numDirBytes := binary.LittleEndian.Uint32(data[44:])
+	blockMap := binary.LittleEndian.Uint32(data[52:])
+	switch m.blockSize {
+	case 512, 1024, 2048, 4096, 8192, 16384, 32768:
+	default:
+		return nil, fmt.Errorf("%w: block size %d", ErrCorrupt, m.blockSize)
+	}
+
+	// The block map lists the blocks holding the stream directory.
+	nDirBlocks := (numDirBytes + m.blockSize - 1) / m.blockSize
+	mapOff := uint64(blockMap) * uint64(m.blockSize)
+	if mapOff+uint64(nDirBlocks)*4 > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: block map", ErrCorrupt)
+	}
+	dirBlocks := make([]uint32, nDirBlocks)
+	for i := range dirBlocks {
+		dirBlocks[i] = binary.LittleEndian.Uint32(data[mapOff+uint64(i)*4:])
+	}
+	dir, err := m.read(dirBlocks, numDirBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	u32 := func(off int) (uint32, bool) {
+		if off+4 > len(dir) {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint32(dir[off:]), true
+	}
+	n, ok := u32(0)
+	if !ok || n > uint32(len(dir)/4) {
+		return nil, fmt.Errorf("%w: stream directory", ErrCorrupt)
+	}
+	m.sizes = make([]uint32, n)
+	m.blocks = make([][]uint32, n)
+	pos := 4
+	for i := range m.sizes {
+		m.sizes[i], _ = u32(pos)
+		pos += 4
+	}
+	for i, size := range m.sizes {
+		if size == 0xFFFFFFFF {
+			continue
+		}
+		nb := (size + m.blockSize - 1) / m.blockSize
+		if uint64(pos)+uint64(nb)*4 > uint64(len(dir)) {
+			return nil, fmt.Errorf("%w: stream directory", ErrCorrupt)
+		}
+		m.blocks[i] = make([]uint32, nb)
+		for j := range m.blocks[i] {
+			m.blocks[i][j], _ = u32(pos)
+			pos += 4
+		}
+	}
+	return m, nil
+}
+
+func (m *msf) read(blocks []uint32, size uint32) ([]byte, error) {
+	if size > maxStreamSize {
+		return nil, fmt.Errorf("%w: stream too large", ErrCorrupt)
+	}
+	out := make([]byte, 0, size)
+	for _, b := range blocks {
+		off := uint64(b) * uint64(m.blockSize)
+		n := uint64(m.blockSize)
+		if rem := uint64(size) - uint64(len(out)); rem < n {
+			n = rem
+		}
+		if off+n > uint64(len(m.data)) {
+			return nil, fmt.Errorf("%w: block %d out of range", ErrCorrupt, b)
+		}
+		out = append(out, m.data[off:off+n]...)
+	}
+	return out, nil
+}
+
+func (m *msf) stream(i int) ([]byte, error) {
+	if i < 0 || i >= len(m.sizes) || m.sizes[i] == 0xFFFFFFFF {
+		return nil, fmt.Errorf("%w: no stream %d", ErrCorrupt, i)
+	}
+	return m.read(m.blocks[i], m.sizes[i])
+}
+
+func (f *File) readDBI(m *msf, dbi []byte) error {
+	u16 := func(off int) uint16 { return binary.LittleEndian.Uint16(dbi[off:]) }
+	i32 := func(off int) int { return int(int32(binary.LittleEndian.Uint32(dbi[off:]))) }
+
+	f.Age = binary.LittleEndian.Uint32(dbi[8:])
+	symRecords := int(u16(20))
+	f.Machine = u16(58)
+	modInfoSize := i32(24)
+	secContribSize, secMapSize := i32(28), i32(32)
+	srcInfoSize, tsMapSize := i32(36), i32(40)
+	dbgHdrSize, ecSize := i32(48), i32(52)
+	for _, n := range []int{modInfoSize, secContribSize, secMapSize, srcInfoSize, tsMapSize, dbgHdrSize, ecSize} {
+		if n < 0 || n > len(dbi) {
+			return fmt.Errorf("%w: DBI substream sizes", ErrCorrupt)
+		}
+	}
+
+	// Module info: each module's symbol stream holds its procedures.
+	mods := dbi[64:]
+	if modInfoSize <= len(mods) {
+		mods = mods[:modInfoSize]
+	}
+	for p := 0; p+64 <= len(mods); {
+		stream := int(binary.LittleEndian.Uint16(mods[p+34:]))
+		symBytes := binary.LittleEndian.Uint32(mods[p+36:])
+		if stream != 0xFFFF && symBytes > 4 {
+			if s, err := m.stream(stream); err == nil && int(symBytes) <= len(s) {
+				f.readSymbols(s[4:symBytes], false)
+			}
+		}
+		// Two NUL-terminated names follow the fixed part; records are 4-byte aligned.
+		q := p + 64
+		for k := 0; k < 2; k++ {
+			i := bytes.IndexByte(mods[q:], 0)
+			if i < 0 {
+				q = len(mods)
+				break
+			}
+			q += i + 1
+		}
+		p = (q + 3) &^ 3
+	}
+
+	// The optional debug header lists auxiliary streams; entry 5 holds the
+	// section headers of the image as linked.
+	dbgHdr := 64 + modInfoSize + secContribSize + secMapSize + srcInfoSize + tsMapSize + ecSize
+	if dbgHdr+dbgHdrSize <= len(dbi) && dbgHdrSize >= (dbgHeaderSectionHdr+1)*2 {
+		idx := int(u16(dbgHdr + dbgHeaderSectionHdr*2))
+		if idx != 0xFFFF {
+			if s, err := m.stream(idx); err == nil {
+				for p := 0; p+40 <= len(s); p += 40 {
+					name := s[p : p+8]
+					if i := bytes.IndexByte(name, 0); i >= 0 {
+						name = name[:i]
+					}
+					f.Sections = append(f.Sections, Section{Name: string(name), VirtualAddress: binary.LittleEndian.Uint32(s[p+12:])})
+				}
+			}
+		}
+	}
+
+	if s, err := m.stream(symRecords); err == nil {
+		f.readSymbols(s, true)
+	}
+	return nil
+}
+
+// readSymbols walks a CodeView symbol record list. Globals holds public and
+// global data records; module streams contribute procedures.
+func (f *File) readSymbols(s []byte, globals bool) {
+	cstr := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	}
+	for p := 0; p+4 <= len(s); {
+		recLen := int(binary.LittleEndian.Uint16(s[p:]))
+		if recLen < 2 || p+2+recLen > len(s) {
+			break
+		}
+		kind := binary.LittleEndian.Uint16(s[p+2:])
+		d := s[p+4 : p+2+recLen]
+		switch {
+		case globals && kind == sPub32 && len(d) >= 10:
+			flags := binary.LittleEndian.Uint32(d)
+			f.Symbols = append(f.Symbols, Symbol{
+				Name:    cstr(d[10:]),
+				Offset:  binary.LittleEndian.Uint32(d[4:]),
+				Segment: binary.LittleEndian.Uint16(d[8:]),
+				Kind:    KindPublic,
+				Code:    flags&pubFunction != 0,
+			})
+		case globals && (kind == sGData32 || kind == sLData32) && len(d) >= 10:
+			f.Symbols = append(f.Symbols, Symbol{
+				Name:    cstr(d[10:]),
+				Offset:  binary.LittleEndian.Uint32(d[4:]),
+				Segment: binary.LittleEndian.Uint16(d[8:]),
+				Kind:    KindData,
+			})
+		case !globals && (kind == sGProc32 || kind == sLProc32 || kind == sGProc32ID || kind == sLProc32ID) && len(d) >= 35:
+			f.Symbols = append(f.Symbols, Symbol{
+				Name:    cstr(d[35:]),
+				Size:    binary.LittleEndian.Uint32(d[12:]),
+				Offset:  binary.LittleEndian.Uint32(d[28:]),
+				Segment: binary.LittleEndian.Uint16(d[32:]),
+				Kind:    KindFunction,
+				Code:    true,
+			})
+		}
+		p += 2 + recLen
+	}
+}
+
+// RVA maps a segment:offset pair to an RVA using the section headers in the
+// PDB, or the image's own sections when the PDB carries none.
+func (f *File) RVA(seg uint16, off uint32, imageSections []Section) (uint32, bool) {
+	secs := f.Sections
+	if len(secs) == 0 {
+		secs = imageSections
+	}
+	if seg == 0 || int(seg) > len(secs) {
+		return 0, false
+	}
+	return secs[seg-1].VirtualAddress + off, true
+}
+
+// GUIDString formats the GUID the way CodeView records are displayed.
+func (f *File) GUIDString() string {
+	g := f.GUID
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:])
+}
@@ -0,0 +1,251 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	errZeroFill = errors.New("maps to zero-filled memory with no file data")
+	errUnmapped = errors.New("is not mapped by any section or the headers")
+)
+
+// fileAlignFloor is the boundary the loader rounds PointerToRawData down
+// to when FileAlignment is at least that large.
+const fileAlignFloor = 0x200
+
+// mappedRegion is one piece of the image as the loader maps it: the
+// headers or a section. Bytes [va, va+fileSize) come from the file at raw;
+// the rest up to va+size is zero-filled.
+type mappedRegion struct {
+	name     string
+	section  *pe.Section // nil for the headers
+	va       uint32
+	size     uint32 // virtual extent, rounded up to SectionAlignment
+	raw      uint32
+	fileSize uint32
+}
+
+// addrSpace models the image the way the Windows loader lays it out, so RVA
+// translation agrees with what runs: headers are addressable,
+// VirtualSize 0 falls back to SizeOfRawData, PointerToRawData is rounded
+// down, raw data is truncated at VirtualSize and the remainder is
+// zero-fill, and a later section wins where sections overlap.
+type addrSpace struct {
+	sizeOfImage uint32
+	flat        bool           // SectionAlignment below a page: RVA == file offset
+	regions     []mappedRegion // later sections first, headers last
+}
+
+// newAddrSpace builds the address space of f. It is built once per opened
+// file and passed to the parsers that translate RVAs.
+func newAddrSpace(f *pe.File) *addrSpace {
+	a := &addrSpace{}
+	_, oh32, oh64 := getOptional(f)
+	var sizeOfHeaders, secAlign, fileAlign uint32
+	switch {
+	case oh32 != nil:
+		a.sizeOfImage, sizeOfHeaders = oh32.SizeOfImage, oh32.SizeOfHeaders
+		secAlign, fileAlign = oh32.SectionAlignment, oh32.FileAlignment
+	case oh64 != nil:
+		a.sizeOfImage, sizeOfHeaders = oh64.SizeOfImage, oh64.SizeOfHeaders
+		secAlign, fileAlign = oh64.SectionAlignment, oh64.FileAlignment
+	}
+	if secAlign == 0 {
+		secAlign = 0x1000
+	}
+	// Windows only loads such an image when every section sits at its RVA
+	// in the file; ReadyToRun images built for other systems do not, and
+	// are translated through their sections instead.
+	a.flat = secAlign < 0x1000 && secAlign == fileAlign
+	for _, s := range f.Sections {
+		if s.Size != 0 && s.Offset != s.VirtualAddress {
+			a.flat = false
+		}
+	}
+
+	for i := len(f.Sections) - 1; i >= 0; i-- {
+		s := f.Sections[i]
+		vsize := s.VirtualSize
+		if vsize == 0 {
+			vsize = s.Size
+		}
+		raw := s.Offset
+		if fileAlign >= fileAlignFloor {
+			raw &^= fileAlignFloor - 1
+		}
+		n := s.Size
+		if fileAlign >= fileAlignFloor {
+			n = alignUp(n, fileAlign)
+		}
+		if n > vsize {
+			n = vsize
+		}
+		if s.Offset == 0 {
+			n = 0 // no file data at all
+		}
+		a.regions = append(a.regions, mappedRegion{
+			name: sectionName(s), section: s,
+			va: s.VirtualAddress, size: alignUp(vsize, secAlign),
+			raw: raw, fileSize: n,
+		})
+	}
+	a.regions = append(a.regions, mappedRegion{
+		name: "headers", size: alignUp(sizeOfHeaders, secAlign), fileSize: sizeOfHeaders,
+	})
+	return a
+}
+
+// region returns the region rva falls in.
+func (a *addrSpace) region(rva uint32) (*mappedRegion, bool) {
+	for i := range a.regions {
+		r := &a.regions[i]
+		if rva >= r.va && rva-r.va < r.size {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// span returns the file offset of rva and how many bytes from there are
+// backed by the file before zero-fill or the end of the region.
+func (a *addrSpace) span(rva uint32) (off, n uint32, err error) {
+	if a.sizeOfImage != 0 && rva >= a.sizeOfImage {
+		return 0, 0, fmt.Errorf("RVA 0x%08X is beyond SizeOfImage: %w", rva, errUnmapped)
+	}
+	if a.flat {
+		return rva, a.sizeOfImage - rva, nil
+	}
+	r, ok := a.region(rva)
+	if !ok {
+		return 0, 0, fmt.Errorf("RVA 0x%08X %w", rva, errUnmapped)
+	}
+	d := rva - r.va
+	if d >= r.fileSize {
+		return 0, 0, fmt.Errorf("RVA 0x%08X in %s %w", rva, r.name, errZeroFill)
+	}
+	return r.raw + d, r.fileSize - d, nil
+}
+
+// end is the first RVA past the image: SizeOfImage, or the end of the last
+// region when the header leaves it zero.
+func (a *addrSpace) end() uint64 {
+	if a.sizeOfImage != 0 {
+		return uint64(a.sizeOfImage)
+	}
+	var end uint64
+	for _, r := range a.regions {
+		if e := uint64(r.va) + uint64(r.size); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// offset translates rva to a file offset.
+func (a *addrSpace) offset(rva uint32) (uint32, error) {
+	off, _, err := a.span(rva)
+	return off, err
+}
+
+// resolveRVA translates rva to a file offset, with an error that says why
+// it has no file data.
+func resolveRVA(as *addrSpace, rva uint32) (uint32, error) {
+	return as.offset(rva)
+}
+
+// readMem reads n bytes at rva as the loaded image would see them:
+// zero-filled memory reads as zeros. It fails only for unmapped RVAs and
+// file data cut short by the end of the file.
+func readMem(as *addrSpace, bin []byte, rva, n uint32) ([]byte, bool) {
+	if uint64(rva)+uint64(n) > as.end() {
+		return nil, false // sizes come from the file; never allocate past the image
+	}
+	b := readMemPrefix(as, bin, rva, n)
+	return b, uint32(len(b)) == n
+}
+
+// readMemPrefix is readMem for tables whose length comes from the file: it
+// returns the readable leading part of the n bytes at rva, stopping at
+// unmapped memory, the end of the file or the end of the image.
+func readMemPrefix(as *addrSpace, bin []byte, rva, n uint32) []byte {
+	if end := as.end(); uint64(rva)+uint64(n) > end {
+		if uint64(rva) >= end {
+			return nil
+		}
+		n = uint32(end - uint64(rva))
+	}
+	var out []byte
+	for uint32(len(out)) < n {
+		cur := rva + uint32(len(out))
+		want := n - uint32(len(out))
+		off, avail, err := as.span(cur)
+		if errors.Is(err, errZeroFill) {
+			r, _ := as.region(cur)
+			z := r.va + r.size - cur
+			if z > want {
+				z = want
+			}
+			out = append(out, make([]byte, z)...)
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if avail > want {
+			avail = want
+		}
+		if uint64(off)+uint64(avail) > uint64(len(bin)) {
+			if uint64(off) < uint64(len(bin)) {
+				out = append(out, bin[off:]...)
+			}
+			break
+		}
+		out = append(out, bin[off:off+avail]...)
+	}
+	return out
+}
+
+func memU16(as *addrSpace, bin []byte, rva uint32) (uint16, bool) {
+	b, ok := readMem(as, bin, rva, 2)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b), true
+}
+
+func memU32(as *addrSpace, bin []byte, rva uint32) (uint32, bool) {
+	b, ok := readMem(as, bin, rva, 4)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+func memU64(as *addrSpace, bin []byte, rva uint32) (uint64, bool) {
+	b, ok := readMem(as, bin, rva, 8)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(b), true
+}
+
+// memUTF16 reads a counted UTF-16 string (length word, then characters).
+func memUTF16(as *addrSpace, bin []byte, rva uint32) (string, bool) {
+	n, ok := memU16(as, bin, rva)
+	if !ok {
+		return "", false
+	}
+	b, ok := readMem(as, bin, rva+2, uint32(n)*2)
+	if !ok {
+		return "", false
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u)), true
+}
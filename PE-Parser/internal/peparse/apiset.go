@@ -0,0 +1,186 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// APISetResolver maps API-set contract names (api-ms-win-*, ext-ms-*) to the
+// DLL that hosts them. Keys are lower case with the trailing "-<n>.dll"
+// revision removed, which is how the loader matches them too.
+type APISetResolver struct {
+	schema map[string]string
+}
+
+// builtinAPISets is a prefix table for the Windows 10/11 schema. Longer
+// prefixes win; anything else under api-ms-win-core- lives in kernelbase.
+var builtinAPISets = []struct{ prefix, host string }{
+	{"api-ms-win-core-rtlsupport-", "ntdll.dll"},
+	{"api-ms-win-core-apiquery-", "ntdll.dll"},
+	{"api-ms-win-core-crt-", "ntdll.dll"},
+	{"api-ms-win-core-kernel32-legacy-", "kernel32.dll"},
+	{"api-ms-win-core-kernel32-private-", "kernel32.dll"},
+	{"api-ms-win-core-ums-", "kernel32.dll"},
+	{"api-ms-win-core-com-", "combase.dll"},
+	{"api-ms-win-core-winrt-", "combase.dll"},
+	{"api-ms-win-core-marshal-", "combase.dll"},
+	{"api-ms-win-core-", "kernelbase.dll"},
+	{"api-ms-win-crt-", "ucrtbase.dll"},
+	{"api-ms-win-eventing-provider-", "kernelbase.dll"},
+	{"api-ms-win-eventing-classicprovider-", "kernelbase.dll"},
+	{"api-ms-win-eventing-", "sechost.dll"},
+	{"api-ms-win-security-base-", "kernelbase.dll"},
+	{"api-ms-win-security-appcontainer-", "kernelbase.dll"},
+	{"api-ms-win-security-lsalookup-", "sechost.dll"},
+	{"api-ms-win-security-sddl-", "sechost.dll"},
+	{"api-ms-win-security-credentials-", "sechost.dll"},
+	{"api-ms-win-security-cryptoapi-", "cryptsp.dll"},
+	{"api-ms-win-security-lsapolicy-", "advapi32.dll"},
+	{"api-ms-win-service-", "sechost.dll"},
+	{"api-ms-win-shcore-", "shcore.dll"},
+	{"api-ms-win-shell-", "shell32.dll"},
+	{"api-ms-win-ntuser-", "user32.dll"},
+	{"api-ms-win-rtcore-ntuser-", "user32.dll"},
+	{"api-ms-win-gdi-", "gdi32.dll"},
+	{"api-ms-win-mm-", "winmm.dll"},
+	{"api-ms-win-power-", "powrprof.dll"},
+	{"api-ms-win-devices-config-", "cfgmgr32.dll"},
+	{"api-ms-win-downlevel-advapi32-", "advapi32.dll"},
+	{"api-ms-win-downlevel-kernel32-", "kernel32.dll"},
+	{"api-ms-win-downlevel-ole32-", "ole32.dll"},
+	{"api-ms-win-downlevel-shlwapi-", "shlwapi.dll"},
+	{"api-ms-win-downlevel-user32-", "user32.dll"},
+	{"api-ms-win-downlevel-version-", "version.dll"},
+	{"api-ms-win-downlevel-normaliz-", "normaliz.dll"},
+	{"api-ms-win-downlevel-shell32-", "shell32.dll"},
+	{"ext-ms-win-ntuser-", "user32.dll"},
+	{"ext-ms-win-rtcore-ntuser-", "user32.dll"},
+	{"ext-ms-win-gdi-", "gdi32.dll"},
+	{"ext-ms-win-rtcore-gdi-", "gdi32.dll"},
+	{"ext-ms-win-kernel32-", "kernel32.dll"},
+	{"ext-ms-win-advapi32-", "advapi32.dll"},
+	{"ext-ms-win-shell32-", "shell32.dll"},
+	{"ext-ms-win-ole32-", "ole32.dll"},
+	{"ext-ms-win-oleacc-", "oleacc.dll"},
+	{"ext-ms-win-com-ole32-", "ole32.dll"},
+	{"ext-ms-win-session-", "winsta.dll"},
+	{"ext-ms-win-security-", "advapi32.dll"},
+}
+
+func NewAPISetResolver() *APISetResolver {
+	return &APISetResolver{}
+}
+
+// IsAPISet reports whether name is an API-set contract rather than a file.
+func IsAPISet(name string) bool {
+	n := strings.ToLower(name)
+	return strings.HasPrefix(n, "api-") || strings.HasPrefix(n, "ext-")
+}
+
+// apiSetKey lowers name and strips ".dll" and the last "-<n>" revision.
+func apiSetKey(name string) string {
+	n := strings.TrimSuffix(strings.ToLower(name), ".dll")
+	if i := strings.LastIndexByte(n, '-'); i > 0 {
+		n = n[:i]
+	}
+	return n
+}
+
+// Resolve returns the host DLL for an API-set name, or "" if name is not an
+// API set or is unknown.
+func (a *APISetResolver) Resolve(name string) string {
+	if !IsAPISet(name) {
+		return ""
+	}
+	key := apiSetKey(name)
+	if a.schema != nil {
+		return a.schema[key]
+	}
+	best, host := 0, ""
+	for _, e := range builtinAPISets {
+		if strings.HasPrefix(key+"-", e.prefix) && len(e.prefix) > best {
+			best, host = len(e.prefix), e.host
+		}
+	}
+	return host
+}
+
+// LoadSchema replaces the built-in table with the .apiset section of a local
+// apisetschema.dll. Only the version 6 layout (Windows 10 and later) is read.
+func (a *APISetResolver) LoadSchema(path string) error {
+	f, err := pe.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := f.Section(".apiset")
+	if s == nil {
+		return fmt.Errorf("%s: no .apiset section", path)
+	}
+	b, err := s.Data()
+	if err != nil {
+		return err
+	}
+	m, err := parseAPISetSchema(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	a.schema = m
+	return nil
+}
+
+func parseAPISetSchema(b []byte) (map[string]string, error) {
+	u32 := func(off uint32) uint32 {
+		v, _ := readU32(b, off)
+		return v
+	}
+	str := func(off, n uint32) string {
+		if uint64(off)+uint64(n) > uint64(len(b)) || n%2 != 0 {
+			return ""
+		}
+		w := make([]uint16, n/2)
+		for i := range w {
+			w[i] = binary.LittleEndian.Uint16(b[off+uint32(i*2):])
+		}
+		return string(utf16.Decode(w))
+	}
+	if len(b) < 28 {
+		return nil, fmt.Errorf("schema too small")
+	}
+	if v := u32(0); v != 6 {
+		return nil, fmt.Errorf("unsupported API set schema version %d", v)
+	}
+	count, entries := u32(12), u32(16)
+	if uint64(entries)+uint64(count)*24 > uint64(len(b)) {
+		return nil, fmt.Errorf("schema entries out of range")
+	}
+	m := make(map[string]string, count)
+	for i := uint32(0); i < count; i++ {
+		e := entries + i*24
+		name := strings.ToLower(str(u32(e+4), u32(e+12))) // hashed length excludes the revision
+		valOff, valCount := u32(e+16), u32(e+20)
+		host := ""
+		// The first value entry is the default host; later ones are
+		// per-importer redirections.
+		if valCount > 0 {
+			host = strings.ToLower(str(u32(valOff+12), u32(valOff+16)))
+		}
+		if name != "" {
+			m[name] = host
+		}
+	}
+	return m, nil
+}
+
+// resolveAPISets fills in the host DLL of every API-set import.
+func resolveAPISets(imp *ImportReport, a *APISetResolver) {
+	for i := range imp.DLLs {
+		imp.DLLs[i].Host = a.Resolve(imp.DLLs[i].Name)
+	}
+	for i := range imp.Delay {
+		imp.Delay[i].Host = a.Resolve(imp.Delay[i].Name)
+	}
+}
@@ -0,0 +1,563 @@
+package peparse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Metadata table numbers (ECMA-335 II.22).
+const (
+	tblModule                 = 0x00
+	tblTypeRef                = 0x01
+	tblTypeDef                = 0x02
+	tblField                  = 0x04
+	tblMethodDef              = 0x06
+	tblParam                  = 0x08
+	tblInterfaceImpl          = 0x09
+	tblMemberRef              = 0x0A
+	tblConstant               = 0x0B
+	tblCustomAttribute        = 0x0C
+	tblDeclSecurity           = 0x0E
+	tblStandAloneSig          = 0x11
+	tblEvent                  = 0x14
+	tblProperty               = 0x17
+	tblModuleRef              = 0x1A
+	tblTypeSpec               = 0x1B
+	tblImplMap                = 0x1C
+	tblAssembly               = 0x20
+	tblAssemblyRef            = 0x23
+	tblFile                   = 0x26
+	tblExportedType           = 0x27
+	tblManifestResource       = 0x28
+	tblNestedClass            = 0x29
+	tblGenericParam           = 0x2A
+	tblMethodSpec             = 0x2B
+	tblGenericParamConstraint = 0x2C
+	numTables                 = 0x2D
+)
+
+// Column kinds. Values below colCoded are simple indexes into that table.
+const (
+	colU8 = 0x100 + iota
+	colU16
+	colU32
+	colString
+	colGUID
+	colBlob
+	colCoded // colCoded + coded index kind
+)
+
+// Coded index kinds (ECMA-335 II.24.2.6) and the tables each can refer to,
+// in tag order; -1 marks an unused tag.
+const (
+	cTypeDefOrRef = iota
+	cHasConstant
+	cHasCustomAttribute
+	cHasFieldMarshal
+	cHasDeclSecurity
+	cMemberRefParent
+	cHasSemantics
+	cMethodDefOrRef
+	cMemberForwarded
+	cImplementation
+	cCustomAttributeType
+	cResolutionScope
+	cTypeOrMethodDef
+)
+
+var codedTables = [][]int{
+	cTypeDefOrRef:        {tblTypeDef, tblTypeRef, tblTypeSpec},
+	cHasConstant:         {tblField, tblParam, tblProperty},
+	cHasCustomAttribute:  {tblMethodDef, tblField, tblTypeRef, tblTypeDef, tblParam, tblInterfaceImpl, tblMemberRef, tblModule, tblDeclSecurity, tblProperty, tblEvent, tblStandAloneSig, tblModuleRef, tblTypeSpec, tblAssembly, tblAssemblyRef, tblFile, tblExportedType, tblManifestResource, tblGenericParam, tblGenericParamConstraint, tblMethodSpec},
+	cHasFieldMarshal:     {tblField, tblParam},
+	cHasDeclSecurity:     {tblTypeDef, tblMethodDef, tblAssembly},
+	cMemberRefParent:     {tblTypeDef, tblTypeRef, tblModuleRef, tblMethodDef, tblTypeSpec},
+	cHasSemantics:        {tblEvent, tblProperty},
+	cMethodDefOrRef:      {tblMethodDef, tblMemberRef},
+	cMemberForwarded:     {tblField, tblMethodDef},
+	cImplementation:      {tblFile, tblAssemblyRef, tblExportedType},
+	cCustomAttributeType: {-1, -1, tblMethodDef, tblMemberRef, -1},
+	cResolutionScope:     {tblModule, tblModuleRef, tblAssemblyRef, tblTypeRef},
+	cTypeOrMethodDef:     {tblTypeDef, tblMethodDef},
+}
+
+// tableSchema lists the columns of every table up to
+// GenericParamConstraint; row sizes of all present tables are needed to
+// locate the later ones.
+var tableSchema = [numTables][]int{
+	0x00: {colU16, colString, colGUID, colGUID, colGUID},
+	0x01: {colCoded + cResolutionScope, colString, colString},
+	0x02: {colU32, colString, colString, colCoded + cTypeDefOrRef, tblField, tblMethodDef},
+	0x03: {tblField},
+	0x04: {colU16, colString, colBlob},
+	0x05: {tblMethodDef},
+	0x06: {colU32, colU16, colU16, colString, colBlob, tblParam},
+	0x07: {tblParam},
+	0x08: {colU16, colU16, colString},
+	0x09: {tblTypeDef, colCoded + cTypeDefOrRef},
+	0x0A: {colCoded + cMemberRefParent, colString, colBlob},
+	0x0B: {colU16, colCoded + cHasConstant, colBlob},
+	0x0C: {colCoded + cHasCustomAttribute, colCoded + cCustomAttributeType, colBlob},
+	0x0D: {colCoded + cHasFieldMarshal, colBlob},
+	0x0E: {colU16, colCoded + cHasDeclSecurity, colBlob},
+	0x0F: {colU16, colU32, tblTypeDef},
+	0x10: {colU32, tblField},
+	0x11: {colBlob},
+	0x12: {tblTypeDef, tblEvent},
+	0x13: {tblEvent},
+	0x14: {colU16, colString, colCoded + cTypeDefOrRef},
+	0x15: {tblTypeDef, tblProperty},
+	0x16: {tblProperty},
+	0x17: {colU16, colString, colBlob},
+	0x18: {colU16, tblMethodDef, colCoded + cHasSemantics},
+	0x19: {tblTypeDef, colCoded + cMethodDefOrRef, colCoded + cMethodDefOrRef},
+	0x1A: {colString},
+	0x1B: {colBlob},
+	0x1C: {colU16, colCoded + cMemberForwarded, colString, tblModuleRef},
+	0x1D: {colU32, tblField},
+	0x1E: {colU32, colU32},
+	0x1F: {colU32},
+	0x20: {colU32, colU16, colU16, colU16, colU16, colU32, colBlob, colString, colString},
+	0x21: {colU32},
+	0x22: {colU32, colU32, colU32},
+	0x23: {colU16, colU16, colU16, colU16, colU32, colBlob, colString, colString, colBlob},
+	0x24: {colU32, tblAssemblyRef},
+	0x25: {colU32, colU32, colU32, tblAssemblyRef},
+	0x26: {colU32, colString, colBlob},
+	0x27: {colU32, colU32, colString, colString, colCoded + cImplementation},
+	0x28: {colU32, colU32, colString, colCoded + cImplementation},
+	0x29: {tblTypeDef, tblTypeDef},
+	0x2A: {colU16, colU16, colCoded + cTypeOrMethodDef, colString},
+	0x2B: {colCoded + cMethodDefOrRef, colBlob},
+	0x2C: {tblGenericParam, colCoded + cTypeDefOrRef},
+}
+
+var tableNames = [numTables]string{
+	"Module", "TypeRef", "TypeDef", "FieldPtr", "Field", "MethodPtr", "MethodDef", "ParamPtr",
+	"Param", "InterfaceImpl", "MemberRef", "Constant", "CustomAttribute", "FieldMarshal", "DeclSecurity", "ClassLayout",
+	"FieldLayout", "StandAloneSig", "EventMap", "EventPtr", "Event", "PropertyMap", "PropertyPtr", "Property",
+	"MethodSemantics", "MethodImpl", "ModuleRef", "TypeSpec", "ImplMap", "FieldRVA", "EncLog", "EncMap",
+	"Assembly", "AssemblyProcessor", "AssemblyOS", "AssemblyRef", "AssemblyRefProcessor", "AssemblyRefOS", "File", "ExportedType",
+	"ManifestResource", "NestedClass", "GenericParam", "MethodSpec", "GenericParamConstraint",
+}
+
+// mdStream is one stream header of the metadata root.
+type mdStream struct {
+	name      string
+	off, size uint32
+}
+
+// metadata is a parsed metadata root: the heaps and the table stream with
+// the row layout of every table.
+type metadata struct {
+	version string
+	streams []mdStream
+	strs    []byte
+	us      []byte
+	guids   []byte
+	blobs   []byte
+
+	tables   []byte
+	rows     [numTables]uint32
+	tableOff [numTables]uint32
+	rowSize  [numTables]uint32
+	colOff   [numTables][]uint32
+	colSize  [numTables][]uint32
+	wideStr  bool
+	wideGUID bool
+	wideBlob bool
+
+	owners []uint32          // MethodDef row -> TypeDef row, built on first use
+	nested map[uint32]uint32 // nested TypeDef row -> enclosing row
+}
+
+// parseMetadata reads the metadata root at the start of md (ECMA-335
+// II.24.2.1) and lays out the tables of the #~ (or uncompressed #-) stream.
+func parseMetadata(md []byte) (*metadata, error) {
+	if sig, _ := readU32(md, 0); sig != 0x424A5342 {
+		return nil, fmt.Errorf("bad metadata signature 0x%08X", sig)
+	}
+	vlen, ok := readU32(md, 12)
+	if !ok || vlen > 256 || 16+vlen+4 > uint32(len(md)) {
+		return nil, fmt.Errorf("bad metadata version length %d", vlen)
+	}
+	m := &metadata{version: strings.TrimRight(string(md[16:16+vlen]), "\x00")}
+	pos := 16 + vlen
+	n, _ := readU16(md, pos+2)
+	pos += 4
+	var tables []byte
+	for i := uint16(0); i < n; i++ {
+		off, ok1 := readU32(md, pos)
+		size, ok2 := readU32(md, pos+4)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("stream headers extend past the metadata")
+		}
+		pos += 8
+		end := pos
+		for end < uint32(len(md)) && md[end] != 0 && end-pos < 32 {
+			end++
+		}
+		name := string(md[pos:end])
+		pos = (end + 4) &^ 3
+		m.streams = append(m.streams, mdStream{name: name, off: off, size: size})
+		if uint64(off)+uint64(size) > uint64(len(md)) {
+			continue
+		}
+		data := md[off : off+size]
+		// The first stream of a name wins, as in the runtime.
+		switch name {
+		case "#Strings":
+			if m.strs == nil {
+				m.strs = data
+			}
+		case "#US":
+			if m.us == nil {
+				m.us = data
+			}
+		case "#GUID":
+			if m.guids == nil {
+				m.guids = data
+			}
+		case "#Blob":
+			if m.blobs == nil {
+				m.blobs = data
+			}
+		case "#~", "#-":
+			if tables == nil {
+				tables = data
+			}
+		}
+	}
+	if tables == nil {
+		return m, fmt.Errorf("no #~ table stream")
+	}
+	return m, m.layoutTables(tables)
+}
+
+func (m *metadata) layoutTables(t []byte) error {
+	if len(t) < 24 {
+		return fmt.Errorf("table stream too short")
+	}
+	heapSizes := t[6]
+	m.wideStr, m.wideGUID, m.wideBlob = heapSizes&1 != 0, heapSizes&2 != 0, heapSizes&4 != 0
+	valid := binary.LittleEndian.Uint64(t[8:])
+	pos := uint32(24)
+	for i := 0; i < 64; i++ {
+		if valid&(1<<uint(i)) == 0 {
+			continue
+		}
+		n, ok := readU32(t, pos)
+		if !ok {
+			return fmt.Errorf("row counts extend past the table stream")
+		}
+		pos += 4
+		if i >= numTables {
+			return fmt.Errorf("unknown metadata table 0x%02X", i)
+		}
+		m.rows[i] = n
+	}
+	if heapSizes&0x40 != 0 {
+		pos += 4 // extra data in uncompressed streams
+	}
+	for i := 0; i < numTables; i++ {
+		m.colOff[i] = make([]uint32, len(tableSchema[i]))
+		m.colSize[i] = make([]uint32, len(tableSchema[i]))
+		var size uint32
+		for c, kind := range tableSchema[i] {
+			w := m.columnSize(kind)
+			m.colOff[i][c], m.colSize[i][c] = size, w
+			size += w
+		}
+		m.rowSize[i] = size
+		m.tableOff[i] = pos
+		if uint64(pos)+uint64(m.rows[i])*uint64(size) > uint64(len(t)) {
+			return fmt.Errorf("%s table extends past the table stream", tableNames[i])
+		}
+		pos += m.rows[i] * size
+	}
+	m.tables = t
+	return nil
+}
+
+func (m *metadata) columnSize(kind int) uint32 {
+	wide := func(b bool) uint32 {
+		if b {
+			return 4
+		}
+		return 2
+	}
+	switch {
+	case kind < numTables:
+		return wide(m.rows[kind] >= 1<<16)
+	case kind == colU8:
+		return 1
+	case kind == colU16:
+		return 2
+	case kind == colU32:
+		return 4
+	case kind == colString:
+		return wide(m.wideStr)
+	case kind == colGUID:
+		return wide(m.wideGUID)
+	case kind == colBlob:
+		return wide(m.wideBlob)
+	}
+	tabs := codedTables[kind-colCoded]
+	bits := uint(0)
+	for 1<<bits < len(tabs) {
+		bits++
+	}
+	var max uint32
+	for _, t := range tabs {
+		if t >= 0 && m.rows[t] > max {
+			max = m.rows[t]
+		}
+	}
+	return wide(max >= 1<<(16-bits))
+}
+
+// cell returns column col of the 1-based row of table.
+func (m *metadata) cell(table int, row uint32, col int) uint32 {
+	if row == 0 || row > m.rows[table] || m.tables == nil {
+		return 0
+	}
+	off := m.tableOff[table] + (row-1)*m.rowSize[table] + m.colOff[table][col]
+	switch m.colSize[table][col] {
+	case 1:
+		return uint32(m.tables[off])
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(m.tables[off:]))
+	}
+	return binary.LittleEndian.Uint32(m.tables[off:])
+}
+
+// coded splits a coded index into table and 1-based row; table is -1 for
+// an unused tag.
+func (m *metadata) coded(kind int, v uint32) (int, uint32) {
+	tabs := codedTables[kind]
+	bits := uint(0)
+	for 1<<bits < len(tabs) {
+		bits++
+	}
+	tag := v & (1<<bits - 1)
+	if int(tag) >= len(tabs) {
+		return -1, 0
+	}
+	return tabs[tag], v >> bits
+}
+
+// str reads a NUL-terminated UTF-8 string from #Strings.
+func (m *metadata) str(off uint32) string {
+	if off >= uint32(len(m.strs)) {
+		return ""
+	}
+	end := off
+	for end < uint32(len(m.strs)) && m.strs[end] != 0 {
+		end++
+	}
+	return string(m.strs[off:end])
+}
+
+// guid formats a 1-based #GUID index in registry form.
+func (m *metadata) guid(idx uint32) string {
+	if idx == 0 || uint64(idx)*16 > uint64(len(m.guids)) {
+		return ""
+	}
+	g := m.guids[(idx-1)*16 : idx*16]
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]),
+		binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:16])
+}
+
+// blob reads a length-prefixed entry from #Blob.
+func (m *metadata) blob(off uint32) []byte {
+	return heapEntry(m.blobs, off)
+}
+
+// heapEntry reads an entry with an ECMA-335 compressed length prefix.
+func heapEntry(heap []byte, off uint32) []byte {
+	n, size, ok := compressedUint(heap, off)
+	if !ok || uint64(off)+uint64(size)+uint64(n) > uint64(len(heap)) {
+		return nil
+	}
+	return heap[off+size : off+size+n]
+}
+
+// userStrings walks the #US heap: each entry is a compressed length, the
+// UTF-16 characters and a trailing flag byte.
+func (m *metadata) userStrings() []string {
+	var out []string
+	for off := uint32(1); off < uint32(len(m.us)); {
+		n, size, ok := compressedUint(m.us, off)
+		if !ok || uint64(off)+uint64(size)+uint64(n) > uint64(len(m.us)) {
+			break
+		}
+		b := m.us[off+size : off+size+n]
+		off += size + n
+		if n == 0 {
+			continue // zero padding at the end of the heap
+		}
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		out = append(out, string(utf16.Decode(u)))
+	}
+	return out
+}
+
+// compressedUint decodes an ECMA-335 compressed unsigned integer and
+// returns it with its encoded size.
+func compressedUint(b []byte, off uint32) (v, size uint32, ok bool) {
+	if off >= uint32(len(b)) {
+		return 0, 0, false
+	}
+	c := uint32(b[off])
+	switch {
+	case c&0x80 == 0:
+		return c, 1, true
+	case c&0xC0 == 0x80:
+		if off+2 > uint32(len(b)) {
+			return 0, 0, false
+		}
+		return (c&0x3F)<<8 | uint32(b[off+1]), 2, true
+	case c&0xE0 == 0xC0:
+		if off+4 > uint32(len(b)) {
+			return 0, 0, false
+		}
+		return (c&0x1F)<<24 | uint32(b[off+1])<<16 | uint32(b[off+2])<<8 | uint32(b[off+3]), 4, true
+	}
+	return 0, 0, false
+}
+
+// typeName formats a TypeDef or TypeRef row as Namespace.Name, with
+// Outer/Inner for nested types where the enclosing type is known.
+func (m *metadata) typeName(table int, row uint32) string {
+	return m.typeNameDepth(table, row, 0)
+}
+
+func (m *metadata) typeNameDepth(table int, row uint32, depth int) string {
+	switch table {
+	case tblTypeDef:
+		name, ns := m.str(m.cell(tblTypeDef, row, 1)), m.str(m.cell(tblTypeDef, row, 2))
+		if outer := m.enclosing(row); outer != 0 && depth < 8 {
+			return m.typeNameDepth(tblTypeDef, outer, depth+1) + "/" + name
+		}
+		return joinTypeName(ns, name)
+	case tblTypeRef:
+		name, ns := m.str(m.cell(tblTypeRef, row, 1)), m.str(m.cell(tblTypeRef, row, 2))
+		if t, r := m.coded(cResolutionScope, m.cell(tblTypeRef, row, 0)); t == tblTypeRef && r != row && depth < 8 {
+			return m.typeNameDepth(tblTypeRef, r, depth+1) + "/" + name
+		}
+		return joinTypeName(ns, name)
+	case tblTypeSpec:
+		return fmt.Sprintf("TypeSpec#%d", row)
+	case tblModuleRef:
+		return "[" + m.str(m.cell(tblModuleRef, row, 0)) + "]"
+	case tblMethodDef:
+		return m.methodName(row)
+	}
+	return ""
+}
+
+func joinTypeName(ns, name string) string {
+	if ns == "" {
+		return name
+	}
+	return ns + "." + name
+}
+
+// enclosing returns the TypeDef row that encloses the nested TypeDef row,
+// or 0.
+func (m *metadata) enclosing(row uint32) uint32 {
+	if m.nested == nil {
+		m.nested = make(map[uint32]uint32, m.rows[tblNestedClass])
+		for i := uint32(1); i <= m.rows[tblNestedClass]; i++ {
+			m.nested[m.cell(tblNestedClass, i, 0)] = m.cell(tblNestedClass, i, 1)
+		}
+	}
+	return m.nested[row]
+}
+
+// methodOwner returns the TypeDef row whose method list holds the
+// MethodDef row. Each type owns the methods from its MethodList up to the
+// next type's.
+func (m *metadata) methodOwner(row uint32) uint32 {
+	if m.owners == nil {
+		m.owners = make([]uint32, m.rows[tblMethodDef]+1)
+		for t := uint32(1); t <= m.rows[tblTypeDef]; t++ {
+			start, end := m.methodRange(t)
+			for r := start; r < end; r++ {
+				m.owners[r] = t
+			}
+		}
+	}
+	if row >= uint32(len(m.owners)) {
+		return 0
+	}
+	return m.owners[row]
+}
+
+// methodRange returns the MethodDef rows [start, end) of a TypeDef row.
+func (m *metadata) methodRange(t uint32) (uint32, uint32) {
+	last := m.rows[tblMethodDef] + 1
+	start := m.cell(tblTypeDef, t, 5)
+	end := last
+	if t < m.rows[tblTypeDef] {
+		end = m.cell(tblTypeDef, t+1, 5)
+	}
+	if start == 0 || start > last {
+		start = last
+	}
+	if end > last {
+		end = last
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+// methodName formats a MethodDef row as Type::Method.
+func (m *metadata) methodName(row uint32) string {
+	name := m.str(m.cell(tblMethodDef, row, 3))
+	if t := m.methodOwner(row); t != 0 {
+		return m.typeName(tblTypeDef, t) + "::" + name
+	}
+	return name
+}
+
+// memberParent names the parent of a MemberRef row.
+func (m *metadata) memberParent(row uint32) string {
+	t, r := m.coded(cMemberRefParent, m.cell(tblMemberRef, row, 0))
+	return m.typeName(t, r)
+}
+
+// attributeType names the attribute class of a CustomAttribute row, whose
+// type column is the constructor.
+func (m *metadata) attributeType(row uint32) string {
+	t, r := m.coded(cCustomAttributeType, m.cell(tblCustomAttribute, row, 1))
+	switch t {
+	case tblMemberRef:
+		return m.memberParent(r)
+	case tblMethodDef:
+		if owner := m.methodOwner(r); owner != 0 {
+			return m.typeName(tblTypeDef, owner)
+		}
+	}
+	return ""
+}
+
+// attributeString returns the first fixed argument of a custom attribute
+// blob when it is a string (II.23.3: prolog 0x0001, then a SerString).
+func attributeString(b []byte) (string, bool) {
+	if len(b) < 3 || b[0] != 1 || b[1] != 0 || b[2] == 0xFF {
+		return "", false
+	}
+	s := heapEntry(b, 2)
+	if s == nil {
+		return "", false
+	}
+	return string(s), true
+}
@@ -0,0 +1,365 @@
+package peparse
+
+import (
+	"bytes"
+	"compress/flate"
+	"debug/pe"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	debugTypeCodeView             = 2
+	debugTypeFPO                  = 3
+	debugTypeVCFeature            = 12
+	debugTypePOGO                 = 13
+	debugTypeILTCG                = 14
+	debugTypeMPX                  = 15
+	debugTypeRepro                = 16
+	debugTypeEmbeddedPortablePDB  = 17
+	debugTypeExDllCharacteristics = 20
+
+	maxDebugEntries = 64
+	maxFPOEntries   = 4096
+)
+
+var debugTypeNames = map[uint32]string{
+	0:  "UNKNOWN",
+	1:  "COFF",
+	2:  "CODEVIEW",
+	3:  "FPO",
+	4:  "MISC",
+	5:  "EXCEPTION",
+	6:  "FIXUP",
+	7:  "OMAP_TO_SRC",
+	8:  "OMAP_FROM_SRC",
+	9:  "BORLAND",
+	10: "RESERVED10",
+	11: "CLSID",
+	12: "VC_FEATURE",
+	13: "POGO",
+	14: "ILTCG",
+	15: "MPX",
+	16: "REPRO",
+	17: "EMBEDDED_PORTABLE_PDB",
+	19: "PDBCHECKSUM",
+	20: "EX_DLLCHARACTERISTICS",
+}
+
+var exDllCharNames = []struct {
+	bit  uint32
+	name string
+}{
+	{0x01, "CET_COMPAT"},
+	{0x02, "CET_COMPAT_STRICT_MODE"},
+	{0x04, "CET_SET_CONTEXT_IP_VALIDATION_RELAXED_MODE"},
+	{0x08, "CET_DYNAMIC_APIS_ALLOW_IN_PROC"},
+	{0x40, "FORWARD_CFI_COMPAT"},
+	{0x80, "HOTPATCH_COMPATIBLE"},
+}
+
+// CodeViewInfo is an RSDS (PDB 7.0) or NB10 (PDB 2.0) record.
+type CodeViewInfo struct {
+	Format      string // "RSDS" or "NB10"
+	GUID        string
+	Signature   uint32 // NB10 only
+	Age         uint32
+	PDBPath     string
+	SymbolKey   string // symbol server directory name: GUID (or signature) followed by age
+	PortablePDB bool
+	User        string // user name found in the PDB path, if any
+}
+
+type POGOEntry struct {
+	RVA  uint32
+	Size uint32
+	Name string
+}
+
+// VCFeature holds the counters of the VC_FEATURE entry.
+type VCFeature struct {
+	PreVC11 uint32
+	CCpp    uint32
+	GS      uint32
+	SDL     uint32
+	GuardN  uint32
+}
+
+// FPOEntry is FPO_DATA: frame layout of a function compiled with frame
+// pointer omission.
+type FPOEntry struct {
+	Start  uint32
+	Size   uint32
+	Locals uint32 // in DWORDs
+	Params uint16 // in DWORDs
+	Prolog uint8
+	Regs   uint8
+	HasSEH bool
+	UseBP  bool
+	Frame  uint8
+}
+
+type EmbeddedPDB struct {
+	UncompressedSize uint32
+	Metadata         bool // decompressed data starts with the BSJB metadata signature
+}
+
+type DebugEntry struct {
+	Type             uint32
+	TypeName         string
+	TimeDateStamp    uint32
+	MajorVersion     uint16
+	MinorVersion     uint16
+	SizeOfData       uint32
+	AddressOfRawData uint32
+	PointerToRawData uint32
+
+	CodeView      *CodeViewInfo
+	POGOSignature string
+	POGO          []POGOEntry
+	VCFeature     *VCFeature
+	ReproHash     string
+	ExDllChars    uint32
+	ExDllNames    []string
+	FPO           []FPOEntry
+	EmbeddedPDB   *EmbeddedPDB
+	Note          string
+}
+
+type DebugReport struct {
+	Entries []DebugEntry
+	Note    string
+}
+
+// CodeView returns the first CodeView record, or nil.
+func (d DebugReport) CodeView() *CodeViewInfo {
+	for _, e := range d.Entries {
+		if e.CodeView != nil {
+			return e.CodeView
+		}
+	}
+	return nil
+}
+
+func parseDebug(f *pe.File, as *addrSpace, bin []byte) DebugReport {
+	var r DebugReport
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_DEBUG)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return r
+	}
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "debug directory " + err.Error()
+		return r
+	}
+	n := dir.Size / 28
+	if n > maxDebugEntries {
+		r.Note = fmt.Sprintf("debug directory truncated to %d entries", maxDebugEntries)
+		n = maxDebugEntries
+	}
+	data := readMemPrefix(as, bin, dir.VirtualAddress, n*28)
+	for i := uint32(0); i < n; i++ {
+		p := i * 28
+		if int(p)+28 > len(data) {
+			r.Note = appendNote(r.Note, "debug directory extends past end of file")
+			break
+		}
+		e := DebugEntry{}
+		e.TimeDateStamp, _ = readU32(data, p+4)
+		e.MajorVersion, _ = readU16(data, p+8)
+		e.MinorVersion, _ = readU16(data, p+10)
+		e.Type, _ = readU32(data, p+12)
+		e.SizeOfData, _ = readU32(data, p+16)
+		e.AddressOfRawData, _ = readU32(data, p+20)
+		e.PointerToRawData, _ = readU32(data, p+24)
+		e.TypeName = debugTypeNames[e.Type]
+		if e.TypeName == "" {
+			e.TypeName = fmt.Sprintf("type %d", e.Type)
+		}
+		decodeDebugEntry(&e, debugData(as, bin, e))
+		r.Entries = append(r.Entries, e)
+	}
+	return r
+}
+
+// debugData returns the raw data of an entry, preferring the file offset
+// and falling back to the RVA.
+func debugData(as *addrSpace, bin []byte, e DebugEntry) []byte {
+	start := e.PointerToRawData
+	if start == 0 || uint64(start)+uint64(e.SizeOfData) > uint64(len(bin)) {
+		if _, ok := rvaToOff(as, e.AddressOfRawData); e.AddressOfRawData == 0 || !ok {
+			return nil
+		}
+		return readMemPrefix(as, bin, e.AddressOfRawData, e.SizeOfData)
+	}
+	end := uint64(start) + uint64(e.SizeOfData)
+	if end > uint64(len(bin)) {
+		end = uint64(len(bin))
+	}
+	return bin[start:end]
+}
+
+func decodeDebugEntry(e *DebugEntry, b []byte) {
+	if b == nil && e.Type != debugTypeILTCG && e.Type != debugTypeRepro {
+		e.Note = "data not present in file"
+		return
+	}
+	switch e.Type {
+	case debugTypeCodeView:
+		e.CodeView = parseCodeView(b)
+		if e.CodeView == nil {
+			e.Note = "unrecognised CodeView signature"
+		} else if e.MajorVersion == 0x0100 && e.MinorVersion == 0x504D {
+			e.CodeView.PortablePDB = true
+		}
+	case debugTypePOGO:
+		if len(b) >= 4 {
+			// "LTCG", "PGI", "PGU", or zero for a plain build.
+			if sig := strings.TrimRight(string(b[:4]), "\x00"); isPrintableASCII(sig) && sig != "" {
+				e.POGOSignature = sig
+			} else {
+				v, _ := readU32(b, 0)
+				e.POGOSignature = fmt.Sprintf("0x%08X", v)
+			}
+			for p := 4; p+9 <= len(b); {
+				rva, _ := readU32(b, uint32(p))
+				size, _ := readU32(b, uint32(p+4))
+				name := b[p+8:]
+				if i := bytes.IndexByte(name, 0); i >= 0 {
+					name = name[:i]
+				}
+				e.POGO = append(e.POGO, POGOEntry{RVA: rva, Size: size, Name: string(name)})
+				p += (8 + len(name) + 1 + 3) &^ 3
+			}
+		}
+	case debugTypeVCFeature:
+		if len(b) >= 20 {
+			v := &VCFeature{}
+			v.PreVC11, _ = readU32(b, 0)
+			v.CCpp, _ = readU32(b, 4)
+			v.GS, _ = readU32(b, 8)
+			v.SDL, _ = readU32(b, 12)
+			v.GuardN, _ = readU32(b, 16)
+			e.VCFeature = v
+		}
+	case debugTypeRepro:
+		// Either empty (the timestamps are hash-derived) or a length-prefixed hash.
+		if n, ok := readU32(b, 0); ok && uint64(n)+4 <= uint64(len(b)) {
+			e.ReproHash = hex.EncodeToString(b[4 : 4+n])
+		}
+	case debugTypeExDllCharacteristics:
+		e.ExDllChars, _ = readU32(b, 0)
+		for _, c := range exDllCharNames {
+			if e.ExDllChars&c.bit != 0 {
+				e.ExDllNames = append(e.ExDllNames, c.name)
+			}
+		}
+	case debugTypeFPO:
+		for p := 0; p+16 <= len(b) && len(e.FPO) < maxFPOEntries; p += 16 {
+			fpo := FPOEntry{}
+			fpo.Start, _ = readU32(b, uint32(p))
+			fpo.Size, _ = readU32(b, uint32(p+4))
+			fpo.Locals, _ = readU32(b, uint32(p+8))
+			fpo.Params, _ = readU16(b, uint32(p+12))
+			bits, _ := readU16(b, uint32(p+14))
+			fpo.Prolog = uint8(bits)
+			fpo.Regs = uint8(bits>>8) & 7
+			fpo.HasSEH = bits&(1<<11) != 0
+			fpo.UseBP = bits&(1<<12) != 0
+			fpo.Frame = uint8(bits >> 14)
+			e.FPO = append(e.FPO, fpo)
+		}
+		if len(b)/16 > maxFPOEntries {
+			e.Note = fmt.Sprintf("FPO listing limited to %d entries", maxFPOEntries)
+		}
+	case debugTypeEmbeddedPortablePDB:
+		// "MPDB", uncompressed size, then a raw deflate stream.
+		if len(b) < 8 || string(b[:4]) != "MPDB" {
+			e.Note = "missing MPDB signature"
+			return
+		}
+		pdb := &EmbeddedPDB{}
+		pdb.UncompressedSize, _ = readU32(b, 4)
+		head := make([]byte, 4)
+		if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(b[8:])), head); err == nil {
+			pdb.Metadata = string(head) == "BSJB"
+		}
+		e.EmbeddedPDB = pdb
+	case debugTypeMPX, debugTypeILTCG:
+		// No payload worth decoding; the entry itself records the build option.
+	}
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+var pdbUserRe = regexp.MustCompile(`(?i)(?:^|[\\/])(?:users|documents and settings|home)[\\/]([^\\/]+)[\\/]`)
+
+func parseCodeView(b []byte) *CodeViewInfo {
+	if len(b) < 4 {
+		return nil
+	}
+	cstr := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	}
+	var cv *CodeViewInfo
+	switch string(b[:4]) {
+	case "RSDS":
+		if len(b) < 24 {
+			return nil
+		}
+		d1, _ := readU32(b, 4)
+		d2, _ := readU16(b, 8)
+		d3, _ := readU16(b, 10)
+		d4 := strings.ToUpper(hex.EncodeToString(b[12:20]))
+		age, _ := readU32(b, 20)
+		cv = &CodeViewInfo{
+			Format:    "RSDS",
+			GUID:      fmt.Sprintf("{%08X-%04X-%04X-%s-%s}", d1, d2, d3, d4[:4], d4[4:]),
+			Age:       age,
+			PDBPath:   cstr(b[24:]),
+			SymbolKey: fmt.Sprintf("%08X%04X%04X%s%X", d1, d2, d3, d4, age),
+		}
+	case "NB10":
+		if len(b) < 16 {
+			return nil
+		}
+		sig, _ := readU32(b, 8)
+		age, _ := readU32(b, 12)
+		cv = &CodeViewInfo{
+			Format:    "NB10",
+			Signature: sig,
+			Age:       age,
+			PDBPath:   cstr(b[16:]),
+			SymbolKey: fmt.Sprintf("%08X%X", sig, age),
+		}
+	default:
+		return nil
+	}
+	if m := pdbUserRe.FindStringSubmatch(cv.PDBPath); m != nil {
+		cv.User = m[1]
+	}
+	return cv
+}
+
+// exDllCharacteristics returns the extended DllCharacteristics stored in
+// an IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS debug directory entry.
+func exDllCharacteristics(d DebugReport) (uint32, bool) {
+	for _, e := range d.Entries {
+		if e.Type == debugTypeExDllCharacteristics {
+			return e.ExDllChars, true
+		}
+	}
+	return 0, false
+}
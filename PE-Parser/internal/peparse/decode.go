@@ -0,0 +1,380 @@
+package peparse
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxDecodeDepth    = 3
+	maxDecodedResults = 500
+	maxBruteForce     = 2000
+	maxBruteForceLen  = 512
+	previewLen        = 120
+)
+
+var (
+	reB64Cand    = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+	reB64URLCand = regexp.MustCompile(`[A-Za-z0-9_-]{16,}={0,2}`)
+	reB32Cand    = regexp.MustCompile(`[A-Z2-7]{16,}={0,6}`)
+	reHexCand    = regexp.MustCompile(`(?:[0-9A-Fa-f]{2}){8,}`)
+	reURLEncCand = regexp.MustCompile(`(?:[^\s%]*%[0-9A-Fa-f]{2}){3,}[^\s%]*`)
+)
+
+// Lower-case markers that make a brute-forced XOR/ADD candidate worth keeping.
+// Printable output alone is not enough: XOR 0x20 of any text is printable, and
+// short markers such as ".com" show up by chance in long XORed text.
+var decodeKeywords = []string{
+	"http://", "https://", "cmd.exe", "powershell", "hkey_", "software\\microsoft",
+	"\\pipe\\", "mozilla/", "user-agent:", "kernel32.dll", "ntdll.dll", "virtualalloc",
+	"loadlibrary", "getprocaddress", "createprocess", "this program cannot",
+}
+
+type blobDecoder struct {
+	minLen    int
+	seen      map[string]bool
+	items     []DecodedBlob
+	bruteLeft int
+	truncated bool
+}
+
+func decodeBlobs(in []sourcedString, minLen int) DecodedReport {
+	if minLen < 4 {
+		minLen = 4
+	}
+	d := &blobDecoder{minLen: minLen, seen: make(map[string]bool), bruteLeft: maxBruteForce}
+	for _, s := range in {
+		if d.full() {
+			break
+		}
+		d.text(s.Text, s.Source, nil, 0)
+	}
+	r := DecodedReport{Items: d.items}
+	if d.truncated {
+		r.Note = fmt.Sprintf("stopped after %d decoded blobs", maxDecodedResults)
+	}
+	return r
+}
+
+func (d *blobDecoder) full() bool {
+	if len(d.items) >= maxDecodedResults {
+		d.truncated = true
+		return true
+	}
+	return false
+}
+
+func (d *blobDecoder) text(text, source string, chain []string, depth int) {
+	if depth >= maxDecodeDepth || len(text) < 16 {
+		return
+	}
+	for _, m := range reB64Cand.FindAllString(text, -1) {
+		if !mixedClasses(m) {
+			continue
+		}
+		if strings.HasSuffix(m, "=") {
+			m = alignPaddedBase64(m)
+		}
+		if out, ok := decodeBase64(m, false); ok {
+			d.result(m, out, source, appendChain(chain, "base64"), depth)
+		}
+	}
+	for _, m := range reB64URLCand.FindAllString(text, -1) {
+		if strings.ContainsAny(m, "-_") && mixedClasses(m) {
+			if out, ok := decodeBase64(m, true); ok {
+				d.result(m, out, source, appendChain(chain, "base64url"), depth)
+			}
+		}
+	}
+	for _, m := range reB32Cand.FindAllString(text, -1) {
+		if len(m)%8 == 0 && strings.ContainsAny(m, "234567") {
+			if out, err := base32.StdEncoding.DecodeString(m); err == nil {
+				d.result(m, out, source, appendChain(chain, "base32"), depth)
+			}
+		}
+	}
+	for _, m := range reHexCand.FindAllString(text, -1) {
+		if !strings.ContainsAny(m, "abcdefABCDEF") {
+			continue
+		}
+		if out, err := hex.DecodeString(m); err == nil {
+			d.result(m, out, source, appendChain(chain, "hex"), depth)
+		}
+	}
+	if strings.Contains(text, "%") {
+		for _, m := range reURLEncCand.FindAllString(text, -1) {
+			if out, err := url.PathUnescape(m); err == nil && out != m {
+				d.result(m, []byte(out), source, appendChain(chain, "urldecode"), depth)
+			}
+		}
+	}
+	if depth == 0 && len(text) >= 32 && len(text) <= maxBruteForceLen && shannonEntropy([]byte(text)) >= 4.0 {
+		d.bruteForce([]byte(text), text, source, chain, depth)
+	}
+}
+
+func (d *blobDecoder) result(input string, out []byte, source string, chain []string, depth int) {
+	if d.full() || len(out) == 0 || d.seen[string(out)] {
+		return
+	}
+	magic, _ := detectMagic(out)
+	printable := looksLikeText(out)
+	if magic == "" && !(printable && len(out) >= d.minLen) {
+		if len(out) >= 16 && len(out) <= maxBruteForceLen {
+			d.bruteForce(out, input, source, chain, depth+1)
+		}
+		return
+	}
+	d.keep(input, out, magic, source, chain)
+	if printable {
+		d.text(string(out), source, chain, depth+1)
+		return
+	}
+	for _, s := range d.items[len(d.items)-1].Strings {
+		d.text(s, source, chain, depth+1)
+	}
+}
+
+func (d *blobDecoder) keep(input string, out []byte, magic, source string, chain []string) {
+	d.seen[string(out)] = true
+	var strs []string
+	if looksLikeText(out) {
+		strs = []string{string(bytes.TrimRight(out, "\x00"))}
+	} else {
+		strs = append(extractStrings(out, d.minLen), extractWideStrings(out, d.minLen)...)
+	}
+	d.items = append(d.items, DecodedBlob{
+		Source:  source,
+		Input:   truncateText(input, previewLen),
+		Chain:   chain,
+		Size:    len(out),
+		Magic:   magic,
+		Preview: previewBytes(out, previewLen),
+		Strings: strs,
+	})
+}
+
+// bruteForce tries every single-byte XOR and ADD key. Results are kept only
+// when they carry strong file magic or gain a keyword the input did not have.
+func (d *blobDecoder) bruteForce(b []byte, input, source string, chain []string, depth int) {
+	if d.bruteLeft <= 0 || depth >= maxDecodeDepth {
+		return
+	}
+	d.bruteLeft--
+	lowerIn := strings.ToLower(string(b))
+	out := make([]byte, len(b))
+	for op := 0; op < 2; op++ {
+		for k := 1; k < 256; k++ {
+			for i, c := range b {
+				if op == 0 {
+					out[i] = c ^ byte(k)
+				} else {
+					out[i] = c + byte(k)
+				}
+			}
+			_, strong := detectMagic(out)
+			if !strong && !gainsKeyword(out, lowerIn) {
+				continue
+			}
+			name := fmt.Sprintf("xor(0x%02X)", k)
+			if op == 1 {
+				name = fmt.Sprintf("add(0x%02X)", k)
+			}
+			res := make([]byte, len(out))
+			copy(res, out)
+			d.result(input, res, source, appendChain(chain, name), depth)
+			if d.full() {
+				return
+			}
+		}
+	}
+}
+
+func gainsKeyword(out []byte, lowerIn string) bool {
+	if !looksLikeText(out) {
+		return false
+	}
+	lower := strings.ToLower(string(out))
+	for _, kw := range decodeKeywords {
+		if strings.Contains(lower, kw) && !strings.Contains(lowerIn, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendChain(chain []string, step string) []string {
+	out := make([]string, len(chain), len(chain)+1)
+	copy(out, chain)
+	return append(out, step)
+}
+
+func decodeBase64(s string, urlSafe bool) ([]byte, bool) {
+	enc, raw := base64.StdEncoding, base64.RawStdEncoding
+	if urlSafe {
+		enc, raw = base64.URLEncoding, base64.RawURLEncoding
+	}
+	if strings.HasSuffix(s, "=") || len(s)%4 == 0 {
+		b, err := enc.DecodeString(s)
+		return b, err == nil
+	}
+	b, err := raw.DecodeString(s)
+	return b, err == nil
+}
+
+// alignPaddedBase64 handles padded Base64 glued to preceding text: the
+// padding anchors the right edge, so the longest suffix that decodes to text
+// is taken as the real blob.
+func alignPaddedBase64(m string) string {
+	m = m[len(m)%4:]
+	for start := 0; len(m)-start >= 16; start += 4 {
+		if out, err := base64.StdEncoding.DecodeString(m[start:]); err == nil && looksLikeText(out) {
+			return m[start:]
+		}
+	}
+	return m
+}
+
+// mixedClasses filters out identifiers and words that merely fit the Base64
+// alphabet by requiring at least upper, lower and digit characters together.
+func mixedClasses(s string) bool {
+	var up, low, dig bool
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z':
+			up = true
+		case c >= 'a' && c <= 'z':
+			low = true
+		case c >= '0' && c <= '9':
+			dig = true
+		}
+	}
+	return up && low && (dig || strings.HasSuffix(s, "="))
+}
+
+// detectMagic names well-known file signatures. strong is false for short
+// signatures that random data hits too easily to trust after brute forcing.
+func detectMagic(b []byte) (name string, strong bool) {
+	switch {
+	case bytes.HasPrefix(b, []byte("MZ")):
+		if len(b) >= 0x40 {
+			lfanew := binary.LittleEndian.Uint32(b[0x3C:])
+			if uint64(lfanew)+4 <= uint64(len(b)) && bytes.Equal(b[lfanew:lfanew+4], []byte("PE\x00\x00")) {
+				return "PE", true
+			}
+		}
+		if bytes.Contains(b, []byte("This program cannot be run")) {
+			return "PE (MZ stub)", true
+		}
+		return "MZ", false
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return "ZIP", true
+	case bytes.HasPrefix(b, []byte("%PDF-")):
+		return "PDF", true
+	case bytes.HasPrefix(b, []byte("7z\xBC\xAF\x27\x1C")):
+		return "7z", true
+	case bytes.HasPrefix(b, []byte("Rar!\x1A\x07")):
+		return "RAR", true
+	case bytes.HasPrefix(b, []byte("\x7FELF")):
+		return "ELF", true
+	case bytes.HasPrefix(b, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")):
+		return "OLE", true
+	case bytes.HasPrefix(b, []byte("MSCF\x00\x00\x00\x00")):
+		return "CAB", true
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1A\n")):
+		return "PNG", true
+	case bytes.HasPrefix(b, []byte("\x1F\x8B\x08")):
+		return "GZIP", false
+	case bytes.HasPrefix(b, []byte("GIF8")):
+		return "GIF", false
+	case bytes.HasPrefix(b, []byte("\xFF\xD8\xFF")):
+		return "JPEG", false
+	case bytes.HasPrefix(b, []byte(`{\rtf`)):
+		return "RTF", false
+	case bytes.Contains(b, []byte("This program cannot be run in DOS mode")):
+		return "PE (embedded)", true
+	}
+	return "", false
+}
+
+func looksLikeText(b []byte) bool {
+	if printableRatio(b) < 0.95 {
+		return false
+	}
+	var distinct [256]bool
+	n, letters := 0, 0
+	for _, c := range b {
+		if !distinct[c] {
+			distinct[c] = true
+			n++
+		}
+		if (c|0x20) >= 'a' && (c|0x20) <= 'z' {
+			letters++
+		}
+	}
+	return n >= 3 && letters > 0
+}
+
+func printableRatio(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	n := 0
+	for _, c := range b {
+		if (c >= 0x20 && c <= 0x7E) || c == '\t' || c == '\r' || c == '\n' {
+			n++
+		}
+	}
+	return float64(n) / float64(len(b))
+}
+
+func shannonEntropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(b))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+func previewBytes(b []byte, max int) string {
+	if len(b) > max {
+		b = b[:max]
+	}
+	var sb strings.Builder
+	for _, c := range b {
+		if c >= 0x20 && c <= 0x7E {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteByte('.')
+		}
+	}
+	return sb.String()
+}
+
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
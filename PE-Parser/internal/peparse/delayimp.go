@@ -0,0 +1,168 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	dlattrRVA       = 0x1 // delay descriptor holds RVAs, not VAs
+	maxDelayDLLs    = 256
+	maxBoundImports = 256
+	delayDescSize   = 32
+	boundDescSize   = 8
+)
+
+type DelayImportDLL struct {
+	Name            string
+	Host            string
+	Attributes      uint32
+	RVABased        bool
+	ModuleHandleRVA uint32
+	IATRVA          uint32
+	INTRVA          uint32
+	BoundIATRVA     uint32
+	UnloadIATRVA    uint32
+	TimeDateStamp   uint32
+	Functions       []ImportFunc
+}
+
+type BoundForwarder struct {
+	Name          string
+	TimeDateStamp uint32
+}
+
+type BoundImport struct {
+	Name          string
+	TimeDateStamp uint32
+	Forwarders    []BoundForwarder
+}
+
+// parseDelayImports reads IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT. Descriptors
+// without the RVA attribute come from old linkers and hold VAs.
+func parseDelayImports(f *pe.File, as *addrSpace, bin []byte, is64 bool, imageBase uint64) ([]DelayImportDLL, string) {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, ""
+	}
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		return nil, "delay import directory " + err.Error()
+	}
+	var out []DelayImportDLL
+	for i := 0; i < maxDelayDLLs; i++ {
+		desc, ok := readMem(as, bin, dir.VirtualAddress+uint32(i*delayDescSize), delayDescSize)
+		if !ok {
+			break
+		}
+		var v [8]uint32
+		for j := range v {
+			v[j] = binary.LittleEndian.Uint32(desc[j*4:])
+		}
+		if v[1] == 0 && v[3] == 0 && v[4] == 0 {
+			break
+		}
+		d := DelayImportDLL{
+			Attributes:    v[0],
+			RVABased:      v[0]&dlattrRVA != 0,
+			TimeDateStamp: v[7],
+		}
+		// Legacy descriptors store VAs; convert everything to RVAs.
+		conv := func(x uint32) uint32 {
+			if d.RVABased || x == 0 || uint64(x) < imageBase {
+				return x
+			}
+			return uint32(uint64(x) - imageBase)
+		}
+		nameBase := uint64(0)
+		if !d.RVABased {
+			nameBase = imageBase
+		}
+		d.ModuleHandleRVA = conv(v[2])
+		d.IATRVA = conv(v[3])
+		d.INTRVA = conv(v[4])
+		d.BoundIATRVA = conv(v[5])
+		d.UnloadIATRVA = conv(v[6])
+		name, ok := readCStringRVA(as, bin, conv(v[1]))
+		if !ok || name == "" {
+			name = "<unknown>"
+		}
+		d.Name = strings.ToLower(name)
+		if d.INTRVA != 0 {
+			d.Functions = readThunks(as, bin, d.INTRVA, d.IATRVA, is64, nameBase, imageBase)
+		}
+		out = append(out, d)
+	}
+	return out, ""
+}
+
+// parseBoundImports reads IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT. The table
+// usually sits in the header area after the section table, and module
+// names are offsets from the start of the table.
+func parseBoundImports(f *pe.File, as *addrSpace, bin []byte) ([]BoundImport, string) {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, ""
+	}
+	base, err := resolveRVA(as, dir.VirtualAddress)
+	if err != nil {
+		return nil, "bound import directory " + err.Error()
+	}
+	end := base + dir.Size
+	if int(end) > len(bin) {
+		end = uint32(len(bin))
+	}
+	name := func(o uint16) string {
+		p := base + uint32(o)
+		if p >= end {
+			return "<unknown>"
+		}
+		i := p
+		for i < end && bin[i] != 0 {
+			i++
+		}
+		return strings.ToLower(string(bin[p:i]))
+	}
+	var out []BoundImport
+	for off := base; off+boundDescSize <= end && len(out) < maxBoundImports; {
+		ts := binary.LittleEndian.Uint32(bin[off:])
+		nameOff := binary.LittleEndian.Uint16(bin[off+4:])
+		nfwd := binary.LittleEndian.Uint16(bin[off+6:])
+		if ts == 0 && nameOff == 0 && nfwd == 0 {
+			break
+		}
+		b := BoundImport{Name: name(nameOff), TimeDateStamp: ts}
+		off += boundDescSize
+		for i := uint16(0); i < nfwd && off+boundDescSize <= end; i++ {
+			b.Forwarders = append(b.Forwarders, BoundForwarder{
+				Name:          name(binary.LittleEndian.Uint16(bin[off+4:])),
+				TimeDateStamp: binary.LittleEndian.Uint32(bin[off:]),
+			})
+			off += boundDescSize
+		}
+		out = append(out, b)
+	}
+	return out, ""
+}
+
+func dataDirectory(f *pe.File, idx int) pe.DataDirectory {
+	_, oh32, oh64 := getOptional(f)
+	switch {
+	case oh32 != nil:
+		return oh32.DataDirectory[idx]
+	case oh64 != nil:
+		return oh64.DataDirectory[idx]
+	}
+	return pe.DataDirectory{}
+}
+
+func headerSize(f *pe.File) uint32 {
+	_, oh32, oh64 := getOptional(f)
+	switch {
+	case oh32 != nil:
+		return oh32.SizeOfHeaders
+	case oh64 != nil:
+		return oh64.SizeOfHeaders
+	}
+	return 0
+}
@@ -0,0 +1,376 @@
+package peparse
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDepNodes   = 5000
+	maxForwardHop = 8
+)
+
+// DepModule is one node of the dependency tree. A module that was already
+// expanded elsewhere in the tree is listed again with Repeat set and no
+// import children.
+type DepModule struct {
+	Name         string
+	Host         string // API-set host, if Name is an API set
+	Path         string
+	Delay        bool
+	Forward      bool // reached through a forwarded export
+	Missing      bool
+	Repeat       bool
+	MissingFuncs []string
+	Note         string
+	Children     []*DepModule
+}
+
+type DepTree struct {
+	Root           *DepModule
+	Dirs           []string
+	MissingModules []string
+	MissingFuncs   int
+	Note           string
+}
+
+// depImage is what the resolver needs from one module on disk.
+type depImage struct {
+	imports []ImportDLL
+	delay   []DelayImportDLL
+	exports exportTable
+	err     error
+}
+
+type depResolver struct {
+	dirs    []string
+	files   map[string]string // lower-case file name -> path, first directory wins
+	apiSets *APISetResolver
+	images  map[string]*depImage
+	done    map[string]bool
+	missing map[string]bool
+	nodes   int
+	depth   int
+	tree    *DepTree
+}
+
+// BuildDeps resolves the imports of the file at path against dirs, which
+// are searched in order after the directory of the file itself.
+func BuildDeps(path string, dirs []string, apiSets *APISetResolver, maxDepth int) *DepTree {
+	if apiSets == nil {
+		apiSets = NewAPISetResolver()
+	}
+	search := append([]string{filepath.Dir(path)}, dirs...)
+	t := &DepTree{Dirs: search}
+	d := &depResolver{
+		dirs:    search,
+		files:   make(map[string]string),
+		apiSets: apiSets,
+		images:  make(map[string]*depImage),
+		done:    make(map[string]bool),
+		missing: make(map[string]bool),
+		depth:   maxDepth,
+		tree:    t,
+	}
+	for _, dir := range search {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Note = appendNote(t.Note, err.Error())
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			key := strings.ToLower(e.Name())
+			if _, ok := d.files[key]; !ok {
+				d.files[key] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+
+	t.Root = &DepModule{Name: filepath.Base(path), Path: path}
+	d.expand(t.Root, nil, 0, 0)
+	for name := range d.missing {
+		t.MissingModules = append(t.MissingModules, name)
+	}
+	sort.Strings(t.MissingModules)
+	if d.nodes >= maxDepNodes {
+		t.Note = appendNote(t.Note, fmt.Sprintf("tree truncated at %d modules", maxDepNodes))
+	}
+	return t
+}
+
+func appendNote(note, s string) string {
+	if note == "" {
+		return s
+	}
+	return note + "; " + s
+}
+
+func (d *depResolver) image(path string) *depImage {
+	if img, ok := d.images[path]; ok {
+		return img
+	}
+	img := &depImage{}
+	d.images[path] = img
+	f, data, _, err := openImage(path)
+	if err != nil {
+		img.err = err
+		return img
+	}
+	defer f.Close()
+	as := newAddrSpace(f)
+	is64, oh32, oh64 := getOptional(f)
+	var imageBase uint64
+	switch {
+	case oh32 != nil:
+		imageBase = uint64(oh32.ImageBase)
+	case oh64 != nil:
+		imageBase = oh64.ImageBase
+	}
+	img.imports = parseImports(f, as, data, is64, imageBase).DLLs
+	img.delay, _ = parseDelayImports(f, as, data, is64, imageBase)
+	img.exports = newExportTable(parseExports(f, as, data))
+	return img
+}
+
+// locate maps an imported name to a file, going through the API-set
+// schema first.
+func (d *depResolver) locate(m *DepModule) bool {
+	name := m.Name
+	if IsAPISet(name) {
+		m.Host = d.apiSets.Resolve(name)
+		if m.Host == "" {
+			m.Note = "unknown API set"
+			return false
+		}
+		name = m.Host
+	}
+	key := strings.ToLower(name)
+	if filepath.Ext(key) == "" {
+		key += ".dll"
+	}
+	p, ok := d.files[key]
+	if ok {
+		m.Path = p
+	}
+	return ok
+}
+
+// expand checks funcs against the exports of m and recurses into its
+// imports. Forwarded exports become children of their own; hops counts the
+// forwarders followed to reach m.
+func (d *depResolver) expand(m *DepModule, funcs []ImportFunc, level, hops int) {
+	d.nodes++
+	if m.Path == "" && !d.locate(m) {
+		m.Missing = true
+		d.missing[strings.ToLower(m.Name)] = true
+		for _, fn := range funcs {
+			m.MissingFuncs = append(m.MissingFuncs, fn.Name)
+		}
+		d.tree.MissingFuncs += len(funcs)
+		return
+	}
+	img := d.image(m.Path)
+	if img.err != nil {
+		m.Note = img.err.Error()
+		return
+	}
+
+	forwards := make(map[string][]ImportFunc)
+	var fwdOrder []string
+	for _, fn := range funcs {
+		fwd, ok := img.exports.lookup(fn)
+		if !ok {
+			m.MissingFuncs = append(m.MissingFuncs, fn.Name)
+			d.tree.MissingFuncs++
+			continue
+		}
+		if fwd == "" || hops >= maxForwardHop {
+			continue
+		}
+		mod, target := splitForwarder(fwd)
+		if _, seen := forwards[mod]; !seen {
+			fwdOrder = append(fwdOrder, mod)
+		}
+		forwards[mod] = append(forwards[mod], target)
+	}
+	for _, mod := range fwdOrder {
+		if d.nodes >= maxDepNodes {
+			break
+		}
+		c := &DepModule{Name: mod, Forward: true}
+		m.Children = append(m.Children, c)
+		d.expand(c, forwards[mod], level+1, hops+1)
+	}
+
+	key := strings.ToLower(m.Path)
+	if d.done[key] {
+		m.Repeat = m != d.tree.Root
+		return
+	}
+	d.done[key] = true
+	if d.depth > 0 && level >= d.depth {
+		return
+	}
+	for _, imp := range img.imports {
+		if d.nodes >= maxDepNodes {
+			return
+		}
+		c := &DepModule{Name: imp.Name}
+		m.Children = append(m.Children, c)
+		d.expand(c, imp.Functions, level+1, 0)
+	}
+	for _, imp := range img.delay {
+		if d.nodes >= maxDepNodes {
+			return
+		}
+		c := &DepModule{Name: imp.Name, Delay: true}
+		m.Children = append(m.Children, c)
+		d.expand(c, imp.Functions, level+1, 0)
+	}
+}
+
+// splitForwarder turns "NTDLL.RtlAllocateHeap" or "NTDLL.#12" into the
+// module file name and an import record.
+func splitForwarder(fwd string) (string, ImportFunc) {
+	i := strings.LastIndexByte(fwd, '.')
+	if i < 0 {
+		return strings.ToLower(fwd), ImportFunc{}
+	}
+	mod := strings.ToLower(fwd[:i]) + ".dll"
+	fn := ImportFunc{Name: fwd[i+1:]}
+	if strings.HasPrefix(fn.Name, "#") {
+		if n, err := strconv.Atoi(fn.Name[1:]); err == nil {
+			fn.ByOrdinal = true
+			fn.Ordinal = uint16(n)
+		}
+	}
+	return mod, fn
+}
+
+// exportTable indexes the exports of a module, as parseExports reads
+// them, by name and by ordinal.
+type exportTable struct {
+	names    map[string]ExportSymbol
+	ordinals map[uint16]ExportSymbol
+}
+
+func newExportTable(r ExportReport) exportTable {
+	t := exportTable{names: make(map[string]ExportSymbol), ordinals: make(map[uint16]ExportSymbol)}
+	for _, sym := range r.Symbols {
+		if !sym.OrdinalOnly {
+			t.names[sym.Name] = sym
+		}
+		t.ordinals[sym.Ordinal] = sym
+	}
+	return t
+}
+
+// lookup reports whether fn is exported and, if so, its forwarder string.
+func (t exportTable) lookup(fn ImportFunc) (string, bool) {
+	sym, ok := t.names[fn.Name]
+	if fn.ByOrdinal {
+		sym, ok = t.ordinals[fn.Ordinal]
+	}
+	return sym.Forwarder, ok
+}
+
+// PrintConsole prints the tree with one module per line.
+func (t *DepTree) PrintConsole() {
+	fmt.Printf("[+] Dependencies of %s\n", t.Root.Name)
+	fmt.Printf("    Search path: %s\n", strings.Join(t.Dirs, ", "))
+	var walk func(m *DepModule, indent string)
+	walk = func(m *DepModule, indent string) {
+		fmt.Printf("%s%s\n", indent, m.label())
+		if len(m.MissingFuncs) > 0 {
+			fmt.Printf("%s    missing: %s\n", indent, strings.Join(m.MissingFuncs, ", "))
+		}
+		for _, c := range m.Children {
+			walk(c, indent+"  ")
+		}
+	}
+	walk(t.Root, "  ")
+	fmt.Printf("\nMissing modules (%d): %s\n", len(t.MissingModules), strings.Join(t.MissingModules, ", "))
+	fmt.Printf("Missing functions: %d\n", t.MissingFuncs)
+	if t.Note != "" {
+		fmt.Println("Note:", t.Note)
+	}
+}
+
+// label is the one-line console form of a module.
+func (m *DepModule) label() string {
+	s := m.Name
+	if m.Host != "" {
+		s += " -> " + m.Host
+	}
+	var tags []string
+	if m.Delay {
+		tags = append(tags, "delay")
+	}
+	if m.Forward {
+		tags = append(tags, "forwarded")
+	}
+	if m.Missing {
+		tags = append(tags, "MISSING")
+	}
+	if m.Repeat {
+		tags = append(tags, "see above")
+	}
+	if m.Note != "" {
+		tags = append(tags, m.Note)
+	}
+	if len(tags) > 0 {
+		s += "  [" + strings.Join(tags, ", ") + "]"
+	}
+	return s
+}
+
+// WriteDOT writes one node per module; missing modules are red, delay-load
+// edges dashed and forwarder edges dotted.
+func (t *DepTree) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph deps {\n  rankdir=LR;\n  node [shape=box, fontname=\"monospace\"];\n")
+	nodes := make(map[string]bool)
+	edges := make(map[string]bool)
+	var walk func(m *DepModule)
+	walk = func(m *DepModule) {
+		id := strings.ToLower(m.Name)
+		if !nodes[id] {
+			nodes[id] = true
+			attr := ""
+			if m.Missing {
+				attr = ", color=red, fontcolor=red"
+			} else if len(m.MissingFuncs) > 0 {
+				attr = ", color=orange"
+			}
+			fmt.Fprintf(&sb, "  %q [label=%q%s];\n", id, m.Name, attr)
+		}
+		for _, c := range m.Children {
+			cid := strings.ToLower(c.Name)
+			style := ""
+			switch {
+			case c.Delay:
+				style = " [style=dashed]"
+			case c.Forward:
+				style = " [style=dotted]"
+			}
+			e := fmt.Sprintf("  %q -> %q%s;\n", id, cid, style)
+			if !edges[e] {
+				edges[e] = true
+				sb.WriteString(e)
+			}
+			walk(c)
+		}
+	}
+	walk(t.Root)
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,238 @@
+package peparse
+
+import (
+	"debug/pe"
+	"fmt"
+
+	"PE-Parser/internal/disasm"
+)
+
+const maxExportListings = 64
+
+type DisasmLine struct {
+	VA      uint64
+	RVA     uint32
+	Bytes   string
+	Text    string
+	Comment string
+}
+
+type DisasmListing struct {
+	Label string
+	RVA   uint32
+	VA    uint64
+	Lines []DisasmLine
+	Note  string
+}
+
+type DisasmReport struct {
+	Listings []DisasmListing
+	Note     string
+}
+
+// disasmContext holds what the listings need to annotate operands: names
+// for IAT slots and exported functions, keyed by VA.
+type disasmContext struct {
+	f         *pe.File
+	as        *addrSpace
+	bin       []byte
+	bits      int
+	imageBase uint64
+	iat       map[uint64]string
+	exports   map[uint64]string
+	symbols   map[uint64]string // code symbols from a PDB
+	thunks    map[uint64]string // cache for thunkTarget
+}
+
+// newDisasmContext returns nil with a note for machines the decoder does not
+// support.
+func newDisasmContext(f *pe.File, as *addrSpace, bin []byte, r *Report) (*disasmContext, string) {
+	bits := 32
+	switch f.FileHeader.Machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		bits = 64
+	default:
+		return nil, fmt.Sprintf("disassembly supports x86/x64 only (machine 0x%04X)", f.FileHeader.Machine)
+	}
+	ctx := &disasmContext{
+		f:         f,
+		as:        as,
+		bin:       bin,
+		bits:      bits,
+		imageBase: r.Header.ImageBaseVA,
+		iat:       iatSlots(r.Imports),
+		exports:   make(map[uint64]string),
+		symbols:   make(map[uint64]string),
+		thunks:    make(map[uint64]string),
+	}
+	for _, s := range r.Exports.Symbols {
+		if s.Forwarder != "" {
+			continue
+		}
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", s.Ordinal)
+		}
+		ctx.exports[ctx.imageBase+uint64(s.RVA)] = name
+	}
+	for rva, name := range r.Symbols.CodeNames() {
+		ctx.symbols[ctx.imageBase+uint64(rva)] = name
+	}
+	return ctx, ""
+}
+
+func buildDisasm(f *pe.File, as *addrSpace, bin []byte, r *Report, count int) DisasmReport {
+	var out DisasmReport
+	if count <= 0 {
+		return out
+	}
+	ctx, note := newDisasmContext(f, as, bin, r)
+	if ctx == nil {
+		out.Note = note
+		return out
+	}
+
+	if r.Header.EntryPointRVA != 0 {
+		out.Listings = append(out.Listings, ctx.listing("Entry point", r.Header.EntryPointRVA, count))
+	}
+	for i, rva := range tlsCallbacks(f, as, bin) {
+		out.Listings = append(out.Listings, ctx.listing(fmt.Sprintf("TLS callback %d", i), rva, count))
+	}
+
+	n := 0
+	for _, s := range r.Exports.Symbols {
+		if s.Forwarder != "" {
+			continue // forwarder string, not code
+		}
+		if n == maxExportListings {
+			out.Note = fmt.Sprintf("export listings limited to the first %d exports", maxExportListings)
+			break
+		}
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", s.Ordinal)
+		}
+		out.Listings = append(out.Listings, ctx.listing("Export "+name, s.RVA, count))
+		n++
+	}
+	return out
+}
+
+// iatSlots maps the VA of every IAT slot to "dll!function".
+func iatSlots(imp ImportReport) map[uint64]string {
+	m := make(map[uint64]string)
+	add := func(dll string, funcs []ImportFunc) {
+		for _, fn := range funcs {
+			if fn.IATRVA != 0 {
+				m[fn.IATVA] = dll + "!" + fn.Name
+			}
+		}
+	}
+	for _, d := range imp.DLLs {
+		add(d.Name, d.Functions)
+	}
+	for _, d := range imp.Delay {
+		add(d.Name, d.Functions)
+	}
+	return m
+}
+
+// codeAt returns the file-backed bytes from rva to where its region's raw
+// data ends, as the loader maps them.
+func codeAt(as *addrSpace, bin []byte, rva uint32) []byte {
+	off, n, err := as.span(rva)
+	if err != nil || uint64(off) >= uint64(len(bin)) {
+		return nil
+	}
+	end := uint64(off) + uint64(n)
+	if end > uint64(len(bin)) {
+		end = uint64(len(bin))
+	}
+	return bin[off:end]
+}
+
+func (c *disasmContext) listing(label string, rva uint32, count int) DisasmListing {
+	l := DisasmListing{Label: label, RVA: rva, VA: c.imageBase + uint64(rva)}
+	code := codeAt(c.as, c.bin, rva)
+	if len(code) == 0 {
+		l.Note = "RVA is not backed by file data"
+		return l
+	}
+	va := l.VA
+	for i := 0; i < count && len(code) > 0; i++ {
+		inst, err := disasm.Decode(code, va, c.bits)
+		if err != nil {
+			l.Lines = append(l.Lines, DisasmLine{VA: va, RVA: uint32(va - c.imageBase), Bytes: fmt.Sprintf("%02X", code[0]), Text: fmt.Sprintf("db 0x%02x", code[0]), Comment: err.Error()})
+			break
+		}
+		l.Lines = append(l.Lines, DisasmLine{
+			VA:      va,
+			RVA:     uint32(va - c.imageBase),
+			Bytes:   fmt.Sprintf("% X", inst.Bytes),
+			Text:    inst.String(),
+			Comment: c.annotate(inst),
+		})
+		code = code[inst.Len:]
+		va += uint64(inst.Len)
+		// int3 padding after a ret/jmp marks the end of the function.
+		if (inst.Flow == disasm.FlowRet || inst.Flow == disasm.FlowJump) && len(code) > 0 && code[0] == 0xCC {
+			break
+		}
+	}
+	return l
+}
+
+func (c *disasmContext) annotate(inst disasm.Inst) string {
+	if inst.HasMem {
+		if name, ok := c.iat[inst.Mem]; ok {
+			return name
+		}
+		if name, ok := c.exports[inst.Mem]; ok {
+			return name
+		}
+		if name, ok := c.symbols[inst.Mem]; ok {
+			return name
+		}
+	}
+	if inst.HasTarget {
+		if name, ok := c.exports[inst.Target]; ok {
+			return name
+		}
+		sym := c.symbols[inst.Target]
+		if name := c.thunkTarget(inst.Target); name != "" {
+			if sym != "" {
+				return sym + " -> " + name
+			}
+			return "-> " + name
+		}
+		if sym != "" {
+			return sym
+		}
+	}
+	if inst.HasImm && c.bits == 32 {
+		// push offset / mov reg, offset of an IAT slot in 32-bit code
+		if name, ok := c.iat[inst.Imm]; ok {
+			return "&" + name
+		}
+	}
+	return ""
+}
+
+// thunkTarget resolves an import thunk, i.e. a `jmp [IAT slot]` stub.
+func (c *disasmContext) thunkTarget(va uint64) string {
+	if name, ok := c.thunks[va]; ok {
+		return name
+	}
+	name := ""
+	if va >= c.imageBase && va-c.imageBase < c.as.end() {
+		if code := codeAt(c.as, c.bin, uint32(va-c.imageBase)); len(code) > 0 {
+			inst, err := disasm.Decode(code, va, c.bits)
+			if err == nil && inst.Flow == disasm.FlowJump && inst.Indirect && inst.HasMem {
+				name = c.iat[inst.Mem]
+			}
+		}
+	}
+	c.thunks[va] = name
+	return name
+}
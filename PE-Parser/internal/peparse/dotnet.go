@@ -0,0 +1,465 @@
+package peparse
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	comImageILOnly           = 0x1
+	comImage32BitRequired    = 0x2
+	comImageILLibrary        = 0x4
+	comImageStrongNameSigned = 0x8
+	comImageNativeEntryPoint = 0x10
+	comImageTrackDebugData   = 0x10000
+	comImage32BitPreferred   = 0x20000
+
+	maxDotNetAnomalies = 32
+	maxConsoleTypes    = 40
+)
+
+type DotNetAssemblyRef struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Culture        string `json:"culture,omitempty"`
+	PublicKeyToken string `json:"public_key_token,omitempty"`
+}
+
+type DotNetMethod struct {
+	Token uint32   `json:"token"`
+	Name  string   `json:"name"`
+	RVA   uint32   `json:"rva,omitempty"`
+	Flags []string `json:"flags,omitempty"`
+}
+
+type DotNetType struct {
+	Token      uint32         `json:"token"`
+	Name       string         `json:"name"` // Namespace.Name, Outer/Inner for nested types
+	Visibility string         `json:"visibility"`
+	Flags      []string       `json:"flags,omitempty"`
+	Extends    string         `json:"extends,omitempty"`
+	Methods    []DotNetMethod `json:"methods,omitempty"`
+}
+
+type DotNetMemberRef struct {
+	Parent string `json:"parent"`
+	Name   string `json:"name"`
+}
+
+type DotNetResource struct {
+	Name   string `json:"name"`
+	Public bool   `json:"public"`
+	Offset uint32 `json:"offset"`
+	Size   uint32 `json:"size,omitempty"`
+	Source string `json:"source"` // "embedded", or the file or assembly holding it
+}
+
+// DotNetPInvoke is one ImplMap row: a managed method bound to a native
+// export.
+type DotNetPInvoke struct {
+	Method     string   `json:"method"`
+	DLL        string   `json:"dll"`
+	EntryPoint string   `json:"entry_point"`
+	Flags      []string `json:"flags,omitempty"`
+}
+
+type DotNetStream struct {
+	Name   string `json:"name"`
+	Offset uint32 `json:"offset"`
+	Size   uint32 `json:"size"`
+}
+
+type DotNetReport struct {
+	Present         bool                `json:"present"`
+	RuntimeVersion  string              `json:"runtime_version"`  // CLR header version, e.g. 2.5
+	MetadataVersion string              `json:"metadata_version"` // version string of the metadata root
+	TargetFramework string              `json:"target_framework,omitempty"`
+	Flags           []string            `json:"flags,omitempty"`
+	EntryPoint      string              `json:"entry_point,omitempty"`
+	Module          string              `json:"module,omitempty"`
+	MVID            string              `json:"mvid,omitempty"`
+	Assembly        *DotNetAssemblyRef  `json:"assembly,omitempty"`
+	References      []DotNetAssemblyRef `json:"references,omitempty"`
+	Streams         []DotNetStream      `json:"streams,omitempty"`
+	Tables          map[string]uint32   `json:"tables,omitempty"`
+	TypeRefs        []string            `json:"type_refs,omitempty"`
+	Types           []DotNetType        `json:"types,omitempty"`
+	MemberRefs      []DotNetMemberRef   `json:"member_refs,omitempty"`
+	Resources       []DotNetResource    `json:"resources,omitempty"`
+	Methods         int                 `json:"methods"`
+	PInvokes        []DotNetPInvoke     `json:"pinvokes,omitempty"`
+	UserStrings     []string            `json:"user_strings,omitempty"`
+	Ranked          []RankedString      `json:"ranked_user_strings,omitempty"`
+	RankNote        string              `json:"rank_note,omitempty"`
+	Obfuscators     []DotNetObfuscator  `json:"obfuscators,omitempty"`
+	TypeRefHash     string              `json:"typeref_hash,omitempty"`
+	Anomalies       []string            `json:"anomalies,omitempty"`
+	Note            string              `json:"note,omitempty"`
+}
+
+// WriteJSON writes the full .NET metadata report.
+func (d DotNetReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+var typeVisibility = [8]string{"internal", "public", "nested public", "nested private",
+	"nested protected", "nested internal", "nested private protected", "nested protected internal"}
+
+var methodAccess = [8]string{"compilercontrolled", "private", "private protected", "internal",
+	"protected", "protected internal", "public", "?"}
+
+// r2rOSOverrides are the values ReadyToRun images XOR into Machine when
+// they are compiled for an operating system other than Windows.
+var r2rOSOverrides = map[string]uint16{"Apple": 0x4644, "FreeBSD": 0xADC4, "Linux": 0x7B79, "NetBSD": 0x1993, "SunOS": 0x1992}
+
+// r2rOS returns the operating system a ReadyToRun Machine value was
+// compiled for, or "" if machine is not an overridden one.
+func r2rOS(machine uint16) string {
+	for name, v := range r2rOSOverrides {
+		switch machine ^ v {
+		case pe.IMAGE_FILE_MACHINE_I386, pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_MACHINE_ARMNT,
+			pe.IMAGE_FILE_MACHINE_ARM64, pe.IMAGE_FILE_MACHINE_LOONGARCH64, pe.IMAGE_FILE_MACHINE_RISCV64:
+			return name
+		}
+	}
+	return ""
+}
+
+// parseDotNet reads the CLR header (IMAGE_COR20_HEADER) and the metadata it
+// points to. User strings shorter than minLen are dropped.
+func parseDotNet(f *pe.File, as *addrSpace, bin []byte, minLen int) DotNetReport {
+	var r DotNetReport
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return r
+	}
+	r.Present = true
+	hdr, ok := readMem(as, bin, dir.VirtualAddress, 72)
+	if !ok {
+		if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+			r.Note = "CLR header " + err.Error()
+		} else {
+			r.Note = "CLR header extends past end of file"
+		}
+		return r
+	}
+	major, _ := readU16(hdr, 4)
+	minor, _ := readU16(hdr, 6)
+	r.RuntimeVersion = fmt.Sprintf("%d.%d", major, minor)
+	mdRVA, _ := readU32(hdr, 8)
+	mdSize, _ := readU32(hdr, 12)
+	flags, _ := readU32(hdr, 16)
+	entry, _ := readU32(hdr, 20)
+	resRVA, _ := readU32(hdr, 24)
+	resSize, _ := readU32(hdr, 28)
+	nativeHdr, _ := readU32(hdr, 64)
+
+	for _, fl := range []struct {
+		bit  uint32
+		name string
+	}{
+		{comImageILOnly, "ILONLY"}, {comImage32BitRequired, "32BITREQUIRED"}, {comImageILLibrary, "IL_LIBRARY"},
+		{comImageStrongNameSigned, "STRONGNAMESIGNED"}, {comImageNativeEntryPoint, "NATIVE_ENTRYPOINT"},
+		{comImageTrackDebugData, "TRACKDEBUGDATA"}, {comImage32BitPreferred, "32BITPREFERRED"},
+	} {
+		if flags&fl.bit != 0 {
+			r.Flags = append(r.Flags, fl.name)
+		}
+	}
+	if nativeHdr != 0 {
+		r.Flags = append(r.Flags, "READYTORUN")
+		lfanew, _ := readU32(bin, 0x3C)
+		if m, _ := readU16(bin, lfanew+4); m != f.FileHeader.Machine {
+			if os := r2rOS(m); os != "" {
+				r.Flags = append(r.Flags, "TARGET_OS_"+strings.ToUpper(os))
+			}
+		}
+	}
+
+	flag := func(format string, args ...interface{}) {
+		if len(r.Anomalies) < maxDotNetAnomalies {
+			r.Anomalies = append(r.Anomalies, fmt.Sprintf(format, args...))
+		}
+	}
+
+	md, ok := readMem(as, bin, mdRVA, mdSize)
+	if mdRVA == 0 || !ok {
+		r.Note = fmt.Sprintf("metadata at RVA 0x%08X (%d bytes) is not readable", mdRVA, mdSize)
+		return r
+	}
+	m, err := parseMetadata(md)
+	if m == nil {
+		r.Note = "metadata: " + err.Error()
+		return r
+	}
+	r.MetadataVersion = m.version
+	for _, s := range m.streams {
+		r.Streams = append(r.Streams, DotNetStream{Name: s.name, Offset: s.off, Size: s.size})
+		if uint64(s.off)+uint64(s.size) > uint64(mdSize) {
+			flag("stream %s extends past the metadata", s.name)
+		}
+	}
+	if err != nil {
+		r.Note = "metadata: " + err.Error()
+		return r
+	}
+
+	r.Tables = make(map[string]uint32)
+	for i, n := range m.rows {
+		if n != 0 {
+			r.Tables[tableNames[i]] = n
+		}
+	}
+	r.Methods = int(m.rows[tblMethodDef])
+
+	if m.rows[tblModule] > 0 {
+		r.Module = m.str(m.cell(tblModule, 1, 1))
+		r.MVID = m.guid(m.cell(tblModule, 1, 2))
+	}
+	if m.rows[tblModule] != 1 {
+		flag("Module table has %d rows", m.rows[tblModule])
+	}
+	if m.rows[tblAssembly] > 0 {
+		a := assemblyRef(m, tblAssembly, 1)
+		r.Assembly = &a
+	}
+	if m.rows[tblAssembly] > 1 {
+		flag("Assembly table has %d rows", m.rows[tblAssembly])
+	}
+	for i := uint32(1); i <= m.rows[tblAssemblyRef]; i++ {
+		r.References = append(r.References, assemblyRef(m, tblAssemblyRef, i))
+	}
+	for i := uint32(1); i <= m.rows[tblTypeRef]; i++ {
+		r.TypeRefs = append(r.TypeRefs, m.typeName(tblTypeRef, i))
+	}
+	for i := uint32(1); i <= m.rows[tblMemberRef]; i++ {
+		r.MemberRefs = append(r.MemberRefs, DotNetMemberRef{Parent: m.memberParent(i), Name: m.str(m.cell(tblMemberRef, i, 1))})
+	}
+	r.Types = dotNetTypes(as, m, flag)
+	r.TargetFramework = targetFramework(m)
+
+	switch {
+	case flags&comImageNativeEntryPoint != 0:
+		r.EntryPoint = fmt.Sprintf("native RVA 0x%08X", entry)
+	case entry>>24 == tblMethodDef && entry&0xFFFFFF <= m.rows[tblMethodDef] && entry&0xFFFFFF != 0:
+		r.EntryPoint = m.methodName(entry & 0xFFFFFF)
+	case entry>>24 == tblFile:
+		r.EntryPoint = fmt.Sprintf("in file %s", m.str(m.cell(tblFile, entry&0xFFFFFF, 1)))
+	case entry != 0:
+		flag("entry point token 0x%08X is not a method", entry)
+	}
+
+	for i := uint32(1); i <= m.rows[tblManifestResource]; i++ {
+		res := DotNetResource{
+			Offset: m.cell(tblManifestResource, i, 0),
+			Public: m.cell(tblManifestResource, i, 1)&7 == 1,
+			Name:   m.str(m.cell(tblManifestResource, i, 2)),
+			Source: "embedded",
+		}
+		if t, row := m.coded(cImplementation, m.cell(tblManifestResource, i, 3)); row != 0 {
+			switch t {
+			case tblFile:
+				res.Source = "file " + m.str(m.cell(tblFile, row, 1))
+			case tblAssemblyRef:
+				res.Source = "assembly " + m.str(m.cell(tblAssemblyRef, row, 6))
+			}
+		} else if res.Offset+4 <= resSize {
+			// Embedded resources are a length-prefixed blob in the
+			// Resources area of the CLR header.
+			if n, ok := memU32(as, bin, resRVA+res.Offset); ok {
+				res.Size = n
+				if uint64(res.Offset)+4+uint64(n) > uint64(resSize) {
+					flag("resource %s extends past the resources area", res.Name)
+				}
+			}
+		} else {
+			flag("resource %s is outside the resources area", res.Name)
+		}
+		r.Resources = append(r.Resources, res)
+	}
+
+	for _, u := range m.userStrings() {
+		if len([]rune(strings.TrimSpace(u))) >= minLen {
+			r.UserStrings = append(r.UserStrings, u)
+		}
+	}
+	r.PInvokes = dotNetPInvokes(m)
+	r.TypeRefHash = typeRefHash(m)
+	r.Obfuscators = detectObfuscators(m, r)
+	return r
+}
+
+// dotNetPInvokes lists the ImplMap table.
+func dotNetPInvokes(m *metadata) []DotNetPInvoke {
+	var out []DotNetPInvoke
+	for i := uint32(1); i <= m.rows[tblImplMap]; i++ {
+		fl := m.cell(tblImplMap, i, 0)
+		p := DotNetPInvoke{
+			EntryPoint: m.str(m.cell(tblImplMap, i, 2)),
+			DLL:        m.str(m.cell(tblModuleRef, m.cell(tblImplMap, i, 3), 0)),
+		}
+		switch t, row := m.coded(cMemberForwarded, m.cell(tblImplMap, i, 1)); t {
+		case tblMethodDef:
+			p.Method = m.methodName(row)
+		case tblField:
+			p.Method = m.str(m.cell(tblField, row, 1))
+		}
+		if cs := []string{"", "ansi", "unicode", "auto"}[fl>>1&3]; cs != "" {
+			p.Flags = append(p.Flags, cs)
+		}
+		if fl&0x40 != 0 {
+			p.Flags = append(p.Flags, "SetLastError")
+		}
+		if fl&0x1 != 0 {
+			p.Flags = append(p.Flags, "ExactSpelling")
+		}
+		switch fl & 0x700 {
+		case 0x200:
+			p.Flags = append(p.Flags, "cdecl")
+		case 0x300:
+			p.Flags = append(p.Flags, "stdcall")
+		case 0x400:
+			p.Flags = append(p.Flags, "thiscall")
+		case 0x500:
+			p.Flags = append(p.Flags, "fastcall")
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// typeRefHash computes the TypeRef hash (TRH): the SHA-256 of the
+// "Namespace-Name" pairs of the TypeRef table, sorted and joined by
+// commas. Builds of the same code share it even when the table order
+// changes.
+func typeRefHash(m *metadata) string {
+	if m.rows[tblTypeRef] == 0 {
+		return ""
+	}
+	refs := make([]string, 0, m.rows[tblTypeRef])
+	for i := uint32(1); i <= m.rows[tblTypeRef]; i++ {
+		refs = append(refs, m.str(m.cell(tblTypeRef, i, 2))+"-"+m.str(m.cell(tblTypeRef, i, 1)))
+	}
+	sort.Strings(refs)
+	sum := sha256.Sum256([]byte(strings.Join(refs, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// assemblyRef reads the identity columns shared by the Assembly and
+// AssemblyRef tables.
+func assemblyRef(m *metadata, table int, row uint32) DotNetAssemblyRef {
+	verCol, keyCol, nameCol := 1, 6, 7
+	if table == tblAssemblyRef {
+		verCol, keyCol, nameCol = 0, 5, 6
+	}
+	a := DotNetAssemblyRef{
+		Name: m.str(m.cell(table, row, nameCol)),
+		Version: fmt.Sprintf("%d.%d.%d.%d", m.cell(table, row, verCol), m.cell(table, row, verCol+1),
+			m.cell(table, row, verCol+2), m.cell(table, row, verCol+3)),
+		Culture: m.str(m.cell(table, row, nameCol+1)),
+	}
+	key := m.blob(m.cell(table, row, keyCol))
+	switch {
+	case len(key) == 8:
+		a.PublicKeyToken = hex.EncodeToString(key)
+	case len(key) > 8:
+		// A full public key (afPublicKey): the token is the last 8 bytes
+		// of its SHA-1, reversed.
+		sum := sha1.Sum(key)
+		tok := make([]byte, 8)
+		for i := range tok {
+			tok[i] = sum[len(sum)-1-i]
+		}
+		a.PublicKeyToken = hex.EncodeToString(tok)
+	}
+	return a
+}
+
+// dotNetTypes builds the type and method inventory from TypeDef and
+// MethodDef.
+func dotNetTypes(as *addrSpace, m *metadata, flag func(string, ...interface{})) []DotNetType {
+	var out []DotNetType
+	for t := uint32(1); t <= m.rows[tblTypeDef]; t++ {
+		fl := m.cell(tblTypeDef, t, 0)
+		dt := DotNetType{
+			Token:      tblTypeDef<<24 | t,
+			Name:       m.typeName(tblTypeDef, t),
+			Visibility: typeVisibility[fl&7],
+		}
+		if fl&0x20 != 0 {
+			dt.Flags = append(dt.Flags, "interface")
+		}
+		if fl&0x80 != 0 {
+			dt.Flags = append(dt.Flags, "abstract")
+		}
+		if fl&0x100 != 0 {
+			dt.Flags = append(dt.Flags, "sealed")
+		}
+		if et, er := m.coded(cTypeDefOrRef, m.cell(tblTypeDef, t, 3)); er != 0 {
+			dt.Extends = m.typeName(et, er)
+		}
+		start, end := m.methodRange(t)
+		for i := start; i < end; i++ {
+			dt.Methods = append(dt.Methods, dotNetMethod(as, m, i, flag))
+		}
+		out = append(out, dt)
+	}
+	return out
+}
+
+func dotNetMethod(as *addrSpace, m *metadata, row uint32, flag func(string, ...interface{})) DotNetMethod {
+	rva := m.cell(tblMethodDef, row, 0)
+	impl := m.cell(tblMethodDef, row, 1)
+	fl := m.cell(tblMethodDef, row, 2)
+	dm := DotNetMethod{Token: tblMethodDef<<24 | row, Name: m.str(m.cell(tblMethodDef, row, 3)), RVA: rva}
+	dm.Flags = append(dm.Flags, methodAccess[fl&7])
+	for _, b := range []struct {
+		bit  uint32
+		name string
+	}{{0x10, "static"}, {0x40, "virtual"}, {0x400, "abstract"}, {0x2000, "pinvoke"}} {
+		if fl&b.bit != 0 {
+			dm.Flags = append(dm.Flags, b.name)
+		}
+	}
+	switch impl & 3 {
+	case 1:
+		dm.Flags = append(dm.Flags, "native")
+	case 3:
+		dm.Flags = append(dm.Flags, "runtime")
+	}
+	if impl&0x1000 != 0 {
+		dm.Flags = append(dm.Flags, "internalcall")
+	}
+	if rva != 0 {
+		if s := sectionAt(as, rva); s == nil {
+			flag("method %s body RVA 0x%08X outside all sections", dm.Name, rva)
+		}
+	}
+	return dm
+}
+
+// targetFramework reads the TargetFrameworkAttribute on the assembly, e.g.
+// ".NETCoreApp,Version=v8.0".
+func targetFramework(m *metadata) string {
+	for i := uint32(1); i <= m.rows[tblCustomAttribute]; i++ {
+		if t, _ := m.coded(cHasCustomAttribute, m.cell(tblCustomAttribute, i, 0)); t != tblAssembly {
+			continue
+		}
+		if m.attributeType(i) != "System.Runtime.Versioning.TargetFrameworkAttribute" {
+			continue
+		}
+		if s, ok := attributeString(m.blob(m.cell(tblCustomAttribute, i, 2))); ok {
+			return s
+		}
+	}
+	return ""
+}
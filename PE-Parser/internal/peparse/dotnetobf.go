@@ -0,0 +1,129 @@
+package peparse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DotNetObfuscator is an obfuscator or packer identified from metadata,
+// with what gave it away.
+type DotNetObfuscator struct {
+	Name     string   `json:"name"`
+	Evidence []string `json:"evidence"`
+}
+
+var (
+	reGUIDSuffixType = regexp.MustCompile(`^(<PrivateImplementationDetails>|<Module>)\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}$`)
+	reBracedGUID     = regexp.MustCompile(`^\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}$`)
+)
+
+// detectObfuscators checks custom attributes, type and method names and
+// manifest resource names against the marks ConfuserEx, .NET Reactor,
+// SmartAssembly, Eazfuscator and Babel leave behind. Generic signs of
+// obfuscation are reported only when no specific tool matched.
+func detectObfuscators(m *metadata, r DotNetReport) []DotNetObfuscator {
+	hits := make(map[string][]string)
+	order := []string{"ConfuserEx", ".NET Reactor", "SmartAssembly", "Eazfuscator.NET", "Babel"}
+	add := func(name, format string, args ...interface{}) {
+		hits[name] = append(hits[name], fmt.Sprintf(format, args...))
+	}
+
+	var generic []string
+	seenAttr := make(map[string]bool)
+	for i := uint32(1); i <= m.rows[tblCustomAttribute]; i++ {
+		full := m.attributeType(i)
+		if seenAttr[full] {
+			continue
+		}
+		seenAttr[full] = true
+		short := full[strings.LastIndexAny(full, "./")+1:]
+		ev := "attribute " + full
+		if v, ok := attributeString(m.blob(m.cell(tblCustomAttribute, i, 2))); ok && v != "" {
+			ev += fmt.Sprintf(" (%q)", v)
+		}
+		switch {
+		case short == "ConfusedByAttribute":
+			add("ConfuserEx", "%s", ev)
+		case full == "SmartAssembly.Attributes.PoweredByAttribute":
+			add("SmartAssembly", "%s", ev)
+		case short == "BabelAttribute" || short == "BabelObfuscatorAttribute":
+			add("Babel", "attribute %s", full)
+		case full == "System.Runtime.CompilerServices.SuppressIldasmAttribute":
+			generic = append(generic, "SuppressIldasmAttribute blocks ildasm")
+		}
+	}
+
+	var eaz, odd, names int
+	var eazSample string
+	check := func(name string) {
+		names++
+		if strings.HasPrefix(name, "#=") {
+			eaz++
+			if eazSample == "" {
+				eazSample = name
+			}
+		}
+		if unreadableName(name) {
+			odd++
+		}
+	}
+	for _, t := range r.Types {
+		leaf := t.Name[strings.LastIndexAny(t.Name, "./")+1:]
+		check(leaf)
+		for _, mt := range t.Methods {
+			check(mt.Name)
+		}
+		switch {
+		case reGUIDSuffixType.MatchString(t.Name):
+			add(".NET Reactor", "type %s", t.Name)
+		case strings.HasPrefix(t.Name, "SmartAssembly."):
+			add("SmartAssembly", "type %s", t.Name)
+		case leaf == "ConfusedByAttribute":
+			add("ConfuserEx", "type %s", t.Name)
+		case leaf == "BabelAttribute" || leaf == "BabelObfuscatorAttribute":
+			add("Babel", "type %s", t.Name)
+		}
+	}
+	if eaz > 0 {
+		add("Eazfuscator.NET", "%d type/method names start with #= (e.g. %s)", eaz, eazSample)
+	}
+	if r.Module == "koi" {
+		add("ConfuserEx", "module named koi (packer stub)")
+	}
+	for _, res := range r.Resources {
+		switch {
+		case res.Name == "koi":
+			add("ConfuserEx", "resource koi (packed module)")
+		case reBracedGUID.MatchString(res.Name):
+			add("SmartAssembly", "resource named by GUID %s", res.Name)
+		}
+	}
+
+	var out []DotNetObfuscator
+	for _, name := range order {
+		if ev := hits[name]; len(ev) > 0 {
+			out = append(out, DotNetObfuscator{Name: name, Evidence: ev})
+		}
+	}
+	if names >= 10 && odd*10 >= names {
+		generic = append(generic, fmt.Sprintf("%d of %d type/method names are unprintable or non-ASCII", odd, names))
+	}
+	if len(out) == 0 && len(generic) > 0 {
+		out = append(out, DotNetObfuscator{Name: "unidentified", Evidence: generic})
+	}
+	return out
+}
+
+// unreadableName reports names renamers produce: invisible or control
+// characters, or letters outside ASCII (ConfuserEx and Babel use
+// private-use and zero-width code points).
+func unreadableName(name string) bool {
+	for _, c := range name {
+		if c > unicode.MaxASCII || unicode.IsControl(c) {
+			return true
+		}
+	}
+	return false
+}
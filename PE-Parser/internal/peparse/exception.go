@@ -0,0 +1,689 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	unwFlagEHandler  = 0x1
+	unwFlagUHandler  = 0x2
+	unwFlagChainInfo = 0x4
+
+	maxExceptionAnomalies = 32
+	maxChainDepth         = 32
+	maxScopeRecords       = 1024
+)
+
+var x64Regs = [16]string{"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi",
+	"r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15"}
+
+// ScopeRecord is one entry of the scope table passed to
+// __C_specific_handler. Handler is a filter RVA, 1 for __except(1), or the
+// __finally block when Target is 0.
+type ScopeRecord struct {
+	Begin   uint32 `json:"begin"`
+	End     uint32 `json:"end"`
+	Handler uint32 `json:"handler"`
+	Target  uint32 `json:"target"`
+}
+
+// UnwindFunction is one .pdata entry with its decoded unwind data.
+type UnwindFunction struct {
+	Begin       uint32        `json:"begin"`
+	End         uint32        `json:"end"`
+	UnwindRVA   uint32        `json:"unwind_rva"`
+	Version     uint8         `json:"version"`
+	Flags       []string      `json:"flags,omitempty"`
+	PrologSize  uint32        `json:"prolog_size"`
+	FrameReg    string        `json:"frame_reg,omitempty"`
+	FrameOffset uint32        `json:"frame_offset,omitempty"`
+	StackSize   uint32        `json:"stack_size"`
+	SavedRegs   []string      `json:"saved_regs,omitempty"`
+	Codes       []string      `json:"codes,omitempty"`
+	Epilogs     int           `json:"epilogs,omitempty"`
+	ChainedTo   uint32        `json:"chained_to,omitempty"` // BeginAddress of the parent entry
+	HandlerRVA  uint32        `json:"handler_rva,omitempty"`
+	Handler     string        `json:"handler,omitempty"`
+	HandlerData uint32        `json:"handler_data_rva,omitempty"`
+	Scopes      []ScopeRecord `json:"scopes,omitempty"`
+}
+
+// HandlerUse counts the functions that share a language-specific handler.
+type HandlerUse struct {
+	RVA       uint32 `json:"rva"`
+	Name      string `json:"name,omitempty"`
+	Functions int    `json:"functions"`
+}
+
+type ExceptionReport struct {
+	Machine   string           `json:"machine"`
+	DirRVA    uint32           `json:"dir_rva"`
+	DirSize   uint32           `json:"dir_size"`
+	Functions []UnwindFunction `json:"functions"`
+	Chained   int              `json:"chained"`
+	Handlers  []HandlerUse     `json:"handlers,omitempty"`
+	Anomalies []string         `json:"anomalies,omitempty"`
+	Note      string           `json:"note,omitempty"`
+}
+
+// WriteJSON writes the full function table.
+func (e ExceptionReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// seeds returns the start of every entry that begins a function rather than
+// continuing one (chained x64 entries, ARM64 fragments).
+func (e ExceptionReport) seeds() []uint32 {
+	var out []uint32
+	for _, fn := range e.Functions {
+		if fn.ChainedTo != 0 || containsString(fn.Flags, "fragment") {
+			continue
+		}
+		out = append(out, fn.Begin)
+	}
+	return out
+}
+
+// parseException walks IMAGE_DIRECTORY_ENTRY_EXCEPTION. x64 entries are
+// RUNTIME_FUNCTIONs with UNWIND_INFO; ARM64 entries carry either packed
+// unwind data or the RVA of an .xdata record.
+func parseException(f *pe.File, as *addrSpace, bin []byte) ExceptionReport {
+	var r ExceptionReport
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return r
+	}
+	r.DirRVA, r.DirSize = dir.VirtualAddress, dir.Size
+
+	stride := uint32(0)
+	switch f.FileHeader.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		r.Machine, stride = "x64", 12
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		r.Machine, stride = "ARM64", 8
+	default:
+		r.Note = fmt.Sprintf("exception data is decoded for x64 and ARM64 only (machine 0x%04X)", f.FileHeader.Machine)
+		return r
+	}
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "exception directory " + err.Error()
+		return r
+	}
+	if dir.Size%stride != 0 {
+		r.Note = fmt.Sprintf("directory size %d is not a multiple of the %d-byte entry", dir.Size, stride)
+	}
+
+	anomalies := make(map[string]int)
+	var order []string
+	flag := func(format string, args ...interface{}) {
+		s := fmt.Sprintf(format, args...)
+		if anomalies[s] == 0 {
+			order = append(order, s)
+		}
+		anomalies[s]++
+	}
+
+	var prevBegin, prevEnd uint32
+	handlers := make(map[uint32]int)
+	for i := uint32(0); i+stride <= dir.Size; i += stride {
+		raw, ok := readMem(as, bin, dir.VirtualAddress+i, stride)
+		if !ok {
+			r.Note = appendNote(r.Note, "exception directory extends past end of file")
+			break
+		}
+		var fn UnwindFunction
+		fn.Begin = binary.LittleEndian.Uint32(raw)
+		if fn.Begin == 0 {
+			continue
+		}
+		if stride == 12 {
+			fn.End = binary.LittleEndian.Uint32(raw[4:])
+			fn.UnwindRVA = binary.LittleEndian.Uint32(raw[8:])
+			decodeX64Unwind(as, bin, &fn, flag)
+		} else {
+			fn.UnwindRVA = binary.LittleEndian.Uint32(raw[4:])
+			decodeARM64Unwind(as, bin, &fn, flag)
+		}
+
+		if fn.End <= fn.Begin {
+			flag("function end does not follow its start")
+		}
+		if fn.Begin < prevBegin {
+			flag("entries are not sorted by start address")
+		} else if fn.Begin < prevEnd {
+			flag("overlapping function ranges")
+		}
+		prevBegin, prevEnd = fn.Begin, fn.End
+		if s := sectionAt(as, fn.Begin); s == nil || s.Characteristics&scnMemExecute == 0 {
+			flag("function start outside executable sections")
+		}
+		if fn.HandlerRVA != 0 {
+			if s := sectionAt(as, fn.HandlerRVA); s == nil || s.Characteristics&scnMemExecute == 0 {
+				flag("exception handler outside executable sections")
+			}
+			handlers[fn.HandlerRVA]++
+		}
+		if fn.ChainedTo != 0 {
+			r.Chained++
+		}
+		r.Functions = append(r.Functions, fn)
+	}
+
+	for rva, n := range handlers {
+		r.Handlers = append(r.Handlers, HandlerUse{RVA: rva, Functions: n})
+	}
+	sort.Slice(r.Handlers, func(i, j int) bool {
+		if r.Handlers[i].Functions != r.Handlers[j].Functions {
+			return r.Handlers[i].Functions > r.Handlers[j].Functions
+		}
+		return r.Handlers[i].RVA < r.Handlers[j].RVA
+	})
+	for i, s := range order {
+		if i == maxExceptionAnomalies {
+			r.Anomalies = append(r.Anomalies, fmt.Sprintf("%d more kinds of anomaly", len(order)-i))
+			break
+		}
+		if n := anomalies[s]; n > 1 {
+			s = fmt.Sprintf("%s (x%d)", s, n)
+		}
+		r.Anomalies = append(r.Anomalies, s)
+	}
+	return r
+}
+
+// decodeX64Unwind reads the UNWIND_INFO of fn, following chained entries to
+// find the function they belong to.
+func decodeX64Unwind(as *addrSpace, bin []byte, fn *UnwindFunction, flag func(string, ...interface{})) {
+	rva := fn.UnwindRVA
+	if rva&1 != 0 {
+		// Old linkers point a chained entry straight at its parent's
+		// RUNTIME_FUNCTION and mark it with bit 0.
+		parent, ok := memU32(as, bin, rva&^1)
+		if !ok {
+			flag("unwind info outside the image")
+			return
+		}
+		fn.ChainedTo = parent
+		fn.Flags = append(fn.Flags, "CHAININFO")
+		return
+	}
+	hdr, ok := readMem(as, bin, rva, 4)
+	if !ok {
+		flag("unwind info outside the image")
+		return
+	}
+	fn.Version = hdr[0] & 7
+	flags := hdr[0] >> 3
+	fn.PrologSize = uint32(hdr[1])
+	count := uint32(hdr[2])
+	if fr := hdr[3] & 0xF; fr != 0 {
+		fn.FrameReg = x64Regs[fr]
+		fn.FrameOffset = uint32(hdr[3]>>4) * 16
+	}
+	if fn.Version != 1 && fn.Version != 2 {
+		flag("unknown unwind info version %d", fn.Version)
+		return
+	}
+	for _, fl := range []struct {
+		bit  byte
+		name string
+	}{{unwFlagEHandler, "EHANDLER"}, {unwFlagUHandler, "UHANDLER"}, {unwFlagChainInfo, "CHAININFO"}} {
+		if flags&fl.bit != 0 {
+			fn.Flags = append(fn.Flags, fl.name)
+		}
+	}
+
+	codes, ok := readMem(as, bin, rva+4, count*2)
+	if !ok {
+		flag("unwind codes extend past end of file")
+		return
+	}
+	decodeX64Codes(fn, codes, flag)
+
+	tail := rva + 4 + ((count+1)&^1)*2
+	switch {
+	case flags&unwFlagChainInfo != 0:
+		parent, ok := readMem(as, bin, tail, 12)
+		if !ok {
+			flag("chained unwind info extends past end of file")
+			return
+		}
+		fn.ChainedTo = binary.LittleEndian.Uint32(parent)
+		// Walk the chain to catch loops; the parent carries the handler.
+		next := binary.LittleEndian.Uint32(parent[8:])
+		for depth := 0; ; depth++ {
+			if depth == maxChainDepth {
+				flag("unwind chain deeper than %d entries", maxChainDepth)
+				break
+			}
+			h, ok := readMem(as, bin, next&^1, 4)
+			if !ok || next&1 != 0 || (h[0]>>3)&unwFlagChainInfo == 0 {
+				break
+			}
+			n := uint32(h[2])
+			p, ok := readMem(as, bin, next+4+((n+1)&^1)*2+8, 4)
+			if !ok {
+				break
+			}
+			next = binary.LittleEndian.Uint32(p)
+		}
+	case flags&(unwFlagEHandler|unwFlagUHandler) != 0:
+		h, ok := memU32(as, bin, tail)
+		if !ok {
+			flag("exception handler RVA extends past end of file")
+			return
+		}
+		fn.HandlerRVA, fn.HandlerData = h, tail+4
+	}
+}
+
+// decodeX64Codes turns UNWIND_CODE slots into text and totals the stack
+// the prolog allocates.
+func decodeX64Codes(fn *UnwindFunction, b []byte, flag func(string, ...interface{})) {
+	n := len(b) / 2
+	slot := func(i int) uint32 { return uint32(binary.LittleEndian.Uint16(b[i*2:])) }
+	for i := 0; i < n; {
+		off, op, info := b[i*2], b[i*2+1]&0xF, b[i*2+1]>>4
+		used := 1
+		var text string
+		switch op {
+		case 0: // UWOP_PUSH_NONVOL
+			text = "push " + x64Regs[info]
+			fn.StackSize += 8
+			fn.SavedRegs = append(fn.SavedRegs, x64Regs[info])
+		case 1: // UWOP_ALLOC_LARGE
+			var size uint32
+			if info == 0 {
+				used = 2
+				if i+1 < n {
+					size = slot(i+1) * 8
+				}
+			} else {
+				used = 3
+				if i+2 < n {
+					size = slot(i+1) | slot(i+2)<<16
+				}
+			}
+			text = fmt.Sprintf("alloc 0x%X", size)
+			fn.StackSize += size
+		case 2: // UWOP_ALLOC_SMALL
+			size := uint32(info)*8 + 8
+			text = fmt.Sprintf("alloc 0x%X", size)
+			fn.StackSize += size
+		case 3: // UWOP_SET_FPREG
+			text = fmt.Sprintf("set_fpreg %s = rsp+0x%X", fn.FrameReg, fn.FrameOffset)
+		case 4, 5: // UWOP_SAVE_NONVOL, UWOP_SAVE_NONVOL_FAR
+			var at uint32
+			if op == 4 {
+				used = 2
+				if i+1 < n {
+					at = slot(i+1) * 8
+				}
+			} else {
+				used = 3
+				if i+2 < n {
+					at = slot(i+1) | slot(i+2)<<16
+				}
+			}
+			text = fmt.Sprintf("save %s, [rsp+0x%X]", x64Regs[info], at)
+			fn.SavedRegs = append(fn.SavedRegs, x64Regs[info])
+		case 6: // UWOP_EPILOG (version 2), UWOP_SAVE_XMM (version 1)
+			used = 2
+			if fn.Version == 2 {
+				text = fmt.Sprintf("epilog size 0x%X", off)
+				off = 0
+				fn.Epilogs++
+				break
+			}
+			var at uint32
+			if i+1 < n {
+				at = slot(i+1) * 8
+			}
+			text = fmt.Sprintf("save xmm%d, [rsp+0x%X]", info, at)
+		case 7: // UWOP_SPARE_CODE, UWOP_SAVE_XMM_FAR
+			used = 3
+			text = "spare"
+		case 8, 9: // UWOP_SAVE_XMM128, UWOP_SAVE_XMM128_FAR
+			var at uint32
+			if op == 8 {
+				used = 2
+				if i+1 < n {
+					at = slot(i+1) * 16
+				}
+			} else {
+				used = 3
+				if i+2 < n {
+					at = slot(i+1) | slot(i+2)<<16
+				}
+			}
+			text = fmt.Sprintf("save xmm%d, [rsp+0x%X]", info, at)
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("xmm%d", info))
+		case 10: // UWOP_PUSH_MACHFRAME
+			if info == 1 {
+				text = "push_machframe (error code)"
+				fn.StackSize += 48
+			} else {
+				text = "push_machframe"
+				fn.StackSize += 40
+			}
+		case 11: // UWOP_SET_FPREG_LARGE, emitted by the .NET JIT and crossgen
+			used = 3
+			var at uint32
+			if i+2 < n {
+				at = (slot(i+1) | slot(i+2)<<16) * 16
+			}
+			fn.FrameOffset = at
+			text = fmt.Sprintf("set_fpreg_large %s = rsp+0x%X", fn.FrameReg, at)
+		default:
+			flag("unknown x64 unwind opcode %d", op)
+			return
+		}
+		if i+used > n {
+			flag("truncated x64 unwind code")
+			return
+		}
+		if off != 0 {
+			text = fmt.Sprintf("+0x%02X %s", off, text)
+		}
+		fn.Codes = append(fn.Codes, text)
+		i += used
+	}
+}
+
+// decodeARM64Unwind decodes the packed form held in the .pdata entry itself
+// or the .xdata record it points to.
+func decodeARM64Unwind(as *addrSpace, bin []byte, fn *UnwindFunction, flag func(string, ...interface{})) {
+	v := fn.UnwindRVA
+	switch v & 3 {
+	case 1, 2:
+		fn.UnwindRVA = 0
+		fn.Flags = append(fn.Flags, "packed")
+		if v&3 == 2 {
+			fn.Flags = append(fn.Flags, "fragment")
+		}
+		fn.End = fn.Begin + ((v>>2)&0x7FF)*4
+		regF, regI := (v>>13)&7, (v>>16)&0xF
+		h, cr := (v>>20)&1, (v>>21)&3
+		fn.StackSize = ((v >> 23) & 0x1FF) * 16
+		for i := uint32(0); i < regI; i++ {
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", 19+i))
+		}
+		if cr == 1 {
+			fn.SavedRegs = append(fn.SavedRegs, "lr")
+		}
+		if regF > 0 {
+			for i := uint32(0); i <= regF; i++ {
+				fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("d%d", 8+i))
+			}
+		}
+		if cr >= 2 {
+			fn.SavedRegs = append(fn.SavedRegs, "fp", "lr")
+			fn.FrameReg = "fp"
+		}
+		if cr == 2 {
+			fn.Flags = append(fn.Flags, "pac")
+		}
+		if h != 0 {
+			fn.Flags = append(fn.Flags, "homes x0-x7")
+		}
+		return
+	case 3:
+		flag("reserved ARM64 packed unwind flag")
+		return
+	}
+
+	rva := v
+	w0, ok := memU32(as, bin, rva)
+	if !ok {
+		flag("unwind info outside the image")
+		return
+	}
+	fn.End = fn.Begin + (w0&0x3FFFF)*4
+	fn.Version = uint8((w0 >> 18) & 3)
+	x, e := (w0>>20)&1, (w0>>21)&1
+	epilogs, words := (w0>>22)&0x1F, (w0>>27)&0x1F
+	pos := uint32(4)
+	if epilogs == 0 && words == 0 {
+		w1, ok := memU32(as, bin, rva+4)
+		if !ok {
+			flag("unwind info extends past end of file")
+			return
+		}
+		epilogs, words = w1&0xFFFF, (w1>>16)&0xFF
+		pos = 8
+	}
+	if fn.Version != 0 {
+		flag("unknown unwind info version %d", fn.Version)
+		return
+	}
+	if x != 0 {
+		fn.Flags = append(fn.Flags, "EXCEPTION_DATA")
+	}
+	if e != 0 {
+		// The count field holds the start index of the one epilog instead.
+		fn.Flags = append(fn.Flags, "PACKED_EPILOG")
+		fn.Epilogs = 1
+	} else {
+		fn.Epilogs = int(epilogs)
+		pos += epilogs * 4
+	}
+	codes, ok := readMem(as, bin, rva+pos, words*4)
+	if !ok {
+		flag("unwind codes extend past end of file")
+		return
+	}
+	decodeARM64Codes(fn, codes, flag)
+	pos += words * 4
+	if x != 0 {
+		h, ok := memU32(as, bin, rva+pos)
+		if !ok {
+			flag("exception handler RVA extends past end of file")
+			return
+		}
+		fn.HandlerRVA, fn.HandlerData = h, rva+pos+4
+	}
+}
+
+// decodeARM64Codes decodes the prolog unwind codes, which run up to the
+// first end opcode; epilog sequences follow it.
+func decodeARM64Codes(fn *UnwindFunction, b []byte, flag func(string, ...interface{})) {
+	for i := 0; i < len(b); {
+		c := b[i]
+		size := 1
+		switch {
+		case c >= 0xC0 && c < 0xE0, c == 0xE2:
+			size = 2
+		case c == 0xE7:
+			size = 3
+		case c == 0xE0:
+			size = 4
+		}
+		if i+size > len(b) {
+			flag("truncated ARM64 unwind code")
+			return
+		}
+		var b1, b2, b3 uint32
+		if size > 1 {
+			b1 = uint32(b[i+1])
+		}
+		if size > 2 {
+			b2 = uint32(b[i+2])
+		}
+		if size > 3 {
+			b3 = uint32(b[i+3])
+		}
+		x4 := (uint32(c)&3)<<2 | b1>>6 // 4-bit register field split over both bytes
+		z6 := b1 & 0x3F
+		var text string
+		switch {
+		case c < 0x20:
+			n := uint32(c&0x1F) * 16
+			text = fmt.Sprintf("alloc_s 0x%X", n)
+			fn.StackSize += n
+		case c < 0x40:
+			n := uint32(c&0x1F) * 8
+			text = fmt.Sprintf("save_r19r20_x [sp-0x%X]!", n)
+			fn.StackSize += n
+			fn.SavedRegs = append(fn.SavedRegs, "x19", "x20")
+		case c < 0x80:
+			text = fmt.Sprintf("save_fplr [sp+0x%X]", uint32(c&0x3F)*8)
+			fn.SavedRegs = append(fn.SavedRegs, "fp", "lr")
+		case c < 0xC0:
+			n := (uint32(c&0x3F) + 1) * 8
+			text = fmt.Sprintf("save_fplr_x [sp-0x%X]!", n)
+			fn.StackSize += n
+			fn.SavedRegs = append(fn.SavedRegs, "fp", "lr")
+		case c < 0xC8:
+			n := (uint32(c&7)<<8 | b1) * 16
+			text = fmt.Sprintf("alloc_m 0x%X", n)
+			fn.StackSize += n
+		case c < 0xCC:
+			text = fmt.Sprintf("save_regp x%d, x%d, [sp+0x%X]", 19+x4, 20+x4, z6*8)
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", 19+x4), fmt.Sprintf("x%d", 20+x4))
+		case c < 0xD0:
+			n := (z6 + 1) * 8
+			text = fmt.Sprintf("save_regp_x x%d, x%d, [sp-0x%X]!", 19+x4, 20+x4, n)
+			fn.StackSize += n
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", 19+x4), fmt.Sprintf("x%d", 20+x4))
+		case c < 0xD4:
+			text = fmt.Sprintf("save_reg x%d, [sp+0x%X]", 19+x4, z6*8)
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", 19+x4))
+		case c < 0xD6:
+			r := (uint32(c&1)<<3 | b1>>5)
+			n := ((b1 & 0x1F) + 1) * 8
+			text = fmt.Sprintf("save_reg_x x%d, [sp-0x%X]!", 19+r, n)
+			fn.StackSize += n
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", 19+r))
+		case c < 0xD8:
+			r := 19 + 2*((uint32(c&1)<<2)|b1>>6)
+			text = fmt.Sprintf("save_lrpair x%d, lr, [sp+0x%X]", r, z6*8)
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("x%d", r), "lr")
+		case c < 0xDE:
+			r := 8 + ((uint32(c&1) << 2) | b1>>6)
+			switch c &^ 1 {
+			case 0xD8:
+				text = fmt.Sprintf("save_fregp d%d, d%d, [sp+0x%X]", r, r+1, z6*8)
+				fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("d%d", r), fmt.Sprintf("d%d", r+1))
+			case 0xDA:
+				n := (z6 + 1) * 8
+				text = fmt.Sprintf("save_fregp_x d%d, d%d, [sp-0x%X]!", r, r+1, n)
+				fn.StackSize += n
+				fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("d%d", r), fmt.Sprintf("d%d", r+1))
+			default:
+				text = fmt.Sprintf("save_freg d%d, [sp+0x%X]", r, z6*8)
+				fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("d%d", r))
+			}
+		case c == 0xDE:
+			r := 8 + b1>>5
+			n := ((b1 & 0x1F) + 1) * 8
+			text = fmt.Sprintf("save_freg_x d%d, [sp-0x%X]!", r, n)
+			fn.StackSize += n
+			fn.SavedRegs = append(fn.SavedRegs, fmt.Sprintf("d%d", r))
+		case c == 0xDF:
+			text = fmt.Sprintf("alloc_z %d vector lengths", b1)
+		case c == 0xE0:
+			n := (b1<<16 | b2<<8 | b3) * 16
+			text = fmt.Sprintf("alloc_l 0x%X", n)
+			fn.StackSize += n
+		case c == 0xE1:
+			text = "set_fp"
+			fn.FrameReg = "fp"
+		case c == 0xE2:
+			text = fmt.Sprintf("add_fp fp = sp+0x%X", b1*8)
+			fn.FrameReg, fn.FrameOffset = "fp", b1*8
+		case c == 0xE3:
+			text = "nop"
+		case c == 0xE4:
+			return // end of the prolog codes
+		case c == 0xE5:
+			text = "end_c"
+		case c == 0xE6:
+			text = "save_next"
+		case c == 0xE7:
+			text = fmt.Sprintf("save_any_reg 0x%02X%02X", b1, b2)
+		case c == 0xE8:
+			text = "trap_frame"
+		case c == 0xE9:
+			text = "machine_frame"
+		case c == 0xEA:
+			text = "context"
+		case c == 0xEB:
+			text = "ec_context"
+		case c == 0xEC:
+			text = "clear_unwound_to_call"
+		case c == 0xFC:
+			text = "pac_sign_lr"
+		default:
+			flag("reserved ARM64 unwind code 0x%02X", c)
+			return
+		}
+		fn.Codes = append(fn.Codes, text)
+		i += size
+	}
+}
+
+// nameExceptionHandlers names language-specific handlers from exports, PDB
+// symbols and import thunks, and decodes the scope tables of functions
+// that use __C_specific_handler.
+func nameExceptionHandlers(f *pe.File, as *addrSpace, bin []byte, r *Report) {
+	ex := &r.Exception
+	if len(ex.Handlers) == 0 {
+		return
+	}
+	names := r.Symbols.CodeNames()
+	for _, s := range r.Exports.Symbols {
+		if s.Forwarder == "" && s.Name != "" && names[s.RVA] == "" {
+			names[s.RVA] = s.Name
+		}
+	}
+	ctx, _ := newDisasmContext(f, as, bin, r)
+	for i := range ex.Handlers {
+		h := &ex.Handlers[i]
+		h.Name = names[h.RVA]
+		if h.Name == "" && ctx != nil {
+			h.Name = ctx.thunkTarget(ctx.imageBase + uint64(h.RVA))
+		}
+		names[h.RVA] = h.Name
+	}
+	for i := range ex.Functions {
+		fn := &ex.Functions[i]
+		if fn.HandlerRVA == 0 {
+			continue
+		}
+		fn.Handler = names[fn.HandlerRVA]
+		if strings.HasSuffix(fn.Handler, "__C_specific_handler") {
+			fn.Scopes = readScopeTable(as, bin, fn.HandlerData)
+		}
+	}
+}
+
+// readScopeTable reads the SCOPE_TABLE that follows the handler RVA.
+func readScopeTable(as *addrSpace, bin []byte, rva uint32) []ScopeRecord {
+	n, ok := memU32(as, bin, rva)
+	if !ok || n == 0 || n > maxScopeRecords {
+		return nil
+	}
+	b, ok := readMem(as, bin, rva+4, n*16)
+	if !ok {
+		return nil
+	}
+	out := make([]ScopeRecord, n)
+	for i := range out {
+		e := b[i*16:]
+		out[i] = ScopeRecord{
+			Begin:   binary.LittleEndian.Uint32(e),
+			End:     binary.LittleEndian.Uint32(e[4:]),
+			Handler: binary.LittleEndian.Uint32(e[8:]),
+			Target:  binary.LittleEndian.Uint32(e[12:]),
+		}
+	}
+	return out
+}
@@ -0,0 +1,569 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"PE-Parser/internal/disasm"
+)
+
+const (
+	maxFunctions         = 50000
+	maxFuncInstructions  = 20000
+	maxTotalInstructions = 4000000
+	maxFuncStrings       = 32
+	maxXrefSites         = 64
+	funcStringLen        = 120
+)
+
+// Imports that never return; code after a call to them is not followed.
+var noReturnAPIs = map[string]bool{
+	"ExitProcess": true, "ExitThread": true, "FreeLibraryAndExitThread": true, "RtlExitUserThread": true,
+	"RtlExitUserProcess": true, "_exit": true, "exit": true, "abort": true, "_invalid_parameter_noinfo_noreturn": true,
+	"__fastfail": true, "_CxxThrowException": true, "RaiseFailFastException": true,
+}
+
+type Function struct {
+	RVA     uint32   `json:"rva"`
+	VA      uint64   `json:"va"`
+	Name    string   `json:"name"`
+	Size    uint32   `json:"size"`
+	Seeds   []string `json:"seeds,omitempty"`
+	Callees []uint32 `json:"callees,omitempty"`
+	APIs    []string `json:"apis,omitempty"`
+	Strings []string `json:"strings,omitempty"`
+}
+
+// XrefSite is an instruction that references a string.
+type XrefSite struct {
+	VA      uint64
+	RVA     uint32
+	FuncRVA uint32
+}
+
+type StringXref struct {
+	Text    string
+	Wide    bool
+	Section string
+	RVA     uint32
+	VA      uint64
+	Count   int
+	Sites   []XrefSite
+}
+
+type StringXrefReport struct {
+	Items        []StringXref
+	Unreferenced int
+	Note         string
+}
+
+type FunctionReport struct {
+	Functions []Function `json:"functions"`
+	Note      string     `json:"note,omitempty"`
+}
+
+// APIUse lists the functions that reference one imported API.
+type APIUse struct {
+	API     string
+	Callers []uint32
+}
+
+// stringIndex finds the extracted string that covers a given RVA.
+type stringIndex struct {
+	items []indexedString
+}
+
+type indexedString struct {
+	RVA     uint32
+	Section string
+	locatedString
+}
+
+func newStringIndex(as *addrSpace, bin []byte, minLen int) *stringIndex {
+	idx := &stringIndex{}
+	for i := range as.regions {
+		r := &as.regions[i]
+		if r.section == nil || r.fileSize == 0 {
+			continue
+		}
+		n := r.fileSize
+		if uint64(r.raw) >= uint64(len(bin)) {
+			continue
+		}
+		if uint64(r.raw)+uint64(n) > uint64(len(bin)) {
+			n = uint32(len(bin)) - r.raw
+		}
+		b, ok := readMem(as, bin, r.va, n)
+		if !ok {
+			continue
+		}
+		for _, ls := range extractLocatedStrings(b, minLen) {
+			rva := r.va + uint32(ls.Off)
+			// Where sections overlap, the string belongs to the one mapped there.
+			if w, ok := as.region(rva); !ok || w != r {
+				continue
+			}
+			idx.items = append(idx.items, indexedString{RVA: rva, Section: r.section.Name, locatedString: ls})
+		}
+	}
+	sort.Slice(idx.items, func(i, j int) bool { return idx.items[i].RVA < idx.items[j].RVA })
+	return idx
+}
+
+// lookup returns the string containing rva, or -1. Pointers into the middle
+// of a run are accepted since some compilers pack string data back to back.
+func (x *stringIndex) lookup(rva uint32) int {
+	i := sort.Search(len(x.items), func(i int) bool { return x.items[i].RVA > rva }) - 1
+	// ASCII and wide runs can overlap; check a few candidates.
+	for k := i; k >= 0 && k > i-4; k-- {
+		s := x.items[k]
+		if rva >= s.RVA && rva < s.RVA+uint32(s.Size) && (!s.Wide || (rva-s.RVA)%2 == 0) {
+			return k
+		}
+	}
+	return -1
+}
+
+// textAt returns the string text starting at rva within item k.
+func (x *stringIndex) textAt(k int, rva uint32) string {
+	s := x.items[k]
+	skip := int(rva - s.RVA)
+	if s.Wide {
+		skip /= 2
+	}
+	if skip == 0 || skip >= len(s.Text) || strings.IndexFunc(s.Text, func(r rune) bool { return r > 0x7E }) >= 0 {
+		return s.Text
+	}
+	return s.Text[skip:]
+}
+
+// plausible filters out data that only happens to be printable, e.g. a
+// global initialised with Latin-1 range bytes.
+func (x *stringIndex) plausible(k int) bool {
+	ascii, total := 0, 0
+	for _, r := range x.items[k].Text {
+		total++
+		if r >= 0x20 && r <= 0x7E {
+			ascii++
+		}
+	}
+	return ascii*4 >= total*3
+}
+
+type funcBuilder struct {
+	ctx     *disasmContext
+	strs    *stringIndex
+	relocs  map[uint32]bool
+	xrefs   map[int]*StringXref
+	funcs   map[uint32]*Function
+	seen    map[uint32]uint32 // instruction RVA -> owning function
+	queue   []uint32
+	budget  int
+	limited bool
+}
+
+func discoverFunctions(f *pe.File, as *addrSpace, bin []byte, r *Report, minLen int) (FunctionReport, StringXrefReport) {
+	var out FunctionReport
+	var xr StringXrefReport
+	ctx, note := newDisasmContext(f, as, bin, r)
+	if ctx == nil {
+		out.Note = note
+		xr.Note = note
+		return out, xr
+	}
+	b := &funcBuilder{
+		ctx:    ctx,
+		strs:   newStringIndex(as, bin, minLen),
+		relocs: r.Relocs.absoluteRVAs(),
+		xrefs:  make(map[int]*StringXref),
+		funcs:  make(map[uint32]*Function),
+		seen:   make(map[uint32]uint32),
+		budget: maxTotalInstructions,
+	}
+
+	if r.Header.EntryPointRVA != 0 {
+		b.seed(r.Header.EntryPointRVA, "entry", "entry")
+	}
+	for i, rva := range tlsCallbacks(f, as, bin) {
+		b.seed(rva, "tls", fmt.Sprintf("tls_callback_%d", i))
+	}
+	for _, s := range r.Exports.Symbols {
+		if s.Forwarder != "" {
+			continue
+		}
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("ordinal_%d", s.Ordinal)
+		}
+		b.seed(s.RVA, "export", name)
+	}
+	for _, rva := range r.Exception.seeds() {
+		b.seed(rva, "pdata", "")
+	}
+	symNames := r.Symbols.CodeNames()
+	for _, s := range r.Symbols.Symbols {
+		if s.Code {
+			b.seed(s.RVA, "pdb", symNames[s.RVA])
+		}
+	}
+
+	for len(b.queue) > 0 && b.budget > 0 {
+		rva := b.queue[0]
+		b.queue = b.queue[1:]
+		b.explore(b.funcs[rva])
+	}
+
+	out.Functions = make([]Function, 0, len(b.funcs))
+	for _, fn := range b.funcs {
+		sort.Slice(fn.Callees, func(i, j int) bool { return fn.Callees[i] < fn.Callees[j] })
+		sort.Strings(fn.APIs)
+		out.Functions = append(out.Functions, *fn)
+	}
+	sort.Slice(out.Functions, func(i, j int) bool { return out.Functions[i].RVA < out.Functions[j].RVA })
+	switch {
+	case b.budget <= 0:
+		out.Note = fmt.Sprintf("stopped after %d instructions", maxTotalInstructions)
+	case b.limited:
+		out.Note = fmt.Sprintf("stopped after %d functions", maxFunctions)
+	}
+	return out, b.stringXrefs()
+}
+
+// stringXrefs lists the referenced strings in address order.
+func (b *funcBuilder) stringXrefs() StringXrefReport {
+	var xr StringXrefReport
+	for k, s := range b.strs.items {
+		x, ok := b.xrefs[k]
+		if !ok {
+			xr.Unreferenced++
+			continue
+		}
+		x.Text, x.Wide, x.Section = s.Text, s.Wide, s.Section
+		x.RVA, x.VA = s.RVA, b.ctx.imageBase+uint64(s.RVA)
+		sort.Slice(x.Sites, func(i, j int) bool { return x.Sites[i].RVA < x.Sites[j].RVA })
+		xr.Items = append(xr.Items, *x)
+	}
+	if b.relocs == nil && b.ctx.bits == 32 {
+		xr.Note = "no base relocations; absolute references only matched on the first character of a string"
+	}
+	return xr
+}
+
+func (b *funcBuilder) executable(rva uint32) bool {
+	r, ok := b.ctx.as.region(rva)
+	return ok && r.section != nil && r.section.Characteristics&(scnCntCode|scnMemExecute) != 0
+}
+
+// seed registers a function start. Known starts only gain a seed kind and,
+// if they have none yet, a name.
+func (b *funcBuilder) seed(rva uint32, kind, name string) *Function {
+	if fn, ok := b.funcs[rva]; ok {
+		if kind != "call" && !containsString(fn.Seeds, kind) {
+			fn.Seeds = append(fn.Seeds, kind)
+		}
+		// PDB names also replace the placeholder names of entry points.
+		if name != "" && (strings.HasPrefix(fn.Name, "sub_") || (kind == "pdb" && placeholderName(fn.Name))) {
+			fn.Name = name
+		}
+		return fn
+	}
+	if !b.executable(rva) {
+		return nil
+	}
+	if len(b.funcs) >= maxFunctions {
+		b.limited = true
+		return nil
+	}
+	if name == "" {
+		name = fmt.Sprintf("sub_%X", b.ctx.imageBase+uint64(rva))
+	}
+	fn := &Function{RVA: rva, VA: b.ctx.imageBase + uint64(rva), Name: name}
+	if kind != "call" {
+		fn.Seeds = []string{kind}
+	}
+	b.funcs[rva] = fn
+	b.queue = append(b.queue, rva)
+	return fn
+}
+
+func placeholderName(name string) bool {
+	return name == "entry" || strings.HasPrefix(name, "tls_callback_") || strings.HasPrefix(name, "ordinal_")
+}
+
+func containsRVA(list []uint32, v uint32) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// explore follows every path of fn by recursive descent. Calls seed new
+// functions; direct jumps to another known start are treated as tail calls.
+func (b *funcBuilder) explore(fn *Function) {
+	c := b.ctx
+	blocks := []uint32{fn.RVA}
+	end := fn.RVA
+	n := 0
+	for len(blocks) > 0 && n < maxFuncInstructions && b.budget > 0 {
+		rva := blocks[len(blocks)-1]
+		blocks = blocks[:len(blocks)-1]
+		for n < maxFuncInstructions && b.budget > 0 {
+			if owner, ok := b.seen[rva]; ok {
+				if owner != fn.RVA && !containsRVA(fn.Callees, owner) && rva == owner {
+					fn.Callees = append(fn.Callees, owner)
+				}
+				break
+			}
+			if rva != fn.RVA {
+				if _, ok := b.funcs[rva]; ok {
+					// fell through into another function
+					break
+				}
+			}
+			code := codeAt(c.as, c.bin, rva)
+			if len(code) == 0 {
+				break
+			}
+			va := c.imageBase + uint64(rva)
+			inst, err := disasm.Decode(code, va, c.bits)
+			if err != nil {
+				break
+			}
+			b.seen[rva] = fn.RVA
+			n++
+			b.budget--
+			next := rva + uint32(inst.Len)
+			if next > end {
+				end = next
+			}
+			api := b.references(fn, inst, rva)
+
+			stop := false
+			switch inst.Flow {
+			case disasm.FlowRet, disasm.FlowHalt:
+				stop = true
+			case disasm.FlowCall:
+				if inst.HasTarget {
+					if name := c.thunkTarget(inst.Target); name != "" {
+						b.addAPI(fn, name)
+						api = name
+					} else if t, ok := b.rva(inst.Target); ok {
+						if callee := b.seed(t, "call", ""); callee != nil && callee.RVA != fn.RVA && !containsRVA(fn.Callees, callee.RVA) {
+							fn.Callees = append(fn.Callees, callee.RVA)
+						}
+					}
+				}
+				if api != "" && noReturnAPIs[api[strings.IndexByte(api, '!')+1:]] {
+					stop = true
+				}
+			case disasm.FlowJump:
+				stop = true
+				if inst.HasTarget {
+					if name := c.thunkTarget(inst.Target); name != "" {
+						b.addAPI(fn, name)
+					} else if t, ok := b.rva(inst.Target); ok {
+						if other, known := b.funcs[t]; known && t != fn.RVA {
+							if !containsRVA(fn.Callees, other.RVA) {
+								fn.Callees = append(fn.Callees, other.RVA)
+							}
+						} else if b.executable(t) {
+							blocks = append(blocks, t)
+						}
+					}
+				}
+			case disasm.FlowCondJump:
+				if t, ok := b.rva(inst.Target); ok && b.executable(t) {
+					blocks = append(blocks, t)
+				}
+			}
+			if stop {
+				break
+			}
+			rva = next
+		}
+	}
+	fn.Size = end - fn.RVA
+}
+
+func (b *funcBuilder) rva(va uint64) (uint32, bool) {
+	if va < b.ctx.imageBase || va-b.ctx.imageBase > 0xFFFFFFFF {
+		return 0, false
+	}
+	return uint32(va - b.ctx.imageBase), true
+}
+
+func (b *funcBuilder) addAPI(fn *Function, name string) {
+	if !containsString(fn.APIs, name) {
+		fn.APIs = append(fn.APIs, name)
+	}
+}
+
+// references records IAT slots and strings touched by the instruction at
+// rva. It returns the API name, if any.
+func (b *funcBuilder) references(fn *Function, inst disasm.Inst, rva uint32) string {
+	var api string
+	for _, addr := range b.ctx.operandAddrs(inst) {
+		if name, ok := b.ctx.iat[addr]; ok {
+			b.addAPI(fn, name)
+			api = name
+		}
+	}
+	for _, ref := range b.stringAddrs(inst, rva) {
+		k := b.strs.lookup(ref.rva)
+		if k < 0 || !b.strs.plausible(k) || (ref.exact && b.strs.items[k].RVA != ref.rva) {
+			continue
+		}
+		b.addXref(k, XrefSite{VA: inst.Addr, RVA: rva, FuncRVA: fn.RVA})
+		if len(fn.Strings) < maxFuncStrings {
+			text := truncateText(b.strs.textAt(k, ref.rva), funcStringLen)
+			if !containsString(fn.Strings, text) {
+				fn.Strings = append(fn.Strings, text)
+			}
+		}
+	}
+	return api
+}
+
+type stringRef struct {
+	rva   uint32
+	exact bool // only accept a pointer to the first character
+}
+
+// stringAddrs lists the addresses an instruction may use as a data pointer.
+// RIP-relative operands are taken as is; absolute addresses only count when
+// a base relocation patches the instruction. Images without relocations fall
+// back to exact matches on the start of a string.
+func (b *funcBuilder) stringAddrs(inst disasm.Inst, rva uint32) []stringRef {
+	relocated, exact := true, false
+	if b.relocs == nil {
+		exact = true
+	} else {
+		relocated = false
+		for i := uint32(0); i < uint32(inst.Len); i++ {
+			if b.relocs[rva+i] {
+				relocated = true
+				break
+			}
+		}
+	}
+	var out []stringRef
+	add := func(va uint64, exact bool) {
+		if t, ok := b.rva(va); ok {
+			out = append(out, stringRef{rva: t, exact: exact})
+		}
+	}
+	if inst.HasMem {
+		switch {
+		case inst.RIPRel:
+			add(inst.Mem, false)
+		case relocated:
+			add(inst.Mem, exact)
+		}
+	}
+	if inst.HasImm && relocated && inst.Imm > b.ctx.imageBase {
+		add(inst.Imm, exact)
+	}
+	return out
+}
+
+func (b *funcBuilder) addXref(k int, site XrefSite) {
+	x := b.xrefs[k]
+	if x == nil {
+		x = &StringXref{}
+		b.xrefs[k] = x
+	}
+	x.Count++
+	if len(x.Sites) < maxXrefSites {
+		x.Sites = append(x.Sites, site)
+	}
+}
+
+// operandAddrs lists the absolute addresses an instruction refers to through
+// memory operands, and in 32-bit code through immediates as well.
+func (c *disasmContext) operandAddrs(inst disasm.Inst) []uint64 {
+	var out []uint64
+	if inst.HasMem {
+		out = append(out, inst.Mem)
+	}
+	if inst.HasImm && c.bits == 32 && inst.Imm > c.imageBase {
+		out = append(out, inst.Imm)
+	}
+	return out
+}
+
+// APICallers inverts the per-function API lists.
+func (g FunctionReport) APICallers() []APIUse {
+	m := make(map[string][]uint32)
+	for _, fn := range g.Functions {
+		for _, api := range fn.APIs {
+			m[api] = append(m[api], fn.RVA)
+		}
+	}
+	out := make([]APIUse, 0, len(m))
+	for api, callers := range m {
+		out = append(out, APIUse{API: api, Callers: callers})
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].API) < strings.ToLower(out[j].API) })
+	return out
+}
+
+// Lookup returns the function starting at rva.
+func (g FunctionReport) Lookup(rva uint32) (Function, bool) {
+	i := sort.Search(len(g.Functions), func(i int) bool { return g.Functions[i].RVA >= rva })
+	if i < len(g.Functions) && g.Functions[i].RVA == rva {
+		return g.Functions[i], true
+	}
+	return Function{}, false
+}
+
+func (g FunctionReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the call graph in Graphviz format. Imported APIs are drawn
+// as ellipses, functions as boxes.
+func (g FunctionReport) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph callgraph {\n  rankdir=LR;\n  node [shape=box, fontname=\"monospace\"];\n")
+	names := make(map[uint32]string, len(g.Functions))
+	for _, fn := range g.Functions {
+		names[fn.RVA] = fn.Name
+	}
+	apis := make(map[string]bool)
+	for _, fn := range g.Functions {
+		fmt.Fprintf(&sb, "  %q [label=%q];\n", fmt.Sprintf("f_%08X", fn.RVA), fn.Name)
+		for _, api := range fn.APIs {
+			apis[api] = true
+		}
+	}
+	apiList := make([]string, 0, len(apis))
+	for api := range apis {
+		apiList = append(apiList, api)
+	}
+	sort.Strings(apiList)
+	for _, api := range apiList {
+		fmt.Fprintf(&sb, "  %q [shape=ellipse];\n", api)
+	}
+	for _, fn := range g.Functions {
+		from := fmt.Sprintf("f_%08X", fn.RVA)
+		for _, c := range fn.Callees {
+			if _, ok := names[c]; ok {
+				fmt.Fprintf(&sb, "  %q -> %q;\n", from, fmt.Sprintf("f_%08X", c))
+			}
+		}
+		for _, api := range fn.APIs {
+			fmt.Fprintf(&sb, "  %q -> %q;\n", from, api)
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,153 @@
+package peparse
+
+import (
+	"debug/pe"
+	"fmt"
+)
+
+const (
+	HardenPass = "pass"
+	HardenFail = "fail"
+	HardenNA   = "n/a"
+)
+
+// HardeningChecks lists the check names in report order; batch output uses
+// them as column names.
+var HardeningChecks = []string{
+	"ASLR", "HighEntropyVA", "NX", "ForceIntegrity", "SafeSEH", "AppContainer",
+	"CFG", "SecurityCookie", "Relocations", "CET",
+}
+
+const (
+	guardCFInstrumented       = 0x00000100
+	guardSecurityCookieUnused = 0x00000800
+
+	exDllCharCETCompat = 0x01
+)
+
+type HardeningCheck struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"` // HardenPass, HardenFail or HardenNA
+	Detail  string `json:"detail"`
+}
+
+type HardeningReport struct {
+	Checks []HardeningCheck
+	Note   string
+}
+
+// Outcome returns the outcome of the named check, or "" if it was not run.
+func (h HardeningReport) Outcome(name string) string {
+	for _, c := range h.Checks {
+		if c.Name == name {
+			return c.Outcome
+		}
+	}
+	return ""
+}
+
+// Harden opens path and runs only the hardening checks, for batch audits.
+func Harden(path string) (HardeningReport, error) {
+	f, data, _, err := openImage(path)
+	if err != nil {
+		return HardeningReport{}, err
+	}
+	defer f.Close()
+	as := newAddrSpace(f)
+	return assessHardening(f, parseLoadConfig(f, as, data, nil), parseDebug(f, as, data)), nil
+}
+
+func assessHardening(f *pe.File, lc LoadConfigReport, dbg DebugReport) HardeningReport {
+	var r HardeningReport
+	is64, oh32, oh64 := getOptional(f)
+	var dllChars, subsystem uint16
+	switch {
+	case oh32 != nil:
+		dllChars, subsystem = oh32.DllCharacteristics, oh32.Subsystem
+	case oh64 != nil:
+		dllChars, subsystem = oh64.DllCharacteristics, oh64.Subsystem
+	default:
+		r.Note = "no optional header"
+		return r
+	}
+	has := func(flag uint16) bool { return dllChars&flag != 0 }
+	add := func(name, outcome, detail string) {
+		r.Checks = append(r.Checks, HardeningCheck{Name: name, Outcome: outcome, Detail: detail})
+	}
+	passIf := func(name string, ok bool, yes, no string) {
+		if ok {
+			add(name, HardenPass, yes)
+		} else {
+			add(name, HardenFail, no)
+		}
+	}
+	stripped := f.FileHeader.Characteristics&pe.IMAGE_FILE_RELOCS_STRIPPED != 0
+	reloc := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_BASERELOC)
+	hasRelocs := reloc.VirtualAddress != 0 && reloc.Size != 0 && !stripped
+	isDLL := f.FileHeader.Characteristics&pe.IMAGE_FILE_DLL != 0
+	x86 := f.FileHeader.Machine == pe.IMAGE_FILE_MACHINE_I386
+	intel := x86 || f.FileHeader.Machine == pe.IMAGE_FILE_MACHINE_AMD64
+
+	switch {
+	case !has(pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE):
+		add("ASLR", HardenFail, "DYNAMIC_BASE not set")
+	case !hasRelocs:
+		add("ASLR", HardenFail, "DYNAMIC_BASE set but the image has no base relocations")
+	default:
+		add("ASLR", HardenPass, "DYNAMIC_BASE")
+	}
+	if is64 {
+		passIf("HighEntropyVA", has(pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA) && has(pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE),
+			"HIGH_ENTROPY_VA", "HIGH_ENTROPY_VA not set")
+	} else {
+		add("HighEntropyVA", HardenNA, "32-bit image")
+	}
+	passIf("NX", has(pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT), "NX_COMPAT", "NX_COMPAT not set")
+	passIf("ForceIntegrity", has(pe.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY), "FORCE_INTEGRITY", "signature not enforced at load")
+
+	switch {
+	case !x86:
+		add("SafeSEH", HardenNA, "table-based exception handling")
+	case has(pe.IMAGE_DLLCHARACTERISTICS_NO_SEH):
+		add("SafeSEH", HardenPass, "NO_SEH: image uses no structured exception handlers")
+	case lc.SEHandlerTable != 0 && lc.SEHandlerCount != 0:
+		add("SafeSEH", HardenPass, fmt.Sprintf("%d handler(s) registered", lc.SEHandlerCount))
+	default:
+		add("SafeSEH", HardenFail, "no SafeSEH handler table")
+	}
+
+	if isDLL || subsystem == pe.IMAGE_SUBSYSTEM_NATIVE {
+		add("AppContainer", HardenNA, "only meaningful for executables")
+	} else {
+		passIf("AppContainer", has(pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER), "APPCONTAINER", "APPCONTAINER not set")
+	}
+
+	switch {
+	case !has(pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF):
+		add("CFG", HardenFail, "GUARD_CF not set")
+	case lc.GuardFlags&guardCFInstrumented == 0:
+		add("CFG", HardenFail, "GUARD_CF set but the load config has no CFG instrumentation")
+	default:
+		add("CFG", HardenPass, fmt.Sprintf("%d valid call targets", lc.GuardCFFunctionCount))
+	}
+
+	switch {
+	case !lc.Present:
+		add("SecurityCookie", HardenFail, "no load config directory")
+	case lc.SecurityCookie == 0 || lc.GuardFlags&guardSecurityCookieUnused != 0:
+		add("SecurityCookie", HardenFail, "no /GS security cookie")
+	default:
+		add("SecurityCookie", HardenPass, fmt.Sprintf("cookie at 0x%X", lc.SecurityCookie))
+	}
+
+	passIf("Relocations", hasRelocs, fmt.Sprintf("%d bytes of base relocations", reloc.Size),
+		"no base relocations; the image can only load at its preferred base")
+
+	if !intel {
+		add("CET", HardenNA, "shadow stacks are x86/x64 only")
+	} else {
+		ex, ok := exDllCharacteristics(dbg)
+		passIf("CET", ok && ex&exDllCharCETCompat != 0, "CET_COMPAT", "CET_COMPAT not set")
+	}
+	return r
+}
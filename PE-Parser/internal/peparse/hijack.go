@@ -0,0 +1,202 @@
+package peparse
+
+import (
+	"bufio"
+	"debug/pe"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultKnownDLLs mirrors HKLM\SYSTEM\CurrentControlSet\Control\Session
+// Manager\KnownDLLs on Windows 10/11. ntdll and kernelbase are added since
+// they are mapped before the application directory is ever searched.
+var defaultKnownDLLs = []string{
+	"advapi32.dll", "clbcatq.dll", "combase.dll", "comdlg32.dll", "coml2.dll", "difxapi.dll",
+	"gdi32.dll", "gdiplus.dll", "imagehlp.dll", "imm32.dll", "kernel32.dll", "msctf.dll",
+	"msvcrt.dll", "normaliz.dll", "nsi.dll", "ole32.dll", "oleaut32.dll", "psapi.dll",
+	"rpcrt4.dll", "sechost.dll", "setupapi.dll", "shcore.dll", "shell32.dll", "shlwapi.dll",
+	"user32.dll", "wldap32.dll", "wow64.dll", "wow64cpu.dll", "wow64win.dll", "ws2_32.dll",
+	"ntdll.dll", "kernelbase.dll",
+}
+
+type HijackCandidate struct {
+	DLL      string
+	Delay    bool
+	InAppDir bool // a file of that name already sits next to the binary
+}
+
+// SideLoadCheck compares the exports of the input DLL with the legitimate
+// DLL it is named after.
+type SideLoadCheck struct {
+	Reference      string
+	RefExports     int
+	Exports        int
+	Missing        []string // exported by the reference, not by the input
+	Extra          []string
+	Forwarded      int
+	ForwardTargets []string
+	Verdict        string
+}
+
+type HijackReport struct {
+	Candidates []HijackCandidate
+	KnownDLLs  string // where the KnownDLLs list came from
+	SideLoad   *SideLoadCheck
+	Note       string
+}
+
+// LoadKnownDLLs reads one DLL name per line; blank lines and lines starting
+// with # are ignored.
+func LoadKnownDLLs(path string) ([]string, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var out []string
+	sc := bufio.NewScanner(fh)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, sc.Err()
+}
+
+func assessHijack(path string, f *pe.File, as *addrSpace, bin []byte, imp ImportReport, opts Options) HijackReport {
+	var r HijackReport
+	known := defaultKnownDLLs
+	r.KnownDLLs = "built-in"
+	if opts.KnownDLLsPath != "" {
+		list, err := LoadKnownDLLs(opts.KnownDLLsPath)
+		if err != nil {
+			r.Note = appendNote(r.Note, "KnownDLLs: "+err.Error())
+		} else {
+			known = list
+			r.KnownDLLs = opts.KnownDLLsPath
+		}
+	}
+	knownSet := make(map[string]bool, len(known))
+	for _, k := range known {
+		knownSet[dllFileName(k)] = true
+	}
+
+	appDir := make(map[string]bool)
+	if entries, err := os.ReadDir(filepath.Dir(path)); err == nil {
+		for _, e := range entries {
+			appDir[strings.ToLower(e.Name())] = true
+		}
+	}
+	self := strings.ToLower(filepath.Base(path))
+	seen := make(map[string]bool)
+	add := func(name string, delay bool) {
+		n := dllFileName(name)
+		if seen[n] || knownSet[n] || IsAPISet(n) || n == self {
+			return
+		}
+		seen[n] = true
+		r.Candidates = append(r.Candidates, HijackCandidate{DLL: n, Delay: delay, InAppDir: appDir[n]})
+	}
+	for _, d := range imp.DLLs {
+		add(d.Name, false)
+	}
+	for _, d := range imp.Delay {
+		add(d.Name, true)
+	}
+
+	if f.FileHeader.Characteristics&pe.IMAGE_FILE_DLL != 0 && opts.RefDLLPath != "" {
+		ref := opts.RefDLLPath
+		if st, err := os.Stat(ref); err == nil && st.IsDir() {
+			ref = findFileFold(ref, filepath.Base(path))
+		}
+		if ref == "" {
+			r.Note = appendNote(r.Note, "no reference DLL named "+filepath.Base(path))
+		} else if chk, err := compareExports(f, as, bin, ref); err != nil {
+			r.Note = appendNote(r.Note, "reference DLL: "+err.Error())
+		} else {
+			r.SideLoad = chk
+		}
+	}
+	return r
+}
+
+func dllFileName(name string) string {
+	n := strings.ToLower(strings.TrimSpace(name))
+	if filepath.Ext(n) == "" {
+		n += ".dll"
+	}
+	return n
+}
+
+func findFileFold(dir, name string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(e.Name(), name) {
+			return filepath.Join(dir, e.Name())
+		}
+	}
+	return ""
+}
+
+// compareExports looks for the export-proxy pattern: a DLL that carries the
+// name and export set of a system DLL but forwards most of it elsewhere.
+func compareExports(f *pe.File, as *addrSpace, bin []byte, refPath string) (*SideLoadCheck, error) {
+	refData, err := os.ReadFile(refPath)
+	if err != nil {
+		return nil, err
+	}
+	rf, err := pe.Open(refPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rf.Close()
+	ref := newExportTable(parseExports(rf, newAddrSpace(rf), refData))
+	own := newExportTable(parseExports(f, as, bin))
+
+	chk := &SideLoadCheck{Reference: refPath, RefExports: len(ref.names), Exports: len(own.names)}
+	for name := range ref.names {
+		if _, ok := own.names[name]; !ok {
+			chk.Missing = append(chk.Missing, name)
+		}
+	}
+	targets := make(map[string]bool)
+	for name, sym := range own.names {
+		if _, ok := ref.names[name]; !ok {
+			chk.Extra = append(chk.Extra, name)
+		}
+		if sym.Forwarder != "" {
+			chk.Forwarded++
+			mod, _ := splitForwarder(sym.Forwarder)
+			targets[mod] = true
+		}
+	}
+	for t := range targets {
+		chk.ForwardTargets = append(chk.ForwardTargets, t)
+	}
+	sort.Strings(chk.Missing)
+	sort.Strings(chk.Extra)
+	sort.Strings(chk.ForwardTargets)
+
+	common := chk.Exports - len(chk.Extra)
+	switch {
+	case chk.RefExports == 0:
+		chk.Verdict = "reference DLL has no named exports"
+	case chk.Forwarded*2 >= chk.Exports && common*2 >= chk.RefExports:
+		chk.Verdict = fmt.Sprintf("likely proxy DLL: %d of %d exports forwarded to %s", chk.Forwarded, chk.Exports, strings.Join(chk.ForwardTargets, ", "))
+	case len(chk.Missing) == 0:
+		chk.Verdict = "export set matches the reference; compare code and signature before trusting it"
+	case common*2 >= chk.RefExports:
+		chk.Verdict = fmt.Sprintf("partial export set (%d of %d reference exports); possible side-loading stub", common, chk.RefExports)
+	default:
+		chk.Verdict = fmt.Sprintf("export set differs from the reference (%d of %d shared)", common, chk.RefExports)
+	}
+	return chk, nil
+}
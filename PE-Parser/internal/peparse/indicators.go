@@ -0,0 +1,496 @@
+package peparse
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type sourcedString struct {
+	Text   string
+	Source string
+}
+
+func appendSourced(dst []sourcedString, strs []string, source string) []sourcedString {
+	for _, s := range strs {
+		dst = append(dst, sourcedString{Text: s, Source: source})
+	}
+	return dst
+}
+
+// Kinds are listed in the order they are reported.
+var indicatorKinds = []string{
+	"url", "domain", "ipv4", "ipv6", "email", "registry", "path",
+	"pipe", "mutex", "useragent", "wallet", "guid",
+}
+
+var (
+	reURL      = regexp.MustCompile(`(?i)\b(?:https?|ftps?|wss?|tcp|udp|smb|file)://[^\s"'<>` + "`" + `\\^{}|]+`)
+	reDomain   = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}\b`)
+	reIPv4     = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	reIPv6     = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+	reEmail    = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}\b`)
+	reRegistry = regexp.MustCompile(`(?i)\b(?:HKEY_LOCAL_MACHINE|HKEY_CURRENT_USER|HKEY_CLASSES_ROOT|HKEY_USERS|HKEY_CURRENT_CONFIG|HKLM|HKCU|HKCR|HKU|HKCC)\\[^"<>|\x00-\x1f]*|\b(?:SOFTWARE|SYSTEM)\\(?:Microsoft|CurrentControlSet|ControlSet\d{3}|Classes|Policies|Wow6432Node)\\[^"<>|\x00-\x1f]*`)
+	rePath     = regexp.MustCompile(`(?i)(?:\b[a-z]:\\|%[a-z0-9_()]+%\\|\\\\[a-z0-9_.$?-]+\\)[a-z0-9_%$.~ ()-][^"<>|*?\x00-\x1f\x7f-\x{10FFFF}]{2,259}`)
+	rePipe     = regexp.MustCompile(`(?i)\\\\[^\\\s"<>|]+\\pipe\\[^\s"<>|]+`)
+	reMutex    = regexp.MustCompile(`(?i)\b(?:Global|Local|Session\\\d+|BaseNamedObjects)\\[^\\\s"<>|]{3,}`)
+	reUA       = regexp.MustCompile(`(?i)\b(?:Mozilla|Opera)/\d+\.\d+\s*\([^)]*\)[^\x00-\x1f"]*|\b(?:curl|Wget|python-requests|python-urllib|Go-http-client|WinHTTP|WinInet|okhttp|libwww-perl|Java)/\d[\w.\-]*`)
+	reUAHeader = regexp.MustCompile(`(?i)User-Agent:[ \t]*([!#$%&'*+.^_|~0-9a-z-]+/[!#$%&'*+.^_|~0-9a-z-]+(?:[ \t][ !#-\[\]-~]{0,480})?)`)
+	reBTC      = regexp.MustCompile(`\b[13][a-km-zA-HJ-NP-Z1-9]{25,34}\b`)
+	reBech32   = regexp.MustCompile(`(?i)\bbc1[ac-hj-np-z02-9]{11,71}\b`)
+	reETH      = regexp.MustCompile(`\b0x[a-fA-F0-9]{40}\b`)
+	reXMR      = regexp.MustCompile(`\b4[0-9AB][1-9A-HJ-NP-Za-km-z]{93}\b`)
+	reGUID     = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+)
+
+const ccTLDs = `ac ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bm bn bo br bs bt bw by bz
+ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm do dz ec ee eg er es et eu fi fj fk fm fo fr
+ga gb gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw gy hk hm hn hr ht hu id ie il im in io iq ir is it je jm jo jp
+ke kg kh ki km kn kp kr kw ky kz la lb lc li lk lr ls lt lu lv ly ma mc md me mg mh mk ml mm mn mo mp mq mr ms mt mu
+mv mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pl pm pn pr ps pt pw py qa re ro rs ru rw sa
+sb sc sd se sg sh si sk sl sm sn so sr ss st su sv sx sy sz tc td tf tg th tj tk tl tm tn to tr tt tv tw tz ua ug uk
+us uy uz va vc ve vg vi vn vu wf ws ye yt za zm zw`
+
+const gTLDs = `com net org info biz gov edu mil int arpa name pro mobi asia tel travel jobs aero coop museum cat xxx post
+onion xyz top site online club shop store tech space website live life world today link click app dev cloud icu vip
+work fun host press ltd group email support services network digital agency solutions center company systems global
+news blog win bid loan download review racing party date trade science stream men gdn cyou buzz rest monster sbs bond
+cfd lol quest autos beauty hair skin makeup`
+
+var knownTLDs = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, t := range strings.Fields(ccTLDs + " " + gTLDs) {
+		m[t] = true
+	}
+	return m
+}()
+
+// ccTLDs that are far more often file extensions in binaries (setup.py,
+// libfoo.so, messages.mo) than real domains when only two labels are present.
+var extensionLikeTLDs = map[string]bool{
+	"sh": true, "py": true, "pl": true, "so": true, "md": true, "rs": true, "ps": true,
+	"cc": true, "in": true, "am": true, "ac": true, "mo": true, "ml": true, "ai": true,
+	"cs": true, "db": true, "pm": true, "la": true, "it": true, "sc": true,
+}
+
+type indicatorSet struct {
+	byKey map[string]*Indicator
+}
+
+func (s *indicatorSet) add(kind, value string, tags []string, source string) {
+	if kind != "ipv6" {
+		value = strings.TrimRight(value, " .,;:)]}'")
+	}
+	if value == "" {
+		return
+	}
+	key := kind + "\x00" + strings.ToLower(value)
+	if it, ok := s.byKey[key]; ok {
+		if len(it.Sources) < 8 && !containsString(it.Sources, source) {
+			it.Sources = append(it.Sources, source)
+		}
+		return
+	}
+	s.byKey[key] = &Indicator{
+		Kind:     kind,
+		Value:    value,
+		Defanged: defang(kind, value),
+		Tags:     tags,
+		Sources:  []string{source},
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func extractIndicators(in []sourcedString) IndicatorReport {
+	set := &indicatorSet{byKey: make(map[string]*Indicator)}
+	for _, s := range in {
+		if len(s.Text) < 4 {
+			continue
+		}
+		scanIndicators(set, s.Text, s.Source)
+	}
+
+	var r IndicatorReport
+	order := make(map[string]int, len(indicatorKinds))
+	for i, k := range indicatorKinds {
+		order[k] = i
+	}
+	for _, it := range set.byKey {
+		r.Items = append(r.Items, *it)
+	}
+	sort.Slice(r.Items, func(i, j int) bool {
+		a, b := r.Items[i], r.Items[j]
+		if order[a.Kind] != order[b.Kind] {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return strings.ToLower(a.Value) < strings.ToLower(b.Value)
+	})
+	return r
+}
+
+func scanIndicators(set *indicatorSet, text, source string) {
+	// Each group below is skipped when the separator all of its patterns
+	// need is absent; running every regexp on every string dominates the
+	// time on large images.
+	if strings.Contains(text, "://") {
+		for _, m := range reURL.FindAllString(text, -1) {
+			if tags, ok := validURL(m); ok {
+				set.add("url", m, tags, source)
+			}
+		}
+	}
+	if strings.IndexByte(text, '@') >= 0 {
+		for _, m := range reEmail.FindAllString(text, -1) {
+			if validDomain(m[strings.IndexByte(m, '@')+1:], true) {
+				set.add("email", m, nil, source)
+			}
+		}
+	}
+	if strings.IndexByte(text, '.') >= 0 {
+		for _, m := range reDomain.FindAllString(text, -1) {
+			if validDomain(m, false) {
+				set.add("domain", strings.ToLower(m), domainTags(m), source)
+			}
+		}
+		for _, loc := range reIPv4.FindAllStringIndex(text, -1) {
+			if isDottedContinuation(text, loc[0], loc[1]) || precededByVersion(text, loc[0]) {
+				continue
+			}
+			m := text[loc[0]:loc[1]]
+			ip := net.ParseIP(m)
+			if ip == nil {
+				continue
+			}
+			set.add("ipv4", m, ipTags(ip), source)
+		}
+	}
+	if strings.Count(text, ":") >= 2 {
+		for _, m := range reIPv6.FindAllString(text, -1) {
+			if ip := net.ParseIP(m); ip != nil && ip.To4() == nil && plausibleIPv6(m) {
+				set.add("ipv6", strings.ToLower(m), ipTags(ip), source)
+			}
+		}
+	}
+	if strings.IndexByte(text, '\\') >= 0 {
+		for _, m := range reRegistry.FindAllString(text, -1) {
+			set.add("registry", m, nil, source)
+		}
+		for _, m := range rePipe.FindAllString(text, -1) {
+			set.add("pipe", m, nil, source)
+		}
+		for _, m := range rePath.FindAllString(text, -1) {
+			if rePipe.MatchString(m) {
+				continue
+			}
+			var tags []string
+			switch {
+			case strings.HasPrefix(m, `\\.\`), strings.HasPrefix(m, `\\?\`):
+				tags = []string{"device"}
+			case strings.HasPrefix(m, `\\`):
+				tags = []string{"unc"}
+			case strings.HasPrefix(m, "%"):
+				tags = []string{"env"}
+			}
+			set.add("path", m, tags, source)
+		}
+		for _, m := range reMutex.FindAllString(text, -1) {
+			set.add("mutex", m, nil, source)
+		}
+	}
+	if strings.IndexByte(text, '/') >= 0 {
+		for _, m := range reUA.FindAllString(text, -1) {
+			set.add("useragent", strings.TrimSpace(m), nil, source)
+		}
+		for _, sm := range reUAHeader.FindAllStringSubmatch(text, -1) {
+			if ua := strings.TrimSpace(sm[1]); len(ua) >= 6 && !strings.HasPrefix(ua, "%") {
+				set.add("useragent", ua, nil, source)
+			}
+		}
+	}
+	for _, m := range reBTC.FindAllString(text, -1) {
+		if validBase58Check(m, 25) {
+			set.add("wallet", m, []string{"btc"}, source)
+		}
+	}
+	for _, m := range reBech32.FindAllString(text, -1) {
+		if validBech32(m) {
+			set.add("wallet", strings.ToLower(m), []string{"btc", "bech32"}, source)
+		}
+	}
+	for _, m := range reETH.FindAllString(text, -1) {
+		set.add("wallet", m, []string{"eth"}, source)
+	}
+	for _, m := range reXMR.FindAllString(text, -1) {
+		set.add("wallet", m, []string{"xmr"}, source)
+	}
+	if strings.IndexByte(text, '-') >= 0 {
+		for _, m := range reGUID.FindAllString(text, -1) {
+			set.add("guid", strings.ToUpper(m), nil, source)
+		}
+	}
+}
+
+// isDottedContinuation rejects IPv4 matches that are part of a longer dotted
+// number such as a four-plus component version string.
+func isDottedContinuation(text string, start, end int) bool {
+	if start > 0 {
+		c := text[start-1]
+		if c == '.' || (c >= '0' && c <= '9') {
+			return true
+		}
+	}
+	if end+1 < len(text) && text[end] == '.' {
+		c := text[end+1]
+		if c >= '0' && c <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// precededByVersion reports whether the IPv4 match at start follows a
+// version keyword ("v", "ver" or "version") as a whole word, as in
+// "v1.2.3.4" or "Version: 10.0.19041.1".
+func precededByVersion(text string, start int) bool {
+	end := start
+	for end > 0 && strings.IndexByte(" =:\"'", text[end-1]) >= 0 {
+		end--
+	}
+	i := end
+	for i > 0 && (text[i-1]|0x20 >= 'a' && text[i-1]|0x20 <= 'z') {
+		i--
+	}
+	switch strings.ToLower(text[i:end]) {
+	case "v", "ver", "version":
+		return true
+	}
+	return false
+}
+
+func validURL(u string) ([]string, bool) {
+	rest := u[strings.Index(u, "://")+3:]
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	host := rest
+	if strings.HasPrefix(host, "[") {
+		if i := strings.IndexByte(host, ']'); i > 0 {
+			host = host[1:i]
+		}
+	} else if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return nil, false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ipTags(ip), true
+	}
+	if strings.EqualFold(host, "localhost") {
+		return []string{"loopback"}, true
+	}
+	return nil, validDomain(host, true)
+}
+
+// validDomain requires a known TLD. Mixed-case names such as System.Net are
+// almost always .NET namespaces rather than hosts, so they are rejected unless
+// the caller already knows the context is a host (URL or e-mail).
+func validDomain(d string, hostContext bool) bool {
+	labels := strings.Split(strings.TrimSuffix(d, "."), ".")
+	if len(labels) < 2 || len(d) > 253 {
+		return false
+	}
+	tld := strings.ToLower(labels[len(labels)-1])
+	if !knownTLDs[tld] {
+		return false
+	}
+	if hostContext {
+		return true
+	}
+	if len(labels) == 2 && extensionLikeTLDs[tld] {
+		return false
+	}
+	if strings.ToLower(d) != d && strings.ToUpper(d) != d {
+		return false
+	}
+	for _, l := range labels[:len(labels)-1] {
+		if l == "" || strings.Trim(l, "0123456789") == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Two-label names under a ccTLD or a newer gTLD collide constantly with
+// symbol and package names (errors.is, sync.mu), so they are kept but tagged.
+var classicTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "info": true, "biz": true,
+	"gov": true, "edu": true, "mil": true, "onion": true,
+}
+
+func domainTags(d string) []string {
+	labels := strings.Split(d, ".")
+	if !classicTLDs[strings.ToLower(labels[len(labels)-1])] && len(labels) == 2 {
+		return []string{"low-confidence"}
+	}
+	return nil
+}
+
+func plausibleIPv6(m string) bool {
+	groups, digits := 0, 0
+	for _, g := range strings.Split(m, ":") {
+		if g != "" {
+			groups++
+			digits += len(g)
+		}
+	}
+	return groups >= 3 && digits >= 6
+}
+
+func ipTags(ip net.IP) []string {
+	if v4 := ip.To4(); v4 != nil {
+		switch {
+		case v4.IsLoopback():
+			return []string{"loopback"}
+		case v4.IsPrivate():
+			return []string{"private"}
+		case v4.IsLinkLocalUnicast():
+			return []string{"link-local"}
+		case v4.IsMulticast():
+			return []string{"multicast"}
+		case v4[0] == 0 || v4[0] >= 240:
+			return []string{"reserved"}
+		case v4[0] == 100 && v4[1]&0xC0 == 64:
+			return []string{"cgnat"}
+		case (v4[0] == 192 && v4[1] == 0 && v4[2] == 2) ||
+			(v4[0] == 198 && v4[1] == 51 && v4[2] == 100) ||
+			(v4[0] == 203 && v4[1] == 0 && v4[2] == 113):
+			return []string{"documentation"}
+		}
+		return []string{"public"}
+	}
+	switch {
+	case ip.IsLoopback():
+		return []string{"loopback"}
+	case ip.IsUnspecified():
+		return []string{"reserved"}
+	case ip.IsPrivate():
+		return []string{"private"}
+	case ip.IsLinkLocalUnicast():
+		return []string{"link-local"}
+	case ip.IsMulticast():
+		return []string{"multicast"}
+	}
+	return []string{"public"}
+}
+
+func defang(kind, v string) string {
+	switch kind {
+	case "url":
+		i := strings.Index(v, "://")
+		scheme, rest := v[:i], v[i+3:]
+		switch strings.ToLower(scheme) {
+		case "http":
+			scheme = "hxxp"
+		case "https":
+			scheme = "hxxps"
+		case "ftp":
+			scheme = "fxp"
+		case "ftps":
+			scheme = "fxps"
+		}
+		host, path := rest, ""
+		if j := strings.IndexAny(rest, "/?#"); j >= 0 {
+			host, path = rest[:j], rest[j:]
+		}
+		return scheme + "[://]" + strings.ReplaceAll(host, ".", "[.]") + path
+	case "domain", "ipv4":
+		return strings.ReplaceAll(v, ".", "[.]")
+	case "ipv6":
+		return strings.ReplaceAll(v, ":", "[:]")
+	case "email":
+		return strings.ReplaceAll(strings.Replace(v, "@", "[@]", 1), ".", "[.]")
+	}
+	return v
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func validBase58Check(s string, wantLen int) bool {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return false
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	b := n.Bytes()
+	for _, c := range s {
+		if c != '1' {
+			break
+		}
+		b = append([]byte{0}, b...)
+	}
+	if len(b) != wantLen {
+		return false
+	}
+	payload, sum := b[:len(b)-4], b[len(b)-4:]
+	h1 := sha256.Sum256(payload)
+	h2 := sha256.Sum256(h1[:])
+	return string(h2[:4]) == string(sum)
+}
+
+func validBech32(s string) bool {
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return false
+	}
+	const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	hrp, data := s[:pos], s[pos+1:]
+	values := make([]int, 0, len(hrp)*2+1+len(data))
+	for _, c := range hrp {
+		values = append(values, int(c)>>5)
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, int(c)&31)
+	}
+	for _, c := range data {
+		i := strings.IndexRune(charset, c)
+		if i < 0 {
+			return false
+		}
+		values = append(values, i)
+	}
+	gen := [5]int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := 1
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 != 0 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	// 1 = bech32 (segwit v0), 0x2bc830a3 = bech32m (taproot).
+	return chk == 1 || chk == 0x2bc830a3
+}
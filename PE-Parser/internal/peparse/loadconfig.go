@@ -0,0 +1,364 @@
+package peparse
+
+import (
+	"debug/pe"
+	"fmt"
+)
+
+const (
+	maxGuardEntries   = 1 << 20
+	maxLoadConfigSize = 0x1000 // the largest defined layout is well under this
+)
+
+var guardFlagNames = []struct {
+	bit  uint32
+	name string
+}{
+	{0x00000100, "CF_INSTRUMENTED"},
+	{0x00000200, "CFW_INSTRUMENTED"},
+	{0x00000400, "CF_FUNCTION_TABLE_PRESENT"},
+	{0x00000800, "SECURITY_COOKIE_UNUSED"},
+	{0x00001000, "PROTECT_DELAYLOAD_IAT"},
+	{0x00002000, "DELAYLOAD_IAT_IN_ITS_OWN_SECTION"},
+	{0x00004000, "CF_EXPORT_SUPPRESSION_INFO_PRESENT"},
+	{0x00008000, "CF_ENABLE_EXPORT_SUPPRESSION"},
+	{0x00010000, "CF_LONGJUMP_TABLE_PRESENT"},
+	{0x00020000, "RF_INSTRUMENTED"},
+	{0x00040000, "RF_ENABLE"},
+	{0x00080000, "RF_STRICT"},
+	{0x00100000, "RETPOLINE_PRESENT"},
+	{0x00400000, "EH_CONTINUATION_TABLE_PRESENT"},
+	{0x00800000, "XFG_ENABLED"},
+	{0x01000000, "CASTGUARD_PRESENT"},
+	{0x02000000, "MEMCPY_PRESENT"},
+}
+
+// Symbol values of IMAGE_DYNAMIC_RELOCATION entries.
+var dynamicRelocSymbols = map[uint64]string{
+	1: "GUARD_RF_PROLOGUE",
+	2: "GUARD_RF_EPILOGUE",
+	3: "GUARD_IMPORT_CONTROL_TRANSFER",
+	4: "GUARD_INDIR_CONTROL_TRANSFER",
+	5: "GUARD_SWITCHTABLE_BRANCH",
+	6: "ARM64X",
+	7: "FUNCTION_OVERRIDE",
+	8: "ARM64_KERNEL_IMPORT_CALL_TRANSFER",
+}
+
+// GuardTarget is one entry of a CFG, long jump or EH continuation table.
+type GuardTarget struct {
+	RVA   uint32
+	Flags byte // FID_SUPPRESSED=1, EXPORT_SUPPRESSED=2, LANGEXCPTHANDLER=4, XFG=8
+	Name  string
+}
+
+type DynamicReloc struct {
+	Symbol uint64
+	Kind   string
+	Size   uint32 // bytes of fixup data following the entry header
+}
+
+type DynamicRelocTable struct {
+	RVA     uint32
+	Version uint32
+	Size    uint32
+	Entries []DynamicReloc
+}
+
+type VolatileMetadata struct {
+	VA                 uint64
+	Version            uint32
+	AccessTableRVA     uint32
+	AccessTableSize    uint32
+	InfoRangeTableRVA  uint32
+	InfoRangeTableSize uint32
+}
+
+// LoadConfigReport is IMAGE_LOAD_CONFIG_DIRECTORY32/64. Only the fields that
+// fit inside the structure's own Size are read; pointer fields are VAs.
+type LoadConfigReport struct {
+	Present       bool
+	DirRVA        uint32
+	Size          uint32
+	TimeDateStamp uint32
+
+	SecurityCookie uint64
+	SEHandlerTable uint64
+	SEHandlerCount uint64
+	SEHandlers     []uint32
+
+	GuardCFCheckFunctionPointer    uint64
+	GuardCFDispatchFunctionPointer uint64
+	GuardCFFunctionTable           uint64
+	GuardCFFunctionCount           uint64
+	GuardFlags                     uint32
+	GuardFlagNames                 []string
+	GuardCFTargets                 []GuardTarget
+
+	GuardLongJumpTargetTable uint64
+	GuardLongJumpTargetCount uint64
+	LongJumpTargets          []GuardTarget
+
+	GuardEHContinuationTable uint64
+	GuardEHContinuationCount uint64
+	EHContinuationTargets    []GuardTarget
+
+	CHPEMetadataPointer uint64
+	DynamicRelocs       *DynamicRelocTable
+	Volatile            *VolatileMetadata
+	Note                string
+}
+
+// loadConfigReader reads fields by their PE32 / PE32+ offsets and reports
+// them missing when they lie beyond the declared structure size.
+type loadConfigReader struct {
+	bin  []byte
+	size uint32
+	is64 bool
+}
+
+func (lc loadConfigReader) u16(off32, off64 uint32) uint16 {
+	o := off32
+	if lc.is64 {
+		o = off64
+	}
+	if o+2 > lc.size {
+		return 0
+	}
+	v, _ := readU16(lc.bin, o)
+	return v
+}
+
+func (lc loadConfigReader) u32(off32, off64 uint32) uint32 {
+	o := off32
+	if lc.is64 {
+		o = off64
+	}
+	if o+4 > lc.size {
+		return 0
+	}
+	v, _ := readU32(lc.bin, o)
+	return v
+}
+
+func (lc loadConfigReader) ptr(off32, off64 uint32) uint64 {
+	if !lc.is64 {
+		return uint64(lc.u32(off32, off32))
+	}
+	if off64+8 > lc.size {
+		return 0
+	}
+	v, _ := readU64(lc.bin, off64)
+	return v
+}
+
+func parseLoadConfig(f *pe.File, as *addrSpace, bin []byte, exports []ExportSymbol) LoadConfigReport {
+	var r LoadConfigReport
+	is64, oh32, oh64 := getOptional(f)
+	var imageBase uint64
+	switch {
+	case oh32 != nil:
+		imageBase = uint64(oh32.ImageBase)
+	case oh64 != nil:
+		imageBase = oh64.ImageBase
+	default:
+		return r
+	}
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG)
+	if dir.VirtualAddress == 0 {
+		return r
+	}
+	r.Present = true
+	r.DirRVA = dir.VirtualAddress
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "load config " + err.Error()
+		return r
+	}
+	size, ok := memU32(as, bin, dir.VirtualAddress)
+	if !ok {
+		r.Note = "truncated load config"
+		return r
+	}
+	r.Size = size
+	if size > maxLoadConfigSize {
+		size = maxLoadConfigSize
+		r.Note = appendNote(r.Note, fmt.Sprintf("implausible load config size 0x%X; read 0x%X bytes", r.Size, size))
+	}
+	buf := readMemPrefix(as, bin, dir.VirtualAddress, size)
+	if uint32(len(buf)) < size {
+		r.Note = appendNote(r.Note, "load config extends past end of file")
+	}
+	lc := loadConfigReader{bin: buf, size: uint32(len(buf)), is64: is64}
+
+	r.TimeDateStamp = lc.u32(4, 4)
+	r.SecurityCookie = lc.ptr(60, 88)
+	r.SEHandlerTable = lc.ptr(64, 96)
+	r.SEHandlerCount = lc.ptr(68, 104)
+	r.GuardCFCheckFunctionPointer = lc.ptr(72, 112)
+	r.GuardCFDispatchFunctionPointer = lc.ptr(76, 120)
+	r.GuardCFFunctionTable = lc.ptr(80, 128)
+	r.GuardCFFunctionCount = lc.ptr(84, 136)
+	r.GuardFlags = lc.u32(88, 144)
+	r.GuardLongJumpTargetTable = lc.ptr(112, 176)
+	r.GuardLongJumpTargetCount = lc.ptr(116, 184)
+	dynRelocVA := lc.ptr(120, 192)
+	r.CHPEMetadataPointer = lc.ptr(124, 200)
+	dynRelocOff := lc.u32(136, 224)
+	dynRelocSec := lc.u16(140, 228)
+	volatileVA := lc.ptr(160, 256)
+	r.GuardEHContinuationTable = lc.ptr(164, 264)
+	r.GuardEHContinuationCount = lc.ptr(168, 272)
+
+	for _, g := range guardFlagNames {
+		if r.GuardFlags&g.bit != 0 {
+			r.GuardFlagNames = append(r.GuardFlagNames, g.name)
+		}
+	}
+
+	names := make(map[uint32]string)
+	for _, s := range exports {
+		if s.Name != "" && s.Forwarder == "" {
+			names[s.RVA] = s.Name
+		}
+	}
+	// Each guard table entry is an RVA followed by n metadata bytes, n being
+	// the top nibble of GuardFlags.
+	stride := 4 + (r.GuardFlags >> 28)
+	table := func(what string, va, count uint64) []GuardTarget {
+		if va == 0 || count == 0 {
+			return nil
+		}
+		if count > maxGuardEntries {
+			r.Note = appendNote(r.Note, fmt.Sprintf("%s count %d truncated", what, count))
+			count = maxGuardEntries
+		}
+		if _, ok := vaToOff(as, va, imageBase); !ok {
+			r.Note = appendNote(r.Note, what+" table outside all sections")
+			return nil
+		}
+		tbl := readMemPrefix(as, bin, uint32(va-imageBase), uint32(count)*stride)
+		var out []GuardTarget
+		for i := uint32(0); i < uint32(count); i++ {
+			e := i * stride
+			rva, ok := readU32(tbl, e)
+			if !ok {
+				r.Note = appendNote(r.Note, what+" table truncated")
+				break
+			}
+			t := GuardTarget{RVA: rva, Name: names[rva]}
+			if stride > 4 && int(e)+4 < len(tbl) {
+				t.Flags = tbl[e+4]
+			}
+			out = append(out, t)
+		}
+		return out
+	}
+	r.GuardCFTargets = table("CFG function", r.GuardCFFunctionTable, r.GuardCFFunctionCount)
+	r.LongJumpTargets = table("long jump", r.GuardLongJumpTargetTable, r.GuardLongJumpTargetCount)
+	r.EHContinuationTargets = table("EH continuation", r.GuardEHContinuationTable, r.GuardEHContinuationCount)
+
+	// SafeSEH handlers are plain RVAs with no metadata bytes.
+	if !is64 && r.SEHandlerTable != 0 && r.SEHandlerCount != 0 {
+		if _, ok := vaToOff(as, r.SEHandlerTable, imageBase); ok {
+			n := uint32(r.SEHandlerCount)
+			if r.SEHandlerCount > maxGuardEntries {
+				n = maxGuardEntries
+			}
+			tbl := readMemPrefix(as, bin, uint32(r.SEHandlerTable-imageBase), n*4)
+			for i := uint32(0); i < n; i++ {
+				rva, ok := readU32(tbl, i*4)
+				if !ok {
+					break
+				}
+				r.SEHandlers = append(r.SEHandlers, rva)
+			}
+		} else {
+			r.Note = appendNote(r.Note, "SEHandlerTable outside all sections")
+		}
+	}
+
+	// The dynamic relocation table is located by section and offset on
+	// newer linkers, by VA on older ones.
+	var dynRVA uint32
+	switch {
+	case dynRelocSec != 0 && int(dynRelocSec) <= len(f.Sections):
+		dynRVA = f.Sections[dynRelocSec-1].VirtualAddress + dynRelocOff
+	case dynRelocVA > imageBase:
+		dynRVA = uint32(dynRelocVA - imageBase)
+	}
+	if dynRVA != 0 {
+		r.DynamicRelocs = parseDynamicRelocs(as, bin, dynRVA, is64)
+	}
+	if volatileVA > imageBase {
+		if _, ok := vaToOff(as, volatileVA, imageBase); ok {
+			b := readMemPrefix(as, bin, uint32(volatileVA-imageBase), 24)
+			v := &VolatileMetadata{VA: volatileVA}
+			v.Version, _ = readU32(b, 4)
+			v.AccessTableRVA, _ = readU32(b, 8)
+			v.AccessTableSize, _ = readU32(b, 12)
+			v.InfoRangeTableRVA, _ = readU32(b, 16)
+			v.InfoRangeTableSize, _ = readU32(b, 20)
+			r.Volatile = v
+		}
+	}
+	return r
+}
+
+func parseDynamicRelocs(as *addrSpace, bin []byte, rva uint32, is64 bool) *DynamicRelocTable {
+	t := &DynamicRelocTable{RVA: rva}
+	if _, ok := rvaToOff(as, rva); !ok {
+		return t
+	}
+	t.Version, _ = memU32(as, bin, rva)
+	t.Size, _ = memU32(as, bin, rva+4)
+	tbl := readMemPrefix(as, bin, rva+8, t.Size)
+	p, end := uint32(0), uint32(len(tbl))
+	for p < end && len(t.Entries) < 256 {
+		var e DynamicReloc
+		var hdr uint32
+		switch t.Version {
+		case 1:
+			// IMAGE_DYNAMIC_RELOCATION32/64: Symbol, BaseRelocSize (packed).
+			if is64 {
+				e.Symbol, _ = readU64(tbl, p)
+				e.Size, _ = readU32(tbl, p+8)
+				hdr = 12
+			} else {
+				s, _ := readU32(tbl, p)
+				e.Symbol = uint64(s)
+				e.Size, _ = readU32(tbl, p+4)
+				hdr = 8
+			}
+		case 2:
+			// IMAGE_DYNAMIC_RELOCATION32/64_V2: HeaderSize, FixupInfoSize, Symbol, ...
+			hdr, _ = readU32(tbl, p)
+			e.Size, _ = readU32(tbl, p+4)
+			if is64 {
+				e.Symbol, _ = readU64(tbl, p+8)
+			} else {
+				s, _ := readU32(tbl, p+8)
+				e.Symbol = uint64(s)
+			}
+		default:
+			return t
+		}
+		if hdr == 0 {
+			break
+		}
+		e.Kind = dynamicRelocSymbols[e.Symbol]
+		if e.Kind == "" {
+			e.Kind = fmt.Sprintf("symbol 0x%X", e.Symbol)
+		}
+		t.Entries = append(t.Entries, e)
+		p += hdr + e.Size
+	}
+	return t
+}
+
+// vaToOff converts a VA based on the preferred ImageBase to a file offset.
+func vaToOff(as *addrSpace, va, imageBase uint64) (uint32, bool) {
+	if va <= imageBase || va-imageBase >= 1<<32 {
+		return 0, false
+	}
+	return rvaToOff(as, uint32(va-imageBase))
+}
@@ -0,0 +1,199 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+)
+
+// MapOptions controls MapImage. With Rebase set, base relocations are
+// applied as if the image had been loaded at Base.
+type MapOptions struct {
+	Base   uint64
+	Rebase bool
+}
+
+// MappedImage is the in-memory layout of a PE as the loader builds it.
+type MappedImage struct {
+	Data      []byte
+	ImageBase uint64 // base the image is laid out for
+	Applied   int    // relocations applied
+	Notes     []string
+}
+
+// MapImage lays out path the way the Windows loader maps it: headers at
+// offset 0, each section at its RVA, raw data truncated to VirtualSize and
+// the remainder of each section zero-filled.
+func MapImage(path string, opts MapOptions) (*MappedImage, error) {
+	f, bin, _, err := openImage(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return mapImage(f, newAddrSpace(f), bin, opts)
+}
+
+func mapImage(f *pe.File, as *addrSpace, bin []byte, opts MapOptions) (*MappedImage, error) {
+	is64, oh32, oh64 := getOptional(f)
+	var imageBase uint64
+	var sizeOfImage, secAlign uint32
+	switch {
+	case oh32 != nil:
+		imageBase = uint64(oh32.ImageBase)
+		sizeOfImage, secAlign = oh32.SizeOfImage, oh32.SectionAlignment
+	case oh64 != nil:
+		imageBase = oh64.ImageBase
+		sizeOfImage, secAlign = oh64.SizeOfImage, oh64.SectionAlignment
+	default:
+		return nil, fmt.Errorf("no optional header")
+	}
+	if secAlign == 0 {
+		secAlign = 0x1000
+	}
+	size := alignUp(sizeOfImage, secAlign)
+	if size == 0 || size > 1<<30 {
+		return nil, fmt.Errorf("implausible SizeOfImage 0x%X", sizeOfImage)
+	}
+
+	m := &MappedImage{Data: make([]byte, size), ImageBase: imageBase}
+	// Headers first, then sections in table order so that a later section
+	// overwrites an earlier one it overlaps, as the loader does.
+	regions := as.regions
+	for i := len(regions) - 1; i >= 0; i-- {
+		r := regions[i]
+		n := r.fileSize
+		if n == 0 {
+			continue
+		}
+		if uint64(r.raw) >= uint64(len(bin)) {
+			m.Notes = append(m.Notes, r.name+": raw data starts past end of file; left zero-filled")
+			continue
+		}
+		if uint64(r.raw)+uint64(n) > uint64(len(bin)) {
+			n = uint32(len(bin)) - r.raw
+			m.Notes = append(m.Notes, r.name+": raw data truncated by end of file")
+		}
+		if uint64(r.va)+uint64(n) > uint64(size) {
+			m.Notes = append(m.Notes, r.name+": extends past SizeOfImage")
+			if r.va >= size {
+				continue
+			}
+			n = size - r.va
+		}
+		copy(m.Data[r.va:], bin[r.raw:r.raw+n])
+	}
+
+	if opts.Rebase && opts.Base != imageBase {
+		rel := parseRelocs(f, as, bin)
+		if rel.Total == 0 {
+			return nil, fmt.Errorf("image has no base relocations; it cannot be rebased")
+		}
+		var notes []string
+		m.Applied, notes = applyRelocs(m.Data, rel, opts.Base-imageBase)
+		m.Notes = append(m.Notes, notes...)
+		m.ImageBase = opts.Base
+		// The loader also updates ImageBase in the mapped headers.
+		setImageBase(m.Data, opts.Base, is64)
+	}
+	return m, nil
+}
+
+// applyRelocs adds delta at every relocation target in img, which must be
+// in memory layout, and returns the number applied and a note per skipped
+// type.
+func applyRelocs(img []byte, rel RelocReport, delta uint64) (int, []string) {
+	n := 0
+	skipped := make(map[string]int)
+	var order []string
+	for _, b := range rel.Blocks {
+		for _, e := range b.Entries {
+			at := uint64(e.RVA)
+			switch e.Type {
+			case relBasedHighLow:
+				if at+4 <= uint64(len(img)) {
+					v := binary.LittleEndian.Uint32(img[at:])
+					binary.LittleEndian.PutUint32(img[at:], v+uint32(delta))
+					n++
+					continue
+				}
+			case relBasedDir64:
+				if at+8 <= uint64(len(img)) {
+					v := binary.LittleEndian.Uint64(img[at:])
+					binary.LittleEndian.PutUint64(img[at:], v+delta)
+					n++
+					continue
+				}
+			case relBasedHigh:
+				if at+2 <= uint64(len(img)) {
+					v := binary.LittleEndian.Uint16(img[at:])
+					binary.LittleEndian.PutUint16(img[at:], v+uint16(uint32(delta)>>16))
+					n++
+					continue
+				}
+			case relBasedLow:
+				if at+2 <= uint64(len(img)) {
+					v := binary.LittleEndian.Uint16(img[at:])
+					binary.LittleEndian.PutUint16(img[at:], v+uint16(delta))
+					n++
+					continue
+				}
+			}
+			if skipped[e.Name] == 0 {
+				order = append(order, e.Name)
+			}
+			skipped[e.Name]++
+		}
+	}
+	var notes []string
+	for _, name := range order {
+		notes = append(notes, fmt.Sprintf("%d %s relocations not applied", skipped[name], name))
+	}
+	return n, notes
+}
+
+// setImageBase writes base into OptionalHeader.ImageBase of b.
+func setImageBase(b []byte, base uint64, is64 bool) {
+	off, ok := imageBaseOffset(b)
+	if !ok {
+		return
+	}
+	if is64 {
+		binary.LittleEndian.PutUint64(b[off:], base)
+	} else {
+		binary.LittleEndian.PutUint32(b[off:], uint32(base))
+	}
+}
+
+// imageBaseOffset returns the file offset of OptionalHeader.ImageBase.
+func imageBaseOffset(b []byte) (uint32, bool) {
+	lfanew, ok := readU32(b, 0x3C)
+	if !ok {
+		return 0, false
+	}
+	opt := lfanew + 24
+	magic, ok := readU16(b, opt)
+	if !ok {
+		return 0, false
+	}
+	switch magic {
+	case 0x10B:
+		return opt + 28, opt+32 <= uint32(len(b))
+	case 0x20B:
+		return opt + 24, opt+32 <= uint32(len(b))
+	}
+	return 0, false
+}
+
+func alignUp(v, a uint32) uint32 {
+	if a == 0 {
+		return v
+	}
+	return (v + a - 1) &^ (a - 1)
+}
+
+func sectionName(s *pe.Section) string {
+	if s.Name == "" {
+		return "(unnamed)"
+	}
+	return s.Name
+}
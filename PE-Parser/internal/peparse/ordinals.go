@@ -0,0 +1,288 @@
+package peparse
+
+import (
+	"debug/pe"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OrdinalDB maps a lower-case DLL name to its export ordinals.
+type OrdinalDB map[string]map[uint16]string
+
+// Winsock 1.1 ordinals are shared between wsock32 and ws2_32.
+var winsockOrdinals = map[uint16]string{
+	1: "accept", 2: "bind", 3: "closesocket", 4: "connect", 5: "getpeername", 6: "getsockname",
+	7: "getsockopt", 8: "htonl", 9: "htons", 10: "ioctlsocket", 11: "inet_addr", 12: "inet_ntoa",
+	13: "listen", 14: "ntohl", 15: "ntohs", 16: "recv", 17: "recvfrom", 18: "select", 19: "send",
+	20: "sendto", 21: "setsockopt", 22: "shutdown", 23: "socket",
+	51: "gethostbyaddr", 52: "gethostbyname", 53: "getprotobyname", 54: "getprotobynumber",
+	55: "getservbyname", 56: "getservbyport", 57: "gethostname",
+	101: "WSAAsyncSelect", 102: "WSAAsyncGetHostByAddr", 103: "WSAAsyncGetHostByName",
+	104: "WSAAsyncGetProtoByNumber", 105: "WSAAsyncGetProtoByName", 106: "WSAAsyncGetServByPort",
+	107: "WSAAsyncGetServByName", 108: "WSACancelAsyncRequest", 109: "WSASetBlockingHook",
+	110: "WSAUnhookBlockingHook", 111: "WSAGetLastError", 112: "WSASetLastError",
+	113: "WSACancelBlockingCall", 114: "WSAIsBlocking", 115: "WSAStartup", 116: "WSACleanup",
+	151: "__WSAFDIsSet",
+}
+
+var ws2Ordinals = map[uint16]string{
+	24: "GetAddrInfoW", 25: "GetNameInfoW", 26: "WSApSetPostRoutine", 27: "FreeAddrInfoW",
+	28: "WPUCompleteOverlappedRequest", 29: "WSAAccept", 30: "WSAAddressToStringA",
+	31: "WSAAddressToStringW", 32: "WSACloseEvent", 33: "WSAConnect", 34: "WSACreateEvent",
+	35: "WSADuplicateSocketA", 36: "WSADuplicateSocketW", 37: "WSAEnumNameSpaceProvidersA",
+	38: "WSAEnumNameSpaceProvidersW", 39: "WSAEnumNetworkEvents", 40: "WSAEnumProtocolsA",
+	41: "WSAEnumProtocolsW", 42: "WSAEventSelect", 43: "WSAGetOverlappedResult",
+	44: "WSAGetQOSByName", 45: "WSAGetServiceClassInfoA", 46: "WSAGetServiceClassInfoW",
+	47: "WSAGetServiceClassNameByClassIdA", 48: "WSAGetServiceClassNameByClassIdW",
+	49: "WSAHtonl", 50: "WSAHtons", 58: "WSAInstallServiceClassA", 59: "WSAInstallServiceClassW",
+	60: "WSAIoctl", 61: "WSAJoinLeaf", 62: "WSALookupServiceBeginA", 63: "WSALookupServiceBeginW",
+	64: "WSALookupServiceEnd", 65: "WSALookupServiceNextA", 66: "WSALookupServiceNextW",
+	67: "WSANSPIoctl", 68: "WSANtohl", 69: "WSANtohs", 70: "WSAProviderConfigChange",
+	71: "WSARecv", 72: "WSARecvDisconnect", 73: "WSARecvFrom", 74: "WSARemoveServiceClass",
+	75: "WSAResetEvent", 76: "WSASend", 77: "WSASendDisconnect", 78: "WSASendTo",
+	79: "WSASetEvent", 80: "WSASetServiceA", 81: "WSASetServiceW", 82: "WSASocketA",
+	83: "WSASocketW", 84: "WSAStringToAddressA", 85: "WSAStringToAddressW",
+	86: "WSAWaitForMultipleEvents", 87: "WSCDeinstallProvider", 88: "WSCEnableNSProvider",
+	89: "WSCEnumProtocols", 90: "WSCGetProviderPath", 91: "WSCInstallNameSpace",
+	92: "WSCInstallProvider", 93: "WSCUnInstallNameSpace", 94: "WSCUpdateProvider",
+	95: "WSCWriteNameSpaceOrder", 96: "WSCWriteProviderOrder", 97: "freeaddrinfo",
+	98: "getaddrinfo", 99: "getnameinfo", 500: "WEP",
+}
+
+var wsock32Ordinals = map[uint16]string{
+	1000: "WSApSetPostRoutine", 1100: "inet_network", 1101: "getnetbyname", 1102: "rcmd",
+	1103: "rexec", 1104: "rresvport", 1105: "sethostname", 1106: "dn_expand", 1107: "WSARecvEx",
+	1108: "s_perror", 1109: "GetAddressByNameA", 1110: "GetAddressByNameW", 1111: "EnumProtocolsA",
+	1112: "EnumProtocolsW", 1113: "GetTypeByNameA", 1114: "GetTypeByNameW", 1115: "GetNameByTypeA",
+	1116: "GetNameByTypeW", 1117: "SetServiceA", 1118: "SetServiceW", 1119: "GetServiceA",
+	1120: "GetServiceW", 1130: "NPLoadNameSpaces", 1140: "TransmitFile", 1141: "AcceptEx",
+	1142: "GetAcceptExSockaddrs",
+}
+
+var oleaut32Ordinals = map[uint16]string{
+	2: "SysAllocString", 3: "SysReAllocString", 4: "SysAllocStringLen", 5: "SysReAllocStringLen",
+	6: "SysFreeString", 7: "SysStringLen", 8: "VariantInit", 9: "VariantClear", 10: "VariantCopy",
+	11: "VariantCopyInd", 12: "VariantChangeType", 13: "VariantTimeToDosDateTime",
+	14: "DosDateTimeToVariantTime", 15: "SafeArrayCreate", 16: "SafeArrayDestroy",
+	17: "SafeArrayGetDim", 18: "SafeArrayGetElemsize", 19: "SafeArrayGetUBound",
+	20: "SafeArrayGetLBound", 21: "SafeArrayLock", 22: "SafeArrayUnlock", 23: "SafeArrayAccessData",
+	24: "SafeArrayUnaccessData", 25: "SafeArrayGetElement", 26: "SafeArrayPutElement",
+	27: "SafeArrayCopy", 28: "DispGetParam", 29: "DispGetIDsOfNames", 30: "DispInvoke",
+	31: "CreateDispTypeInfo", 32: "CreateStdDispatch", 33: "RegisterActiveObject",
+	34: "RevokeActiveObject", 35: "GetActiveObject", 36: "SafeArrayAllocDescriptor",
+	37: "SafeArrayAllocData", 38: "SafeArrayDestroyDescriptor", 39: "SafeArrayDestroyData",
+	40: "SafeArrayRedim", 41: "SafeArrayAllocDescriptorEx", 42: "SafeArrayCreateEx",
+	43: "SafeArrayCreateVectorEx", 44: "SafeArraySetRecordInfo", 45: "SafeArrayGetRecordInfo",
+	46: "VarParseNumFromStr", 47: "VarNumFromParseNum", 48: "VarI2FromUI1", 49: "VarI2FromI4",
+	50: "VarI2FromR4", 51: "VarI2FromR8", 52: "VarI2FromCy", 53: "VarI2FromDate", 54: "VarI2FromStr",
+	55: "VarI2FromDisp", 56: "VarI2FromBool", 57: "SafeArraySetIID", 58: "VarI4FromUI1",
+	59: "VarI4FromI2", 60: "VarI4FromR4", 61: "VarI4FromR8", 62: "VarI4FromCy", 63: "VarI4FromDate",
+	64: "VarI4FromStr", 65: "VarI4FromDisp", 66: "VarI4FromBool", 67: "SafeArrayGetIID",
+	68: "VarR4FromUI1", 69: "VarR4FromI2", 70: "VarR4FromI4", 71: "VarR4FromR8", 72: "VarR4FromCy",
+	73: "VarR4FromDate", 74: "VarR4FromStr", 75: "VarR4FromDisp", 76: "VarR4FromBool",
+	77: "SafeArrayGetVartype", 78: "VarR8FromUI1", 79: "VarR8FromI2", 80: "VarR8FromI4",
+	81: "VarR8FromR4", 82: "VarR8FromCy", 83: "VarR8FromDate", 84: "VarR8FromStr",
+	85: "VarR8FromDisp", 86: "VarR8FromBool", 87: "VarFormat", 88: "VarDateFromUI1",
+	89: "VarDateFromI2", 90: "VarDateFromI4", 91: "VarDateFromR4", 92: "VarDateFromR8",
+	93: "VarDateFromCy", 94: "VarDateFromStr", 95: "VarDateFromDisp", 96: "VarDateFromBool",
+	97: "VarFormatDateTime", 98: "VarCyFromUI1", 99: "VarCyFromI2", 100: "VarCyFromI4",
+	101: "VarCyFromR4", 102: "VarCyFromR8", 103: "VarCyFromDate", 104: "VarCyFromStr",
+	105: "VarCyFromDisp", 106: "VarCyFromBool", 107: "VarFormatNumber", 108: "VarBstrFromUI1",
+	109: "VarBstrFromI2", 110: "VarBstrFromI4", 111: "VarBstrFromR4", 112: "VarBstrFromR8",
+	113: "VarBstrFromCy", 114: "VarBstrFromDate", 115: "VarBstrFromDisp", 116: "VarBstrFromBool",
+	117: "VarFormatPercent", 118: "VarBoolFromUI1", 119: "VarBoolFromI2", 120: "VarBoolFromI4",
+	121: "VarBoolFromR4", 122: "VarBoolFromR8", 123: "VarBoolFromDate", 124: "VarBoolFromCy",
+	125: "VarBoolFromStr", 126: "VarBoolFromDisp", 127: "VarFormatCurrency",
+	146: "DispCallFunc", 147: "VariantChangeTypeEx", 148: "SafeArrayPtrOfIndex",
+	149: "SysStringByteLen", 150: "SysAllocStringByteLen", 161: "CreateTypeLib", 162: "LoadTypeLib",
+	163: "LoadRegTypeLib", 164: "RegisterTypeLib", 165: "QueryPathOfRegTypeLib",
+	166: "LHashValOfNameSys", 167: "LHashValOfNameSysA", 170: "OaBuildVersion",
+	180: "CreateTypeLib2", 183: "LoadTypeLibEx", 184: "SystemTimeToVariantTime",
+	185: "VariantTimeToSystemTime", 186: "UnRegisterTypeLib", 200: "GetErrorInfo",
+	201: "SetErrorInfo", 202: "CreateErrorInfo",
+}
+
+var comctl32Ordinals = map[uint16]string{
+	2: "MenuHelp", 3: "ShowHideMenuCtl", 4: "GetEffectiveClientRect", 5: "DrawStatusTextA",
+	6: "CreateStatusWindowA", 7: "CreateToolbar", 8: "CreateMappedBitmap", 9: "DPA_LoadStream",
+	10: "DPA_SaveStream", 11: "DPA_Merge", 13: "MakeDragList", 14: "LBItemFromPt", 15: "DrawInsert",
+	16: "CreateUpDownControl", 17: "InitCommonControls", 71: "Alloc", 72: "ReAlloc", 73: "Free",
+	74: "GetSize", 151: "CreateMRUListA", 152: "FreeMRUList", 153: "AddMRUStringA",
+	154: "EnumMRUListA", 155: "FindMRUStringA", 156: "DelMRUString", 157: "CreateMRUListLazyA",
+	320: "DSA_Create", 321: "DSA_Destroy", 322: "DSA_GetItem", 323: "DSA_GetItemPtr",
+	324: "DSA_InsertItem", 325: "DSA_SetItem", 326: "DSA_DeleteItem", 327: "DSA_DeleteAllItems",
+	328: "DPA_Create", 329: "DPA_Destroy", 330: "DPA_Grow", 331: "DPA_Clone", 332: "DPA_GetPtr",
+	333: "DPA_GetPtrIndex", 334: "DPA_InsertPtr", 335: "DPA_SetPtr", 336: "DPA_DeletePtr",
+	337: "DPA_DeleteAllPtrs", 338: "DPA_Sort", 339: "DPA_Search", 340: "DPA_CreateEx",
+	385: "DPA_EnumCallback", 386: "DPA_DestroyCallback", 387: "DSA_EnumCallback",
+	388: "DSA_DestroyCallback", 410: "SetWindowSubclass", 411: "GetWindowSubclass",
+	412: "RemoveWindowSubclass", 413: "DefSubclassProc",
+}
+
+var shell32Ordinals = map[uint16]string{
+	16: "ILFindLastID", 17: "ILRemoveLastID", 18: "ILClone", 19: "ILCloneFirst", 21: "ILIsEqual",
+	23: "ILIsParent", 24: "ILFindChild", 25: "ILCombine", 60: "ExitWindowsDialog", 61: "RunFileDlg",
+	62: "PickIconDlg", 152: "ILGetSize", 153: "ILGetNext", 154: "ILAppendID", 155: "ILFree",
+	165: "SHCreateDirectory", 175: "SHGetSpecialFolderPathW", 189: "ILCreateFromPathA",
+	190: "ILCreateFromPathW", 680: "IsUserAnAdmin",
+}
+
+// builtinOrdinals covers DLLs commonly imported by ordinal. MFC is not
+// built in: its DLLs export by ordinal only (NONAME) and the numbering
+// changes with every build, so MFC names come from the .def files shipped
+// with the MFC sources (mfc42.def, mfc140u.def, ...) via LoadOrdinalDir.
+func builtinOrdinals() OrdinalDB {
+	return OrdinalDB{
+		"ws2_32.dll":   merged(winsockOrdinals, ws2Ordinals),
+		"wsock32.dll":  merged(winsockOrdinals, wsock32Ordinals),
+		"oleaut32.dll": merged(oleaut32Ordinals),
+		"comctl32.dll": merged(comctl32Ordinals),
+		"shell32.dll":  merged(shell32Ordinals),
+	}
+}
+
+func merged(maps ...map[uint16]string) map[uint16]string {
+	out := make(map[uint16]string)
+	for _, m := range maps {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// LoadOrdinalDir adds the named exports of every DLL in dir, and the
+// EXPORTS of every module-definition (.def) file, to db, taking precedence
+// over the built-in tables.
+func (db OrdinalDB) LoadOrdinalDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".def":
+			if dll, m := readDefOrdinals(path); len(m) > 0 {
+				db[dll] = m
+			}
+		case ".dll":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			f, err := pe.Open(path)
+			if err != nil {
+				continue
+			}
+			exp := parseExports(f, newAddrSpace(f), data)
+			f.Close()
+			m := make(map[uint16]string, len(exp.Symbols))
+			for _, s := range exp.Symbols {
+				if s.Name != "" {
+					m[s.Ordinal] = s.Name
+				}
+			}
+			if len(m) > 0 {
+				db[strings.ToLower(e.Name())] = m
+			}
+		}
+	}
+	return nil
+}
+
+// readDefOrdinals reads the EXPORTS of a module-definition file, lines such
+// as "?Foo@@YAXXZ @ 1234 NONAME". The DLL is named by the LIBRARY statement,
+// or else after the file.
+func readDefOrdinals(path string) (string, map[uint16]string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+	dll := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	m := make(map[uint16]string)
+	inExports := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "LIBRARY":
+			if len(fields) > 1 {
+				dll = strings.Trim(fields[1], `"`)
+			}
+			inExports = false
+			continue
+		case "EXPORTS":
+			inExports = true
+			fields = fields[1:]
+		case "NAME", "DESCRIPTION", "VERSION", "HEAPSIZE", "STACKSIZE", "SECTIONS", "STUB":
+			inExports = false
+			continue
+		}
+		if !inExports || len(fields) < 2 {
+			continue
+		}
+		name := fields[0]
+		if i := strings.IndexByte(name, '='); i > 0 {
+			name = name[:i] // exported=internal
+		}
+		for i, fld := range fields[1:] {
+			if !strings.HasPrefix(fld, "@") {
+				continue
+			}
+			num := fld[1:]
+			if num == "" && i+2 < len(fields) {
+				num = fields[i+2]
+			}
+			if n, err := strconv.ParseUint(num, 10, 16); err == nil {
+				m[uint16(n)] = name
+			}
+			break
+		}
+	}
+	dll = strings.ToLower(dll)
+	if filepath.Ext(dll) == "" {
+		dll += ".dll"
+	}
+	return dll, m
+}
+
+// resolveOrdinals names imports by ordinal from db. The ordinal itself is
+// kept on the record.
+func resolveOrdinals(imp *ImportReport, db OrdinalDB) {
+	resolve := func(dll string, funcs []ImportFunc) {
+		key := strings.ToLower(dll)
+		if filepath.Ext(key) == "" {
+			key += ".dll"
+		}
+		m := db[key]
+		if m == nil {
+			return
+		}
+		for i := range funcs {
+			if !funcs[i].ByOrdinal {
+				continue
+			}
+			if name, ok := m[funcs[i].Ordinal]; ok {
+				funcs[i].Name = name
+				funcs[i].Resolved = true
+			}
+		}
+	}
+	for _, d := range imp.DLLs {
+		resolve(d.Name, d.Functions)
+	}
+	for _, d := range imp.Delay {
+		resolve(d.Name, d.Functions)
+	}
+}
@@ -3,7 +3,6 @@ package peparse
 import (
 	"debug/pe"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -24,6 +23,24 @@ type Options struct {
 	AutoInstall bool
 	AssumeYes   bool
 
+	Indicators   bool
+	Decode       bool
+	StackStrings bool
+
+	DisasmCount int
+	OrdinalDir  string
+	APISetPath  string
+
+	DepDirs   []string
+	DepDepth  int
+	Functions bool
+
+	Hijack        bool
+	KnownDLLsPath string
+	RefDLLPath    string
+
+	SymbolDir string // local symbol store searched for the matching PDB
+
 	Quiet bool
 }
 
@@ -32,6 +49,14 @@ type RankedString struct {
 	Score *float64
 }
 
+type StackString struct {
+	Text    string
+	Wide    bool
+	RVA     uint32
+	VA      uint64
+	FuncRVA uint32
+}
+
 type SectionReport struct {
 	Index          int
 	Name           string
@@ -42,9 +67,11 @@ type SectionReport struct {
 	HexDump        string
 	Truncated      bool
 
-	Strings  []string
-	Ranked   []RankedString
-	RankNote string
+	Strings      []string
+	WideStrings  []string
+	StackStrings []StackString
+	Ranked       []RankedString
+	RankNote     string
 }
 
 type HeaderReport struct {
@@ -54,26 +81,48 @@ type HeaderReport struct {
 	EntryPointRVA  uint32
 	EntryPointVA   uint64
 	OptionalFlavor string
+	Layout         Layout // mapped inputs are realigned to file layout before parsing
+}
+
+// ImportFunc is one INT/IAT entry. Thunk is the INT value as stored in the
+// file: a hint/name RVA, or the ordinal with the high bit set.
+type ImportFunc struct {
+	Name      string
+	Hint      uint16
+	Ordinal   uint16
+	ByOrdinal bool
+	Resolved  bool // Name was looked up from the ordinal
+	Thunk     uint64
+	IATRVA    uint32
+	IATVA     uint64
 }
 
 type ImportDLL struct {
 	Name      string
-	Functions []string
+	Host      string // host DLL when Name is an API set
+	Functions []ImportFunc
+	IATRVA    uint32
 }
 type ImportReport struct {
-	DLLs []ImportDLL
-	Note string
+	DLLs  []ImportDLL
+	Delay []DelayImportDLL
+	Bound []BoundImport
+	Note  string
 }
 
 type ExportSymbol struct {
-	Name    string
-	Ordinal uint16
-	RVA     uint32
+	Name        string
+	Ordinal     uint16
+	RVA         uint32
+	OrdinalOnly bool
+	Forwarder   string // "DLL.Function" when RVA points into the export directory
+	Section     string
 }
 type ExportReport struct {
-	DLLName string
-	Symbols []ExportSymbol
-	Note    string
+	DLLName   string
+	Symbols   []ExportSymbol
+	Anomalies []string
+	Note      string
 }
 
 type ResourceTypeSummary struct {
@@ -86,12 +135,53 @@ type ResourceReport struct {
 	Note  string
 }
 
+type Indicator struct {
+	Kind     string
+	Value    string
+	Defanged string
+	Tags     []string
+	Sources  []string
+}
+type IndicatorReport struct {
+	Items []Indicator
+	Note  string
+}
+
+type DecodedBlob struct {
+	Source  string
+	Input   string
+	Chain   []string
+	Size    int
+	Magic   string
+	Preview string
+	Strings []string
+}
+type DecodedReport struct {
+	Items []DecodedBlob
+	Note  string
+}
+
 type Report struct {
-	Header    HeaderReport
-	Sections  []SectionReport
-	Imports   ImportReport
-	Exports   ExportReport
-	Resources ResourceReport
+	Header      HeaderReport
+	Sections    []SectionReport
+	Imports     ImportReport
+	Exports     ExportReport
+	TLS         TLSReport
+	LoadConfig  LoadConfigReport
+	Hardening   HardeningReport
+	Debug       DebugReport
+	Symbols     SymbolReport
+	Relocs      RelocReport
+	Exception   ExceptionReport
+	Resources   ResourceReport
+	DotNet      DotNetReport
+	Indicators  IndicatorReport
+	Decoded     DecodedReport
+	Disasm      DisasmReport
+	Functions   FunctionReport
+	Deps        *DepTree
+	Hijack      HijackReport
+	StringXrefs StringXrefReport
 
 	GeneratedAt time.Time
 	InputBase   string
@@ -106,6 +196,12 @@ func (r *Report) PrintConsole() {
 		fmt.Printf("    Format: %s  ImageBase: 0x%08X  SizeOfImage: 0x%X  EP RVA: 0x%08X  EP VA: 0x%08X\n",
 			r.Header.OptionalFlavor, uint32(r.Header.ImageBaseVA), r.Header.SizeOfImage, r.Header.EntryPointRVA, uint32(r.Header.EntryPointVA))
 	}
+	if r.Header.Layout.Mapped {
+		fmt.Println("    Layout: mapped (memory dump), realigned to file layout for parsing")
+		for _, e := range r.Header.Layout.Evidence {
+			fmt.Printf("      %s\n", e)
+		}
+	}
 
 	fmt.Printf("\nFound %d sections:\n", len(r.Sections))
 	for _, s := range r.Sections {
@@ -113,23 +209,263 @@ func (r *Report) PrintConsole() {
 			s.Index, s.Name, s.PtrRaw, s.SizeRaw, s.VirtualSize, s.VirtualAddress)
 	}
 
-	if len(r.Imports.DLLs) > 0 {
+	for _, s := range r.Sections {
+		if len(s.StackStrings) == 0 {
+			continue
+		}
+		fmt.Printf("\nStack strings in %s (%d):\n", s.Name, len(s.StackStrings))
+		for _, ss := range s.StackStrings {
+			kind := "ascii"
+			if ss.Wide {
+				kind = "wide"
+			}
+			fmt.Printf("  RVA:0x%08X Func:0x%08X %-5s %q\n", ss.RVA, ss.FuncRVA, kind, ss.Text)
+		}
+	}
+
+	if len(r.Imports.DLLs) > 0 || len(r.Imports.Delay) > 0 || r.Imports.Note != "" {
 		fmt.Printf("\nImports (%d DLLs):\n", len(r.Imports.DLLs))
 		for _, d := range r.Imports.DLLs {
-			fmt.Printf("  %s  (%d funcs)\n", d.Name, len(d.Functions))
+			if d.Host != "" {
+				fmt.Printf("  %s -> %s  (%d funcs)\n", d.Name, d.Host, len(d.Functions))
+			} else {
+				fmt.Printf("  %s  (%d funcs)\n", d.Name, len(d.Functions))
+			}
+		}
+		if len(r.Imports.Delay) > 0 {
+			fmt.Printf("  Delay-loaded (%d DLLs):\n", len(r.Imports.Delay))
+			for _, d := range r.Imports.Delay {
+				name := d.Name
+				if d.Host != "" {
+					name += " -> " + d.Host
+				}
+				fmt.Printf("    %s  (%d funcs)  IAT:0x%08X INT:0x%08X\n", name, len(d.Functions), d.IATRVA, d.INTRVA)
+				for _, fn := range d.Functions {
+					fmt.Printf("      0x%X  %s\n", fn.IATVA, fn.Name)
+				}
+			}
+		}
+		if len(r.Imports.Bound) > 0 {
+			fmt.Printf("  Bound (%d DLLs):\n", len(r.Imports.Bound))
+			for _, b := range r.Imports.Bound {
+				fmt.Printf("    %s  TimeDateStamp:0x%08X\n", b.Name, b.TimeDateStamp)
+				for _, fw := range b.Forwarders {
+					fmt.Printf("      -> %s  TimeDateStamp:0x%08X\n", fw.Name, fw.TimeDateStamp)
+				}
+			}
 		}
 		if r.Imports.Note != "" {
 			fmt.Println("  Note:", r.Imports.Note)
 		}
 	}
 
+	if len(r.Hijack.Candidates) > 0 || r.Hijack.SideLoad != nil || r.Hijack.Note != "" {
+		fmt.Printf("\nDLL search-order hijack candidates (%d, KnownDLLs: %s):\n", len(r.Hijack.Candidates), r.Hijack.KnownDLLs)
+		for _, c := range r.Hijack.Candidates {
+			var tags []string
+			if c.Delay {
+				tags = append(tags, "delay")
+			}
+			if c.InAppDir {
+				tags = append(tags, "present in application directory")
+			}
+			if len(tags) > 0 {
+				fmt.Printf("  %s  [%s]\n", c.DLL, strings.Join(tags, ", "))
+			} else {
+				fmt.Printf("  %s\n", c.DLL)
+			}
+		}
+		if sl := r.Hijack.SideLoad; sl != nil {
+			fmt.Printf("  Side-loading check against %s:\n    %s\n", sl.Reference, sl.Verdict)
+			fmt.Printf("    exports: %d (reference %d), forwarded: %d, missing: %d, extra: %d\n", sl.Exports, sl.RefExports, sl.Forwarded, len(sl.Missing), len(sl.Extra))
+		}
+		if r.Hijack.Note != "" {
+			fmt.Println("  Note:", r.Hijack.Note)
+		}
+	}
+
 	if len(r.Exports.Symbols) > 0 || r.Exports.Note != "" {
 		fmt.Printf("\nExports from %s: %d symbols\n", r.Exports.DLLName, len(r.Exports.Symbols))
+		for _, s := range r.Exports.Symbols {
+			name := s.Name
+			if s.OrdinalOnly {
+				name = "(ordinal only)"
+			}
+			if s.Forwarder != "" {
+				fmt.Printf("  %5d  %-40s -> %s\n", s.Ordinal, name, s.Forwarder)
+			} else {
+				fmt.Printf("  %5d  %-40s RVA:0x%08X %s\n", s.Ordinal, name, s.RVA, s.Section)
+			}
+		}
+		for _, a := range r.Exports.Anomalies {
+			fmt.Println("  Anomaly:", a)
+		}
 		if r.Exports.Note != "" {
 			fmt.Println("  Note:", r.Exports.Note)
 		}
 	}
 
+	if r.TLS.Present {
+		fmt.Printf("\nTLS directory at RVA 0x%08X:\n", r.TLS.DirRVA)
+		fmt.Printf("  RawData: 0x%X-0x%X  Index: 0x%X  Callbacks: 0x%X  ZeroFill: 0x%X  Characteristics: 0x%08X\n",
+			r.TLS.StartAddressOfRawData, r.TLS.EndAddressOfRawData, r.TLS.AddressOfIndex,
+			r.TLS.AddressOfCallBacks, r.TLS.SizeOfZeroFill, r.TLS.Characteristics)
+		if r.TLS.Finding != "" {
+			fmt.Println("  [!]", r.TLS.Finding)
+		}
+		for i, cb := range r.TLS.Callbacks {
+			sec := cb.Section
+			if sec == "" {
+				sec = "(outside image)"
+			} else if !cb.Executable {
+				sec += " (not executable)"
+			}
+			fmt.Printf("  #%d VA:0x%X RVA:0x%08X %s\n", i, cb.VA, cb.RVA, sec)
+		}
+		if r.TLS.Note != "" {
+			fmt.Println("  Note:", r.TLS.Note)
+		}
+	}
+
+	if lc := r.LoadConfig; lc.Present {
+		fmt.Printf("\nLoad config at RVA 0x%08X (size %d):\n", lc.DirRVA, lc.Size)
+		fmt.Printf("  SecurityCookie: 0x%X  GuardFlags: 0x%08X %s\n", lc.SecurityCookie, lc.GuardFlags, strings.Join(lc.GuardFlagNames, "|"))
+		if lc.GuardCFCheckFunctionPointer != 0 {
+			fmt.Printf("  GuardCFCheckFunctionPointer: 0x%X  GuardCFDispatchFunctionPointer: 0x%X\n", lc.GuardCFCheckFunctionPointer, lc.GuardCFDispatchFunctionPointer)
+		}
+		for _, t := range []struct {
+			name    string
+			targets []GuardTarget
+		}{{"CFG targets", lc.GuardCFTargets}, {"Long jump targets", lc.LongJumpTargets}, {"EH continuation targets", lc.EHContinuationTargets}} {
+			if len(t.targets) == 0 {
+				continue
+			}
+			fmt.Printf("  %s (%d):\n", t.name, len(t.targets))
+			for i, g := range t.targets {
+				if i == 20 {
+					fmt.Printf("    ... %d more\n", len(t.targets)-i)
+					break
+				}
+				fmt.Printf("    RVA:0x%08X flags:0x%02X %s\n", g.RVA, g.Flags, g.Name)
+			}
+		}
+		if len(lc.SEHandlers) > 0 {
+			fmt.Printf("  SafeSEH handlers (%d):", len(lc.SEHandlers))
+			for _, h := range lc.SEHandlers {
+				fmt.Printf(" 0x%08X", h)
+			}
+			fmt.Println()
+		}
+		if lc.CHPEMetadataPointer != 0 {
+			fmt.Printf("  CHPE metadata: 0x%X\n", lc.CHPEMetadataPointer)
+		}
+		if d := lc.DynamicRelocs; d != nil {
+			fmt.Printf("  Dynamic value relocations at RVA 0x%08X: version %d, %d bytes\n", d.RVA, d.Version, d.Size)
+			for _, e := range d.Entries {
+				fmt.Printf("    %s (%d bytes)\n", e.Kind, e.Size)
+			}
+		}
+		if v := lc.Volatile; v != nil {
+			fmt.Printf("  Volatile metadata at 0x%X: access table RVA 0x%08X (%d bytes), range table RVA 0x%08X (%d bytes)\n",
+				v.VA, v.AccessTableRVA, v.AccessTableSize, v.InfoRangeTableRVA, v.InfoRangeTableSize)
+		}
+		if lc.Note != "" {
+			fmt.Println("  Note:", lc.Note)
+		}
+	}
+
+	if len(r.Debug.Entries) > 0 || r.Debug.Note != "" {
+		fmt.Printf("\nDebug directory (%d entries):\n", len(r.Debug.Entries))
+		for _, e := range r.Debug.Entries {
+			fmt.Printf("  %-22s size:%-6d RVA:0x%08X raw:0x%08X\n", e.TypeName, e.SizeOfData, e.AddressOfRawData, e.PointerToRawData)
+			if cv := e.CodeView; cv != nil {
+				fmt.Printf("    %s %s age %d  key %s\n", cv.Format, cv.GUID, cv.Age, cv.SymbolKey)
+				fmt.Printf("    PDB: %s\n", cv.PDBPath)
+				if cv.User != "" {
+					fmt.Printf("    User: %s\n", cv.User)
+				}
+				if cv.PortablePDB {
+					fmt.Println("    Portable PDB")
+				}
+			}
+			if len(e.POGO) > 0 {
+				names := make([]string, len(e.POGO))
+				for i, p := range e.POGO {
+					names[i] = p.Name
+				}
+				fmt.Printf("    %s: %s\n", e.POGOSignature, strings.Join(names, " "))
+			}
+			if v := e.VCFeature; v != nil {
+				fmt.Printf("    Pre-VC++ 11.00: %d  C/C++: %d  /GS: %d  /sdl: %d  guardN: %d\n", v.PreVC11, v.CCpp, v.GS, v.SDL, v.GuardN)
+			}
+			if e.ReproHash != "" {
+				fmt.Printf("    Hash: %s\n", e.ReproHash)
+			}
+			if e.Type == debugTypeExDllCharacteristics {
+				fmt.Printf("    0x%08X %s\n", e.ExDllChars, strings.Join(e.ExDllNames, "|"))
+			}
+			if len(e.FPO) > 0 {
+				fmt.Printf("    %d FPO records\n", len(e.FPO))
+			}
+			if p := e.EmbeddedPDB; p != nil {
+				fmt.Printf("    Embedded portable PDB, %d bytes uncompressed, metadata: %v\n", p.UncompressedSize, p.Metadata)
+			}
+			if e.Note != "" {
+				fmt.Println("    Note:", e.Note)
+			}
+		}
+		if r.Debug.Note != "" {
+			fmt.Println("  Note:", r.Debug.Note)
+		}
+	}
+
+	if r.Symbols.PDBPath != "" || r.Symbols.Note != "" {
+		fmt.Println("\nSymbols:")
+		if r.Symbols.PDBPath != "" {
+			fmt.Printf("  Loaded %d symbols from %s (%s age %d)\n", len(r.Symbols.Symbols), r.Symbols.PDBPath, r.Symbols.GUID, r.Symbols.Age)
+		}
+		if r.Symbols.Note != "" {
+			fmt.Println("  Note:", r.Symbols.Note)
+		}
+	}
+
+	if r.Relocs.Total > 0 || r.Relocs.Note != "" {
+		fmt.Printf("\nBase relocations: %d in %d blocks\n", r.Relocs.Total, len(r.Relocs.Blocks))
+		for _, sc := range r.Relocs.PerSection {
+			fmt.Printf("  %-8s %6d %s\n", sc.Section, sc.Total, relocTypeSummary(sc.Types))
+		}
+		for _, a := range r.Relocs.Anomalies {
+			fmt.Println("  Anomaly:", a)
+		}
+		if r.Relocs.Note != "" {
+			fmt.Println("  Note:", r.Relocs.Note)
+		}
+	}
+
+	if len(r.Exception.Functions) > 0 || r.Exception.Note != "" {
+		fmt.Printf("\nException directory (%s): %d functions, %d chained\n", r.Exception.Machine, len(r.Exception.Functions), r.Exception.Chained)
+		for _, h := range r.Exception.Handlers {
+			name := h.Name
+			if name == "" {
+				name = "(unnamed)"
+			}
+			fmt.Printf("  Handler 0x%08X %-40s %d functions\n", h.RVA, name, h.Functions)
+		}
+		for _, a := range r.Exception.Anomalies {
+			fmt.Println("  Anomaly:", a)
+		}
+		if r.Exception.Note != "" {
+			fmt.Println("  Note:", r.Exception.Note)
+		}
+	}
+
+	if len(r.Hardening.Checks) > 0 {
+		fmt.Println("\nHardening:")
+		for _, c := range r.Hardening.Checks {
+			fmt.Printf("  %-4s %-15s %s\n", c.Outcome, c.Name, c.Detail)
+		}
+	}
+
 	if len(r.Resources.Types) > 0 || r.Resources.Note != "" {
 		fmt.Printf("\nResources: %d types\n", len(r.Resources.Types))
 		for _, t := range r.Resources.Types {
@@ -139,6 +475,169 @@ func (r *Report) PrintConsole() {
 			fmt.Println("  Note:", r.Resources.Note)
 		}
 	}
+
+	if d := r.DotNet; d.Present {
+		fmt.Printf("\n.NET: runtime %s, metadata %s", d.RuntimeVersion, d.MetadataVersion)
+		if d.TargetFramework != "" {
+			fmt.Printf(", target %s", d.TargetFramework)
+		}
+		fmt.Println()
+		if a := d.Assembly; a != nil {
+			fmt.Printf("  Assembly: %s %s", a.Name, a.Version)
+			if a.PublicKeyToken != "" {
+				fmt.Printf("  token %s", a.PublicKeyToken)
+			}
+			fmt.Println()
+		}
+		if d.Module != "" {
+			fmt.Printf("  Module: %s  MVID %s\n", d.Module, d.MVID)
+		}
+		if d.TypeRefHash != "" {
+			fmt.Println("  TypeRef hash:", d.TypeRefHash)
+		}
+		for _, o := range d.Obfuscators {
+			fmt.Println("  Obfuscator:", o.Name)
+			for _, e := range o.Evidence {
+				fmt.Println("    " + e)
+			}
+		}
+		if len(d.Flags) > 0 {
+			fmt.Println("  Flags:", strings.Join(d.Flags, " "))
+		}
+		if d.EntryPoint != "" {
+			fmt.Println("  Entry point:", d.EntryPoint)
+		}
+		for _, a := range d.References {
+			fmt.Printf("  Reference: %s %s %s\n", a.Name, a.Version, a.PublicKeyToken)
+		}
+		for _, res := range d.Resources {
+			vis := "private"
+			if res.Public {
+				vis = "public"
+			}
+			fmt.Printf("  Resource: %s (%s, %s, %d bytes)\n", res.Name, res.Source, vis, res.Size)
+		}
+		for _, p := range d.PInvokes {
+			fmt.Printf("  P/Invoke: %s!%s <- %s", p.DLL, p.EntryPoint, p.Method)
+			if len(p.Flags) > 0 {
+				fmt.Printf(" [%s]", strings.Join(p.Flags, " "))
+			}
+			fmt.Println()
+		}
+		if len(d.UserStrings) > 0 {
+			fmt.Printf("  User strings (#US): %d\n", len(d.UserStrings))
+		}
+		fmt.Printf("  Types: %d, methods: %d, type refs: %d, member refs: %d\n", len(d.Types), d.Methods, len(d.TypeRefs), len(d.MemberRefs))
+		for i, t := range d.Types {
+			if i == maxConsoleTypes {
+				fmt.Printf("    ... %d more types\n", len(d.Types)-i)
+				break
+			}
+			fmt.Printf("    %-40s %-16s %d methods\n", t.Name, t.Visibility, len(t.Methods))
+		}
+		for _, a := range d.Anomalies {
+			fmt.Println("  Anomaly:", a)
+		}
+		if d.Note != "" {
+			fmt.Println("  Note:", d.Note)
+		}
+	}
+
+	if len(r.Disasm.Listings) > 0 || r.Disasm.Note != "" {
+		fmt.Printf("\nDisassembly (%d listings):\n", len(r.Disasm.Listings))
+		for _, l := range r.Disasm.Listings {
+			fmt.Printf("  %s  RVA:0x%08X VA:0x%X\n", l.Label, l.RVA, l.VA)
+			for _, ln := range l.Lines {
+				text := ln.Text
+				if ln.Comment != "" {
+					text = fmt.Sprintf("%-48s ; %s", text, ln.Comment)
+				}
+				fmt.Printf("    %X  %-30s %s\n", ln.VA, ln.Bytes, text)
+			}
+			if l.Note != "" {
+				fmt.Println("    Note:", l.Note)
+			}
+		}
+		if r.Disasm.Note != "" {
+			fmt.Println("  Note:", r.Disasm.Note)
+		}
+	}
+
+	if len(r.Functions.Functions) > 0 || r.Functions.Note != "" {
+		withAPIs := 0
+		for _, fn := range r.Functions.Functions {
+			if len(fn.APIs) > 0 {
+				withAPIs++
+			}
+		}
+		fmt.Printf("\nFunctions (%d, %d calling imports):\n", len(r.Functions.Functions), withAPIs)
+		for _, fn := range r.Functions.Functions {
+			if len(fn.APIs) == 0 {
+				continue
+			}
+			fmt.Printf("  %s  RVA:0x%08X size:%d callees:%d\n    APIs: %s\n", fn.Name, fn.RVA, fn.Size, len(fn.Callees), strings.Join(fn.APIs, ", "))
+			if len(fn.Strings) > 0 {
+				fmt.Printf("    Strings: %q\n", fn.Strings)
+			}
+		}
+		if uses := r.Functions.APICallers(); len(uses) > 0 {
+			fmt.Printf("  Imported API callers:\n")
+			for _, u := range uses {
+				names := make([]string, 0, len(u.Callers))
+				for _, rva := range u.Callers {
+					if fn, ok := r.Functions.Lookup(rva); ok {
+						names = append(names, fn.Name)
+					}
+				}
+				fmt.Printf("    %-40s %s\n", u.API, strings.Join(names, ", "))
+			}
+		}
+		if r.Functions.Note != "" {
+			fmt.Println("  Note:", r.Functions.Note)
+		}
+	}
+
+	if len(r.StringXrefs.Items) > 0 || r.StringXrefs.Note != "" {
+		fmt.Printf("\nString references (%d referenced, %d unreferenced):\n", len(r.StringXrefs.Items), r.StringXrefs.Unreferenced)
+		for _, x := range r.StringXrefs.Items {
+			fmt.Printf("  0x%08X %-8s %q  referenced by %d locations\n", x.RVA, x.Section, truncateText(x.Text, 120), x.Count)
+			for _, site := range x.Sites {
+				name := ""
+				if fn, ok := r.Functions.Lookup(site.FuncRVA); ok {
+					name = fn.Name
+				}
+				fmt.Printf("      0x%X  %s\n", site.VA, name)
+			}
+		}
+		if r.StringXrefs.Note != "" {
+			fmt.Println("  Note:", r.StringXrefs.Note)
+		}
+	}
+
+	if len(r.Decoded.Items) > 0 {
+		fmt.Printf("\nDecoded blobs (%d):\n", len(r.Decoded.Items))
+		for _, d := range r.Decoded.Items {
+			magic := ""
+			if d.Magic != "" {
+				magic = "  [" + d.Magic + "]"
+			}
+			fmt.Printf("  %s  (%s, %d bytes)%s\n    %s\n", strings.Join(d.Chain, " > "), d.Source, d.Size, magic, d.Preview)
+		}
+		if r.Decoded.Note != "" {
+			fmt.Println("  Note:", r.Decoded.Note)
+		}
+	}
+
+	if len(r.Indicators.Items) > 0 {
+		fmt.Printf("\nIndicators (%d):\n", len(r.Indicators.Items))
+		for _, it := range r.Indicators.Items {
+			tags := ""
+			if len(it.Tags) > 0 {
+				tags = "  [" + strings.Join(it.Tags, ",") + "]"
+			}
+			fmt.Printf("  %-9s %s%s\n", it.Kind, it.Defanged, tags)
+		}
+	}
 }
 
 func Parse(path string, opts Options) (*Report, error) {
@@ -148,15 +647,12 @@ func Parse(path string, opts Options) (*Report, error) {
 	}
 	inputBase := filepath.Base(abs)
 
-	data, err := os.ReadFile(abs)
-	if err != nil {
-		return nil, fmt.Errorf("read: %w", err)
-	}
-	f, err := pe.Open(abs)
+	f, data, layout, err := openImage(abs)
 	if err != nil {
-		return nil, fmt.Errorf("open pe: %w", err)
+		return nil, err
 	}
 	defer f.Close()
+	as := newAddrSpace(f)
 
 	r := &Report{GeneratedAt: time.Now(), InputBase: inputBase}
 
@@ -182,7 +678,9 @@ func Parse(path string, opts Options) (*Report, error) {
 	default:
 		r.Header.OptionalFlavor = "Unknown"
 	}
+	r.Header.Layout = layout
 
+	var corpus []sourcedString
 	secs := make([]SectionReport, 0, len(f.Sections))
 	for i, s := range f.Sections {
 		name := strings.TrimRight(s.Name, "\x00")
@@ -207,21 +705,44 @@ func Parse(path string, opts Options) (*Report, error) {
 			}
 		}
 
-		if opts.ShowStrings {
+		if opts.ShowStrings || opts.Indicators || opts.Decode {
 			if b, err := s.Data(); err == nil {
-				sec.Strings = extractStrings(b, opts.MinStrLen)
+				ascii := extractStrings(b, opts.MinStrLen)
+				wide := extractWideStrings(b, opts.MinStrLen)
+				if opts.ShowStrings {
+					sec.Strings = ascii
+					sec.WideStrings = wide
+				}
+				corpus = appendSourced(corpus, ascii, "section "+name+" (ascii)")
+				corpus = appendSourced(corpus, wide, "section "+name+" (wide)")
+			}
+		}
+
+		if opts.StackStrings && s.Characteristics&(scnCntCode|scnMemExecute) != 0 {
+			if b, err := s.Data(); err == nil {
+				sec.StackStrings = extractStackStrings(b, s.VirtualAddress, r.Header.ImageBaseVA, r.Header.Is64, opts.MinStrLen)
+				for _, ss := range sec.StackStrings {
+					corpus = append(corpus, sourcedString{Text: ss.Text, Source: fmt.Sprintf("stack string @0x%08X", ss.RVA)})
+				}
 			}
 		}
 
 		secs = append(secs, sec)
 	}
 	r.Sections = secs
+	r.DotNet = parseDotNet(f, as, data, opts.MinStrLen)
+	if opts.ShowStrings || opts.Indicators || opts.Decode {
+		corpus = appendSourced(corpus, r.DotNet.UserStrings, ".NET user strings (#US)")
+	}
 
 	if opts.UseSifter {
 		_, sifterNote := stringsifter.EnsureAvailable(opts.AutoInstall, opts.AssumeYes, !opts.Quiet)
 
 		for i := range r.Sections {
 			plain := r.Sections[i].Strings
+			for _, ss := range r.Sections[i].StackStrings {
+				plain = append(plain, ss.Text)
+			}
 			if len(plain) == 0 {
 				continue
 			}
@@ -238,11 +759,87 @@ func Parse(path string, opts Options) (*Report, error) {
 				r.Sections[i].RankNote = sifterNote
 			}
 		}
+		if us := r.DotNet.UserStrings; len(us) > 0 {
+			scored, note := stringsifter.Rank(us, opts.RankLimit, opts.RankMin)
+			r.DotNet.Ranked = sortFilterRanked(toRanked(scored), opts.RankLimit, opts.RankMin)
+			r.DotNet.RankNote = note
+			if note == "" {
+				r.DotNet.RankNote = sifterNote
+			}
+		}
+	}
+
+	r.Imports = parseImports(f, as, data, r.Header.Is64, r.Header.ImageBaseVA)
+	var delayNote, boundNote, ordNote, apiNote string
+	r.Imports.Delay, delayNote = parseDelayImports(f, as, data, r.Header.Is64, r.Header.ImageBaseVA)
+	r.Imports.Bound, boundNote = parseBoundImports(f, as, data)
+	ordDB := builtinOrdinals()
+	if opts.OrdinalDir != "" {
+		if err := ordDB.LoadOrdinalDir(opts.OrdinalDir); err != nil {
+			ordNote = "ordinal maps: " + err.Error()
+		}
+	}
+	resolveOrdinals(&r.Imports, ordDB)
+	apiSets := NewAPISetResolver()
+	if opts.APISetPath != "" {
+		if err := apiSets.LoadSchema(opts.APISetPath); err != nil {
+			apiNote = "API set schema: " + err.Error()
+		}
+	}
+	resolveAPISets(&r.Imports, apiSets)
+	if len(opts.DepDirs) > 0 {
+		r.Deps = BuildDeps(path, opts.DepDirs, apiSets, opts.DepDepth)
+	}
+	if opts.Hijack {
+		r.Hijack = assessHijack(path, f, as, data, r.Imports, opts)
+	}
+	for _, n := range []string{delayNote, boundNote, ordNote, apiNote} {
+		if n == "" {
+			continue
+		}
+		if r.Imports.Note != "" {
+			r.Imports.Note += "; "
+		}
+		r.Imports.Note += n
+	}
+	r.Exports = parseExports(f, as, data)
+	checkExportName(&r.Exports, filepath.Base(path))
+	r.TLS = parseTLS(f, as, data)
+	r.LoadConfig = parseLoadConfig(f, as, data, r.Exports.Symbols)
+	r.Debug = parseDebug(f, as, data)
+	r.Relocs = parseRelocs(f, as, data)
+	if opts.SymbolDir != "" {
+		r.Symbols = loadSymbols(f, r.Debug, opts.SymbolDir)
+	}
+	r.Exception = parseException(f, as, data)
+	nameExceptionHandlers(f, as, data, r)
+	r.Hardening = assessHardening(f, r.LoadConfig, r.Debug)
+	r.Resources = parseResources(f, as, data)
+	r.Disasm = buildDisasm(f, as, data, r, opts.DisasmCount)
+	if opts.Functions {
+		r.Functions, r.StringXrefs = discoverFunctions(f, as, data, r, opts.MinStrLen)
 	}
 
-	r.Imports = parseImports(f, data, r.Header.Is64)
-	r.Exports = parseExports(f, data)
-	r.Resources = parseResources(f, data)
+	if opts.Indicators || opts.Decode {
+		for _, leaf := range walkResourceLeaves(f, as, data) {
+			b, ok := leaf.bytes(as, data)
+			if !ok {
+				continue
+			}
+			src := "resource " + leaf.label()
+			corpus = appendSourced(corpus, extractStrings(b, opts.MinStrLen), src+" (ascii)")
+			corpus = appendSourced(corpus, extractWideStrings(b, opts.MinStrLen), src+" (wide)")
+		}
+	}
+	if opts.Decode {
+		r.Decoded = decodeBlobs(corpus, opts.MinStrLen)
+		for _, d := range r.Decoded.Items {
+			corpus = appendSourced(corpus, d.Strings, "decoded "+strings.Join(d.Chain, " > ")+" from "+d.Source)
+		}
+	}
+	if opts.Indicators {
+		r.Indicators = extractIndicators(corpus)
+	}
 
 	return r, nil
 }
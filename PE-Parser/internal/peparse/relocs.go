@@ -0,0 +1,287 @@
+package peparse
+
+import (
+	"debug/pe"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	relBasedAbsolute = 0
+	relBasedHigh     = 1
+	relBasedLow      = 2
+	relBasedHighLow  = 3
+	relBasedHighAdj  = 4
+	relBasedDir64    = 10
+
+	maxRelocAnomalies = 32
+	scnMemWrite       = 0x80000000
+)
+
+type RelocEntry struct {
+	RVA  uint32 `json:"rva"`
+	Type uint8  `json:"type"`
+	Name string `json:"name"`
+}
+
+type RelocBlock struct {
+	PageRVA uint32       `json:"page_rva"`
+	Size    uint32       `json:"size"`
+	Entries []RelocEntry `json:"entries"`
+}
+
+type RelocSectionCount struct {
+	Section string         `json:"section"`
+	Total   int            `json:"total"`
+	Types   map[string]int `json:"types"`
+}
+
+type RelocReport struct {
+	DirRVA     uint32              `json:"dir_rva"`
+	DirSize    uint32              `json:"dir_size"`
+	Blocks     []RelocBlock        `json:"blocks"`
+	Total      int                 `json:"total"`
+	Types      map[string]int      `json:"types"`
+	PerSection []RelocSectionCount `json:"per_section"`
+	Anomalies  []string            `json:"anomalies,omitempty"`
+	Note       string              `json:"note,omitempty"`
+}
+
+// WriteJSON writes the full relocation listing.
+func (r RelocReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// relocTypeName names a base relocation type; types 5 to 9 depend on the
+// machine.
+func relocTypeName(t uint8, machine uint16) string {
+	switch t {
+	case relBasedAbsolute:
+		return "ABSOLUTE"
+	case relBasedHigh:
+		return "HIGH"
+	case relBasedLow:
+		return "LOW"
+	case relBasedHighLow:
+		return "HIGHLOW"
+	case relBasedHighAdj:
+		return "HIGHADJ"
+	case relBasedDir64:
+		return "DIR64"
+	}
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_THUMB:
+		switch t {
+		case 5:
+			return "ARM_MOV32"
+		case 7:
+			return "THUMB_MOV32"
+		}
+	case pe.IMAGE_FILE_MACHINE_MIPS16, pe.IMAGE_FILE_MACHINE_MIPSFPU, pe.IMAGE_FILE_MACHINE_MIPSFPU16, pe.IMAGE_FILE_MACHINE_R4000, pe.IMAGE_FILE_MACHINE_WCEMIPSV2:
+		switch t {
+		case 5:
+			return "MIPS_JMPADDR"
+		case 9:
+			return "MIPS_JMPADDR16"
+		}
+	case pe.IMAGE_FILE_MACHINE_RISCV32, pe.IMAGE_FILE_MACHINE_RISCV64, pe.IMAGE_FILE_MACHINE_RISCV128:
+		switch t {
+		case 5:
+			return "RISCV_HIGH20"
+		case 7:
+			return "RISCV_LOW12I"
+		case 8:
+			return "RISCV_LOW12S"
+		}
+	case pe.IMAGE_FILE_MACHINE_LOONGARCH32, pe.IMAGE_FILE_MACHINE_LOONGARCH64:
+		if t == 8 {
+			return "LOONGARCH_MARK_LA"
+		}
+	}
+	return fmt.Sprintf("type %d", t)
+}
+
+// parseRelocs walks IMAGE_DIRECTORY_ENTRY_BASERELOC into blocks and entries
+// and checks where the relocations land.
+func parseRelocs(f *pe.File, as *addrSpace, bin []byte) RelocReport {
+	var r RelocReport
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_BASERELOC)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return r
+	}
+	r.DirRVA, r.DirSize = dir.VirtualAddress, dir.Size
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "relocation directory " + err.Error()
+		return r
+	}
+	data := readMemPrefix(as, bin, dir.VirtualAddress, dir.Size)
+
+	is64, oh32, oh64 := getOptional(f)
+	var sizeOfImage uint32
+	switch {
+	case oh32 != nil:
+		sizeOfImage = oh32.SizeOfImage
+	case oh64 != nil:
+		sizeOfImage = oh64.SizeOfImage
+	}
+	machine := f.FileHeader.Machine
+	hdrSize := headerSize(f)
+
+	anomalies := make(map[string]int)
+	var order []string
+	flag := func(format string, args ...interface{}) {
+		s := fmt.Sprintf(format, args...)
+		if anomalies[s] == 0 {
+			order = append(order, s)
+		}
+		anomalies[s]++
+	}
+
+	r.Types = make(map[string]int)
+	perSection := make(map[string]*RelocSectionCount)
+	var secOrder []string
+	seenPages := make(map[uint32]bool)
+	widths := make(map[uint32]uint32)
+	for pos := uint32(0); pos+8 <= dir.Size; {
+		page, ok1 := readU32(data, pos)
+		size, ok2 := readU32(data, pos+4)
+		if !ok1 || !ok2 {
+			r.Note = appendNote(r.Note, "relocation directory extends past end of file")
+			break
+		}
+		if size < 8 || size%2 != 0 || pos+size > dir.Size {
+			r.Note = appendNote(r.Note, fmt.Sprintf("malformed block at +0x%X (size %d)", pos, size))
+			break
+		}
+		if page&0xFFF != 0 {
+			flag("block page 0x%08X is not 4K aligned", page)
+		}
+		if seenPages[page] {
+			flag("page 0x%08X has more than one block", page)
+		}
+		seenPages[page] = true
+
+		blk := RelocBlock{PageRVA: page, Size: size}
+		for i := uint32(8); i+2 <= size; i += 2 {
+			e, _ := readU16(data, pos+i)
+			t := uint8(e >> 12)
+			rva := page + uint32(e&0x0FFF)
+			if t == relBasedAbsolute {
+				continue // padding
+			}
+			ent := RelocEntry{RVA: rva, Type: t, Name: relocTypeName(t, machine)}
+			blk.Entries = append(blk.Entries, ent)
+			r.Types[ent.Name]++
+			r.Total++
+			if t == relBasedHighAdj {
+				i += 2 // the next slot holds the low 16 bits of the adjustment
+			}
+			switch t {
+			case relBasedHighLow:
+				widths[rva] = 4
+			case relBasedDir64:
+				widths[rva] = 8
+			}
+
+			switch {
+			case rva >= sizeOfImage:
+				flag("%s relocation outside the image", ent.Name)
+				continue
+			case rva < hdrSize:
+				flag("%s relocation into the headers", ent.Name)
+				continue
+			}
+			if is64 && t == relBasedHighLow {
+				flag("HIGHLOW relocation in a 64-bit image")
+			}
+			if !is64 && t == relBasedDir64 {
+				flag("DIR64 relocation in a 32-bit image")
+			}
+			if strings.HasPrefix(ent.Name, "type ") {
+				flag("unknown relocation %s for this machine", ent.Name)
+			}
+
+			name := "(none)"
+			if s := sectionAt(as, rva); s != nil {
+				name = strings.TrimRight(s.Name, "\x00")
+				// x64 and ARM64 code is position independent; absolute
+				// fixups inside a read-only code section are unusual there.
+				if is64 && s.Characteristics&scnMemExecute != 0 && s.Characteristics&scnMemWrite == 0 {
+					flag("%s relocation into non-writable code section %s", ent.Name, name)
+				}
+			} else {
+				flag("%s relocation outside all sections", ent.Name)
+			}
+			sc := perSection[name]
+			if sc == nil {
+				sc = &RelocSectionCount{Section: name, Types: make(map[string]int)}
+				perSection[name] = sc
+				secOrder = append(secOrder, name)
+			}
+			sc.Total++
+			sc.Types[ent.Name]++
+		}
+		r.Blocks = append(r.Blocks, blk)
+		pos += size
+	}
+	rvas := make([]uint32, 0, len(widths))
+	for rva := range widths {
+		rvas = append(rvas, rva)
+	}
+	sort.Slice(rvas, func(i, j int) bool { return rvas[i] < rvas[j] })
+	for i := 1; i < len(rvas); i++ {
+		if prev := rvas[i-1]; prev+widths[prev] > rvas[i] {
+			flag("overlapping relocations at 0x%08X and 0x%08X", prev, rvas[i])
+		}
+	}
+	for _, name := range secOrder {
+		r.PerSection = append(r.PerSection, *perSection[name])
+	}
+	for i, s := range order {
+		if i == maxRelocAnomalies {
+			r.Anomalies = append(r.Anomalies, fmt.Sprintf("%d more kinds of anomaly", len(order)-i))
+			break
+		}
+		if n := anomalies[s]; n > 1 {
+			s = fmt.Sprintf("%s (x%d)", s, n)
+		}
+		r.Anomalies = append(r.Anomalies, s)
+	}
+	return r
+}
+
+// absoluteRVAs returns the RVAs patched by HIGHLOW and DIR64 base
+// relocations, i.e. the locations that hold absolute addresses.
+func (r RelocReport) absoluteRVAs() map[uint32]bool {
+	if r.Total == 0 {
+		return nil
+	}
+	out := make(map[uint32]bool, r.Total)
+	for _, b := range r.Blocks {
+		for _, e := range b.Entries {
+			if e.Type == relBasedHighLow || e.Type == relBasedDir64 {
+				out[e.RVA] = true
+			}
+		}
+	}
+	return out
+}
+
+// relocTypeSummary formats per-type counts as "DIR64:12 HIGHLOW:3".
+func relocTypeSummary(types map[string]int) string {
+	names := make([]string, 0, len(types))
+	for n := range types {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf("%s:%d", n, types[n])
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,6 +1,7 @@
 package peparse
 
 import (
+	"bytes"
 	"debug/pe"
 	"encoding/binary"
 	"fmt"
@@ -61,6 +62,35 @@ func extractStrings(data []byte, minLen int) []string {
 	return out
 }
 
+func extractWideStrings(data []byte, minLen int) []string {
+	if minLen < 1 {
+		minLen = 1
+	}
+	var out []string
+	var buf strings.Builder
+	n := 0
+	flush := func() {
+		if n >= minLen {
+			out = append(out, buf.String())
+		}
+		buf.Reset()
+		n = 0
+	}
+	for i := 0; i+1 < len(data); {
+		c := data[i]
+		if data[i+1] == 0 && c >= 0x20 && c <= 0x7E {
+			buf.WriteByte(c)
+			n++
+			i += 2
+			continue
+		}
+		flush()
+		i++
+	}
+	flush()
+	return out
+}
+
 func getOptional(f *pe.File) (is64 bool, oh32 *pe.OptionalHeader32, oh64 *pe.OptionalHeader64) {
 	switch oh := f.OptionalHeader.(type) {
 	case *pe.OptionalHeader32:
@@ -72,15 +102,11 @@ func getOptional(f *pe.File) (is64 bool, oh32 *pe.OptionalHeader32, oh64 *pe.Opt
 	}
 }
 
-func rvaToOff(f *pe.File, rva uint32) (uint32, bool) {
-	for _, s := range f.Sections {
-		va := s.VirtualAddress
-		vsz := s.VirtualSize
-		if rva >= va && rva < va+vsz {
-			return s.Offset + (rva - va), true
-		}
-	}
-	return 0, false
+// rvaToOff translates rva through the loader's address space model. Use
+// resolveRVA where the reason for a failure should be reported.
+func rvaToOff(as *addrSpace, rva uint32) (uint32, bool) {
+	off, err := resolveRVA(as, rva)
+	return off, err == nil
 }
 
 func readU16(b []byte, off uint32) (uint16, bool) {
@@ -102,16 +128,28 @@ func readU64(b []byte, off uint32) (uint64, bool) {
 	return binary.LittleEndian.Uint64(b[off:]), true
 }
 
-func readCStringRVA(f *pe.File, bin []byte, rva uint32) (string, bool) {
-	off, ok := rvaToOff(f, rva)
-	if !ok || int(off) >= len(bin) {
-		return "", false
-	}
-	i := off
-	for i < uint32(len(bin)) && bin[i] != 0 {
-		i++
+// readCStringRVA reads a NUL-terminated string at rva. A string that runs
+// into zero-fill ends there, as it would in memory.
+func readCStringRVA(as *addrSpace, bin []byte, rva uint32) (string, bool) {
+	var out []byte
+	for len(out) < 4096 {
+		off, n, err := as.span(rva + uint32(len(out)))
+		if err != nil {
+			return string(out), len(out) > 0
+		}
+		if uint64(off)+uint64(n) > uint64(len(bin)) {
+			if off >= uint32(len(bin)) {
+				return string(out), len(out) > 0
+			}
+			n = uint32(len(bin)) - off
+		}
+		chunk := bin[off : off+n]
+		if i := bytes.IndexByte(chunk, 0); i >= 0 {
+			return string(append(out, chunk[:i]...)), true
+		}
+		out = append(out, chunk...)
 	}
-	return string(bin[off:i]), true
+	return string(out), true
 }
 
 type importDesc struct {
@@ -122,7 +160,7 @@ type importDesc struct {
 	FirstThunk         uint32
 }
 
-func parseImports(f *pe.File, bin []byte, is64 bool) ImportReport {
+func parseImports(f *pe.File, as *addrSpace, bin []byte, is64 bool, imageBase uint64) ImportReport {
 	var r ImportReport
 	_, oh32, oh64 := getOptional(f)
 	var dir pe.DataDirectory
@@ -137,82 +175,93 @@ func parseImports(f *pe.File, bin []byte, is64 bool) ImportReport {
 	if dir.VirtualAddress == 0 || dir.Size < 20 {
 		return r
 	}
-	baseOff, ok := rvaToOff(f, dir.VirtualAddress)
-	if !ok {
-		r.Note = "bad import directory RVA"
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "import directory " + err.Error()
 		return r
 	}
 
-	for off := baseOff; ; off += 20 {
-		if int(off)+20 > len(bin) {
+	for rva := dir.VirtualAddress; ; rva += 20 {
+		b, ok := readMem(as, bin, rva, 20)
+		if !ok {
 			break
 		}
 		id := importDesc{
-			OriginalFirstThunk: binary.LittleEndian.Uint32(bin[off+0:]),
-			TimeDateStamp:      binary.LittleEndian.Uint32(bin[off+4:]),
-			ForwarderChain:     binary.LittleEndian.Uint32(bin[off+8:]),
-			NameRVA:            binary.LittleEndian.Uint32(bin[off+12:]),
-			FirstThunk:         binary.LittleEndian.Uint32(bin[off+16:]),
+			OriginalFirstThunk: binary.LittleEndian.Uint32(b[0:]),
+			TimeDateStamp:      binary.LittleEndian.Uint32(b[4:]),
+			ForwarderChain:     binary.LittleEndian.Uint32(b[8:]),
+			NameRVA:            binary.LittleEndian.Uint32(b[12:]),
+			FirstThunk:         binary.LittleEndian.Uint32(b[16:]),
 		}
 		if id.OriginalFirstThunk == 0 && id.NameRVA == 0 && id.FirstThunk == 0 {
 			break
 		}
-		dll, ok := readCStringRVA(f, bin, id.NameRVA)
+		dll, ok := readCStringRVA(as, bin, id.NameRVA)
 		if !ok || dll == "" {
 			dll = "<unknown>"
 		}
-		funcs := parseImportNames(f, bin, id, is64)
-		r.DLLs = append(r.DLLs, ImportDLL{Name: strings.ToLower(dll), Functions: funcs})
+		funcs := parseImportNames(as, bin, id, is64, imageBase)
+		r.DLLs = append(r.DLLs, ImportDLL{Name: strings.ToLower(dll), Functions: funcs, IATRVA: id.FirstThunk})
 	}
 	return r
 }
 
-func parseImportNames(f *pe.File, bin []byte, id importDesc, is64 bool) []string {
+func parseImportNames(as *addrSpace, bin []byte, id importDesc, is64 bool, imageBase uint64) []ImportFunc {
 	thunk := id.OriginalFirstThunk
 	if thunk == 0 {
 		thunk = id.FirstThunk
 	}
-	off, ok := rvaToOff(f, thunk)
-	if !ok {
+	return readThunks(as, bin, thunk, id.FirstThunk, is64, 0, imageBase)
+}
+
+// readThunks walks a null-terminated thunk array and pairs each entry with
+// its IAT slot. nameBase is subtracted from hint/name pointers, for tables
+// that hold VAs instead of RVAs.
+func readThunks(as *addrSpace, bin []byte, thunk, iat uint32, is64 bool, nameBase, imageBase uint64) []ImportFunc {
+	if _, ok := rvaToOff(as, thunk); !ok {
 		return nil
 	}
-	var names []string
-	for {
-		if !is64 {
-			val, ok := readU32(bin, off)
-			if !ok || val == 0 {
-				break
-			}
-			off += 4
-			if val&0x80000000 != 0 {
-				names = append(names, fmt.Sprintf("#%d", val&0xFFFF))
-				continue
-			}
-			rva := uint32(val)
-			name, ok := readCStringRVA(f, bin, rva+2)
-			if !ok {
-				name = "<name>"
-			}
-			names = append(names, name)
+	ptr := uint32(4)
+	ordFlag := uint64(0x80000000)
+	if is64 {
+		ptr = 8
+		ordFlag = 0x8000000000000000
+	}
+	var funcs []ImportFunc
+	for i := uint32(0); ; i++ {
+		var val uint64
+		var ok bool
+		if is64 {
+			val, ok = memU64(as, bin, thunk+i*ptr)
 		} else {
-			val, ok := readU64(bin, off)
-			if !ok || val == 0 {
-				break
-			}
-			off += 8
-			if val&0x8000000000000000 != 0 {
-				names = append(names, fmt.Sprintf("#%d", uint32(val&0xFFFF)))
-				continue
-			}
-			rva := uint32(val)
-			name, ok := readCStringRVA(f, bin, rva+2)
-			if !ok {
-				name = "<name>"
-			}
-			names = append(names, name)
+			var v uint32
+			v, ok = memU32(as, bin, thunk+i*ptr)
+			val = uint64(v)
+		}
+		if !ok || val == 0 {
+			break
+		}
+		fn := ImportFunc{Thunk: val}
+		if iat != 0 {
+			fn.IATRVA = iat + i*ptr
+			fn.IATVA = imageBase + uint64(fn.IATRVA)
+		}
+		if val&ordFlag != 0 {
+			fn.ByOrdinal = true
+			fn.Ordinal = uint16(val)
+			fn.Name = fmt.Sprintf("#%d", fn.Ordinal)
+			funcs = append(funcs, fn)
+			continue
+		}
+		rva := uint32(val - nameBase)
+		fn.Hint, _ = memU16(as, bin, rva)
+		name, ok := readCStringRVA(as, bin, rva+2)
+		if !ok {
+			name = "<name>"
 		}
+		fn.Name = name
+		funcs = append(funcs, fn)
 	}
-	return names
+	return funcs
 }
 
 type exportDir struct {
@@ -229,7 +278,11 @@ type exportDir struct {
 	AddressOfNameOrdinal uint32
 }
 
-func parseExports(f *pe.File, bin []byte) ExportReport {
+// maxExportAnomalies caps the anomalies listed per kind; DLLs exporting
+// data or with crafted tables can have thousands.
+const maxExportAnomalies = 16
+
+func parseExports(f *pe.File, as *addrSpace, bin []byte) ExportReport {
 	var r ExportReport
 	_, oh32, oh64 := getOptional(f)
 	var dir pe.DataDirectory
@@ -244,34 +297,45 @@ func parseExports(f *pe.File, bin []byte) ExportReport {
 	if dir.VirtualAddress == 0 || dir.Size < 40 {
 		return r
 	}
-	baseOff, ok := rvaToOff(f, dir.VirtualAddress)
-	if !ok || int(baseOff)+40 > len(bin) {
-		r.Note = "bad export directory RVA"
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "export directory " + err.Error()
+		return r
+	}
+	b, ok := readMem(as, bin, dir.VirtualAddress, 40)
+	if !ok {
+		r.Note = "export directory truncated by end of file"
 		return r
 	}
 	ed := exportDir{
-		Characteristics:      binary.LittleEndian.Uint32(bin[baseOff+0:]),
-		TimeDateStamp:        binary.LittleEndian.Uint32(bin[baseOff+4:]),
-		MajorVersion:         binary.LittleEndian.Uint16(bin[baseOff+8:]),
-		MinorVersion:         binary.LittleEndian.Uint16(bin[baseOff+10:]),
-		NameRVA:              binary.LittleEndian.Uint32(bin[baseOff+12:]),
-		Base:                 binary.LittleEndian.Uint32(bin[baseOff+16:]),
-		NumberOfFunctions:    binary.LittleEndian.Uint32(bin[baseOff+20:]),
-		NumberOfNames:        binary.LittleEndian.Uint32(bin[baseOff+24:]),
-		AddressOfFunctions:   binary.LittleEndian.Uint32(bin[baseOff+28:]),
-		AddressOfNames:       binary.LittleEndian.Uint32(bin[baseOff+32:]),
-		AddressOfNameOrdinal: binary.LittleEndian.Uint32(bin[baseOff+36:]),
-	}
-	if name, ok := readCStringRVA(f, bin, ed.NameRVA); ok {
+		Characteristics:      binary.LittleEndian.Uint32(b[0:]),
+		TimeDateStamp:        binary.LittleEndian.Uint32(b[4:]),
+		MajorVersion:         binary.LittleEndian.Uint16(b[8:]),
+		MinorVersion:         binary.LittleEndian.Uint16(b[10:]),
+		NameRVA:              binary.LittleEndian.Uint32(b[12:]),
+		Base:                 binary.LittleEndian.Uint32(b[16:]),
+		NumberOfFunctions:    binary.LittleEndian.Uint32(b[20:]),
+		NumberOfNames:        binary.LittleEndian.Uint32(b[24:]),
+		AddressOfFunctions:   binary.LittleEndian.Uint32(b[28:]),
+		AddressOfNames:       binary.LittleEndian.Uint32(b[32:]),
+		AddressOfNameOrdinal: binary.LittleEndian.Uint32(b[36:]),
+	}
+	if name, ok := readCStringRVA(as, bin, ed.NameRVA); ok {
 		r.DLLName = name
 	}
 
-	funcsOff, ok1 := rvaToOff(f, ed.AddressOfFunctions)
-	namesOff, ok2 := rvaToOff(f, ed.AddressOfNames)
-	ordsOff, ok3 := rvaToOff(f, ed.AddressOfNameOrdinal)
-	if !ok1 || !ok2 || !ok3 {
-		r.Note = "malformed export arrays"
-		return r
+	for _, a := range []struct {
+		name string
+		rva  uint32
+		used bool
+	}{
+		{"AddressOfFunctions", ed.AddressOfFunctions, ed.NumberOfFunctions > 0},
+		{"AddressOfNames", ed.AddressOfNames, ed.NumberOfNames > 0},
+		{"AddressOfNameOrdinals", ed.AddressOfNameOrdinal, ed.NumberOfNames > 0},
+	} {
+		if _, err := resolveRVA(as, a.rva); a.used && err != nil {
+			r.Note = "malformed export arrays: " + a.name + " " + err.Error()
+			return r
+		}
 	}
 
 	nNames := int(ed.NumberOfNames)
@@ -281,30 +345,108 @@ func parseExports(f *pe.File, bin []byte) ExportReport {
 		return r
 	}
 
+	// Each kind of anomaly is listed up to maxExportAnomalies times; the rest
+	// are counted under the kind's summary text.
+	counts := make(map[string]int)
+	var kinds []string
+	flag := func(more, s string) {
+		if counts[more] == 0 {
+			kinds = append(kinds, more)
+		}
+		counts[more]++
+		if counts[more] <= maxExportAnomalies {
+			r.Anomalies = append(r.Anomalies, s)
+		}
+	}
+
+	// Names first, so every EAT slot knows its names (there can be several).
+	names := make(map[int][]string)
+	seen := make(map[string]bool)
+	prev, sorted := "", true
 	for i := 0; i < nNames; i++ {
-		if int(namesOff)+4 > len(bin) || int(ordsOff)+2 > len(bin) {
+		nameRVA, ok1 := memU32(as, bin, ed.AddressOfNames+uint32(i)*4)
+		ordIdx, ok2 := memU16(as, bin, ed.AddressOfNameOrdinal+uint32(i)*2)
+		if !ok1 || !ok2 {
 			break
 		}
-		nameRVA := binary.LittleEndian.Uint32(bin[namesOff:])
-		ordIdx := binary.LittleEndian.Uint16(bin[ordsOff:])
-		namesOff += 4
-		ordsOff += 2
 
-		name, _ := readCStringRVA(f, bin, nameRVA)
+		name, _ := readCStringRVA(as, bin, nameRVA)
+		if seen[name] {
+			flag("duplicate export names", fmt.Sprintf("duplicate export name %q", name))
+		}
+		seen[name] = true
+		if sorted && i > 0 && name < prev {
+			sorted = false
+			r.Anomalies = append(r.Anomalies, fmt.Sprintf("name table not sorted at %q (after %q); the loader binary-searches it", name, prev))
+		}
+		prev = name
 		idx := int(ordIdx)
-		if idx < 0 || idx >= nFuncs {
+		if idx >= nFuncs {
+			flag("exports have out-of-range ordinal indexes", fmt.Sprintf("export %q has out-of-range ordinal index %d", name, idx))
 			continue
 		}
-		funcRVA := binary.LittleEndian.Uint32(bin[funcsOff+uint32(idx*4):])
-		r.Symbols = append(r.Symbols, ExportSymbol{
-			Name:    name,
+		names[idx] = append(names[idx], name)
+	}
+
+	for idx := 0; idx < nFuncs; idx++ {
+		funcRVA, ok := memU32(as, bin, ed.AddressOfFunctions+uint32(idx)*4)
+		if !ok {
+			break
+		}
+		if funcRVA == 0 {
+			continue // unused ordinal
+		}
+		sym := ExportSymbol{
 			Ordinal: uint16(ed.Base) + uint16(idx),
 			RVA:     funcRVA,
-		})
+		}
+		if funcRVA >= dir.VirtualAddress && funcRVA < dir.VirtualAddress+dir.Size {
+			sym.Forwarder, _ = readCStringRVA(as, bin, funcRVA)
+		} else if sec := sectionAt(as, funcRVA); sec == nil {
+			flag("exports point outside all sections", fmt.Sprintf("ordinal %d points outside all sections (RVA 0x%08X)", sym.Ordinal, funcRVA))
+		} else {
+			sym.Section = strings.TrimRight(sec.Name, "\x00")
+			if sec.Characteristics&(scnCntCode|scnMemExecute) == 0 {
+				flag("exports point to non-executable sections", fmt.Sprintf("ordinal %d points to non-executable section %s", sym.Ordinal, sym.Section))
+			}
+		}
+		if len(names[idx]) == 0 {
+			sym.OrdinalOnly = true
+			r.Symbols = append(r.Symbols, sym)
+			continue
+		}
+		for _, name := range names[idx] {
+			sym.Name = name
+			r.Symbols = append(r.Symbols, sym)
+		}
+	}
+	for _, more := range kinds {
+		if n := counts[more]; n > maxExportAnomalies {
+			r.Anomalies = append(r.Anomalies, fmt.Sprintf("%d more %s", n-maxExportAnomalies, more))
+		}
 	}
 	return r
 }
 
+// sectionAt returns the section the loader maps at rva.
+func sectionAt(as *addrSpace, rva uint32) *pe.Section {
+	if r, ok := as.region(rva); ok {
+		return r.section
+	}
+	return nil
+}
+
+// checkExportName flags a DLL whose internal export name differs from its
+// file name, typical of renamed or side-loaded copies.
+func checkExportName(r *ExportReport, fileName string) {
+	if r.DLLName == "" || len(r.Symbols) == 0 {
+		return
+	}
+	if dllFileName(r.DLLName) != dllFileName(fileName) {
+		r.Anomalies = append(r.Anomalies, fmt.Sprintf("internal name %q differs from file name %q", r.DLLName, fileName))
+	}
+}
+
 type resDir struct {
 	Characteristics uint32
 	TimeDateStamp   uint32
@@ -318,7 +460,7 @@ type resEntry struct {
 	OffsetToData uint32
 }
 
-func parseResources(f *pe.File, bin []byte) ResourceReport {
+func parseResources(f *pe.File, as *addrSpace, bin []byte) ResourceReport {
 	var r ResourceReport
 	_, oh32, oh64 := getOptional(f)
 	var dir pe.DataDirectory
@@ -333,31 +475,36 @@ func parseResources(f *pe.File, bin []byte) ResourceReport {
 	if dir.VirtualAddress == 0 || dir.Size < 16 {
 		return r
 	}
-	baseOff, ok := rvaToOff(f, dir.VirtualAddress)
-	if !ok || int(baseOff)+16 > len(bin) {
-		r.Note = "bad resource directory RVA"
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "resource directory " + err.Error()
+		return r
+	}
+	b, ok := readMem(as, bin, dir.VirtualAddress, 16)
+	if !ok {
+		r.Note = "resource directory truncated by end of file"
 		return r
 	}
 	root := resDir{
-		Characteristics: le32(bin, baseOff+0),
-		TimeDateStamp:   le32(bin, baseOff+4),
-		MajorVersion:    le16(bin, baseOff+8),
-		MinorVersion:    le16(bin, baseOff+10),
-		NNamed:          le16(bin, baseOff+12),
-		NId:             le16(bin, baseOff+14),
+		Characteristics: le32(b, 0),
+		TimeDateStamp:   le32(b, 4),
+		MajorVersion:    le16(b, 8),
+		MinorVersion:    le16(b, 10),
+		NNamed:          le16(b, 12),
+		NId:             le16(b, 14),
 	}
 	total := int(root.NNamed) + int(root.NId)
-	entryOff := baseOff + 16
+	entryRVA := dir.VirtualAddress + 16
 	types := make(map[uint32]int)
 	for i := 0; i < total; i++ {
-		if int(entryOff)+8 > len(bin) {
+		eb, ok := readMem(as, bin, entryRVA, 8)
+		if !ok {
 			break
 		}
 		e := resEntry{
-			NameOrID:     le32(bin, entryOff+0),
-			OffsetToData: le32(bin, entryOff+4),
+			NameOrID:     le32(eb, 0),
+			OffsetToData: le32(eb, 4),
 		}
-		entryOff += 8
+		entryRVA += 8
 
 		isID := (e.NameOrID & 0x80000000) == 0
 		var typeID uint32
@@ -368,11 +515,10 @@ func parseResources(f *pe.File, bin []byte) ResourceReport {
 		}
 		if (e.OffsetToData & 0x80000000) != 0 {
 			subRVA := dir.VirtualAddress + (e.OffsetToData &^ 0x80000000)
-			subOff, ok := rvaToOff(f, subRVA)
-			if ok && int(subOff)+16 <= len(bin) {
+			if sb, ok := readMem(as, bin, subRVA, 16); ok {
 				rd := resDir{
-					NNamed: le16(bin, subOff+12),
-					NId:    le16(bin, subOff+14),
+					NNamed: le16(sb, 12),
+					NId:    le16(sb, 14),
 				}
 				types[typeID] += int(rd.NNamed) + int(rd.NId)
 			}
@@ -388,6 +534,119 @@ func parseResources(f *pe.File, bin []byte) ResourceReport {
 	return r
 }
 
+type resourceLeaf struct {
+	TypeID   uint32
+	TypeName string
+	NameID   uint32
+	Name     string
+	Lang     uint32
+	DataRVA  uint32
+	Size     uint32
+	CodePage uint32
+}
+
+func (l resourceLeaf) label() string {
+	typ := l.TypeName
+	if typ == "" {
+		typ = strings.SplitN(resourceTypeName(l.TypeID), " ", 2)[0]
+	}
+	name := l.Name
+	if name == "" {
+		name = fmt.Sprintf("%d", l.NameID)
+	}
+	return fmt.Sprintf("%s/%s/%d", typ, name, l.Lang)
+}
+
+func (l resourceLeaf) bytes(as *addrSpace, bin []byte) ([]byte, bool) {
+	off, n, err := as.span(l.DataRVA)
+	if err == nil && n >= l.Size && uint64(off)+uint64(l.Size) <= uint64(len(bin)) {
+		return bin[off : off+l.Size], true
+	}
+	if l.Size > 64<<20 {
+		return nil, false
+	}
+	// Data spilling into zero-fill reads as it would in memory.
+	return readMem(as, bin, l.DataRVA, l.Size)
+}
+
+func walkResourceLeaves(f *pe.File, as *addrSpace, bin []byte) []resourceLeaf {
+	_, oh32, oh64 := getOptional(f)
+	var dir pe.DataDirectory
+	if oh32 != nil {
+		dir = oh32.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+	} else if oh64 != nil {
+		dir = oh64.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+	} else {
+		return nil
+	}
+	if dir.VirtualAddress == 0 || dir.Size < 16 {
+		return nil
+	}
+	base := dir.VirtualAddress
+	if _, ok := rvaToOff(as, base); !ok {
+		return nil
+	}
+
+	var out []resourceLeaf
+	seen := make(map[uint32]bool)
+	var walk func(rva uint32, depth int, cur resourceLeaf)
+	walk = func(rva uint32, depth int, cur resourceLeaf) {
+		if depth > 2 || seen[rva] {
+			return
+		}
+		seen[rva] = true
+		hdr, ok := readMem(as, bin, rva, 16)
+		if !ok {
+			return
+		}
+		total := int(le16(hdr, 12)) + int(le16(hdr, 14))
+		entryRVA := rva + 16
+		for i := 0; i < total && len(out) < 1<<14; i++ {
+			eb, ok := readMem(as, bin, entryRVA, 8)
+			if !ok {
+				return
+			}
+			e := resEntry{
+				NameOrID:     le32(eb, 0),
+				OffsetToData: le32(eb, 4),
+			}
+			entryRVA += 8
+
+			next := cur
+			var id uint32
+			var name string
+			if e.NameOrID&0x80000000 != 0 {
+				name, _ = memUTF16(as, bin, base+(e.NameOrID&^0x80000000))
+			} else {
+				id = e.NameOrID
+			}
+			switch depth {
+			case 0:
+				next.TypeID, next.TypeName = id, name
+			case 1:
+				next.NameID, next.Name = id, name
+			case 2:
+				next.Lang = id
+			}
+
+			if e.OffsetToData&0x80000000 != 0 {
+				walk(base+(e.OffsetToData&^0x80000000), depth+1, next)
+				continue
+			}
+			db, ok := readMem(as, bin, base+e.OffsetToData, 16)
+			if !ok {
+				continue
+			}
+			next.DataRVA = le32(db, 0)
+			next.Size = le32(db, 4)
+			next.CodePage = le32(db, 8)
+			out = append(out, next)
+		}
+	}
+	walk(base, 0, resourceLeaf{})
+	return out
+}
+
 func resourceTypeName(id uint32) string {
 	switch id {
 	case 1:
@@ -439,3 +698,61 @@ func le16(b []byte, off uint32) uint16 {
 	}
 	return binary.LittleEndian.Uint16(b[off:])
 }
+
+type locatedString struct {
+	Off  int // offset of the first byte in the scanned buffer
+	Size int // bytes covered, including both bytes of each wide char
+	Text string
+	Wide bool
+}
+
+// extractLocatedStrings applies the extractStrings and extractWideStrings
+// rules but keeps the offset of every string.
+func extractLocatedStrings(data []byte, minLen int) []locatedString {
+	if minLen < 1 {
+		minLen = 1
+	}
+	var out []locatedString
+	var buf strings.Builder
+	start, n := -1, 0
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) {
+			r := rune(data[i])
+			if unicode.IsPrint(r) && r != '\t' && r != '\r' && r != '\n' {
+				if start < 0 {
+					start = i
+				}
+				buf.WriteRune(r)
+				n++
+				continue
+			}
+		}
+		if n >= minLen {
+			out = append(out, locatedString{Off: start, Size: n, Text: buf.String()})
+		}
+		buf.Reset()
+		start, n = -1, 0
+	}
+	for i := 0; i+1 < len(data); {
+		c := data[i]
+		if data[i+1] == 0 && c >= 0x20 && c <= 0x7E {
+			if start < 0 {
+				start = i
+			}
+			buf.WriteByte(c)
+			n++
+			i += 2
+			continue
+		}
+		if n >= minLen {
+			out = append(out, locatedString{Off: start, Size: 2 * n, Text: buf.String(), Wide: true})
+		}
+		buf.Reset()
+		start, n = -1, 0
+		i++
+	}
+	if n >= minLen {
+		out = append(out, locatedString{Off: start, Size: 2 * n, Text: buf.String(), Wide: true})
+	}
+	return out
+}
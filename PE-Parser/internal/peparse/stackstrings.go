@@ -0,0 +1,311 @@
+package peparse
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	scnCntCode    = 0x00000020
+	scnMemExecute = 0x20000000
+
+	stackStoreGap = 16
+)
+
+type stackStore struct {
+	base byte // 4 = esp/rsp, 5 = ebp/rbp
+	disp int32
+	val  uint64
+	size int
+}
+
+// matchStackStore decodes one `mov size [esp/ebp+disp], imm` at b[0:]. Only
+// frame-based stores are accepted; everything else is left to the caller to
+// skip.
+func matchStackStore(b []byte, is64 bool) (stackStore, int, bool) {
+	var st stackStore
+	p := 0
+	op16, rexW := false, false
+	if p < len(b) && b[p] == 0x66 {
+		op16 = true
+		p++
+	}
+	if is64 && p < len(b) && b[p]&0xF0 == 0x40 {
+		rex := b[p]
+		if rex&0x07 != 0 {
+			return st, 0, false
+		}
+		rexW = rex&0x08 != 0
+		p++
+	}
+	if p+2 > len(b) {
+		return st, 0, false
+	}
+	op := b[p]
+	if op != 0xC6 && op != 0xC7 {
+		return st, 0, false
+	}
+	modrm := b[p+1]
+	p += 2
+	mod, reg, rm := modrm>>6, (modrm>>3)&7, modrm&7
+	if reg != 0 || mod == 3 {
+		return st, 0, false
+	}
+	switch rm {
+	case 4:
+		if p >= len(b) || b[p] != 0x24 {
+			return st, 0, false
+		}
+		p++
+		st.base = 4
+	case 5:
+		if mod == 0 {
+			return st, 0, false
+		}
+		st.base = 5
+	default:
+		return st, 0, false
+	}
+	switch mod {
+	case 1:
+		if p+1 > len(b) {
+			return st, 0, false
+		}
+		st.disp = int32(int8(b[p]))
+		p++
+	case 2:
+		if p+4 > len(b) {
+			return st, 0, false
+		}
+		st.disp = int32(binary.LittleEndian.Uint32(b[p:]))
+		p += 4
+	}
+	switch {
+	case op == 0xC6:
+		st.size = 1
+	case op16:
+		st.size = 2
+	case rexW:
+		st.size = 8
+	default:
+		st.size = 4
+	}
+	immLen := st.size
+	if immLen == 8 {
+		immLen = 4
+	}
+	if p+immLen > len(b) {
+		return st, 0, false
+	}
+	switch immLen {
+	case 1:
+		st.val = uint64(b[p])
+	case 2:
+		st.val = uint64(binary.LittleEndian.Uint16(b[p:]))
+	case 4:
+		st.val = uint64(binary.LittleEndian.Uint32(b[p:]))
+		if st.size == 8 {
+			st.val = uint64(int64(int32(uint32(st.val))))
+		}
+	}
+	return st, p + immLen, true
+}
+
+// extractStackStrings scans executable code for runs of immediate stores
+// into the stack frame and reassembles the bytes they write.
+func extractStackStrings(code []byte, secRVA uint32, imageBase uint64, is64 bool, minLen int) []StackString {
+	if minLen < 4 {
+		minLen = 4
+	}
+	var out []StackString
+	for i := 0; i < len(code); {
+		st, n, ok := matchStackStore(code[i:], is64)
+		if !ok {
+			i++
+			continue
+		}
+		run := []stackStore{st}
+		start, end := i, i+n
+		for j := end; j < len(code) && j-end <= stackStoreGap; {
+			if st2, n2, ok2 := matchStackStore(code[j:], is64); ok2 {
+				run = append(run, st2)
+				j += n2
+				end = j
+				continue
+			}
+			j++
+		}
+		i = end
+		if len(run) < 2 {
+			continue
+		}
+		rva := secRVA + uint32(start)
+		fn := uint32(0)
+		if f := guessFunctionStart(code, start); f >= 0 {
+			fn = secRVA + uint32(f)
+		}
+		for _, s := range reassembleStack(run, minLen) {
+			s.RVA = rva
+			s.VA = imageBase + uint64(rva)
+			s.FuncRVA = fn
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func reassembleStack(run []stackStore, minLen int) []StackString {
+	var out []StackString
+	for _, base := range []byte{4, 5} {
+		mem := make(map[int64]byte)
+		for _, st := range run {
+			if st.base != base {
+				continue
+			}
+			for k := 0; k < st.size; k++ {
+				mem[int64(st.disp)+int64(k)] = byte(st.val >> (8 * k))
+			}
+		}
+		if len(mem) < minLen {
+			continue
+		}
+		offs := make([]int64, 0, len(mem))
+		for o := range mem {
+			offs = append(offs, o)
+		}
+		sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+		seg := []byte{mem[offs[0]]}
+		flush := func() {
+			for _, s := range stackASCIIStrings(seg, minLen) {
+				out = append(out, StackString{Text: s})
+			}
+			for _, s := range stackWideStrings(seg, minLen) {
+				out = append(out, StackString{Text: s, Wide: true})
+			}
+		}
+		for k := 1; k < len(offs); k++ {
+			if offs[k] != offs[k-1]+1 {
+				flush()
+				seg = seg[:0]
+			}
+			seg = append(seg, mem[offs[k]])
+		}
+		flush()
+	}
+	return out
+}
+
+// Stack stores of -1 and packed numeric constants are everywhere; only runs
+// that are mostly letters, with few unusual symbols, are kept.
+func plausibleStackText(s string) bool {
+	var seen [128]bool
+	distinct, letters, odd := 0, 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 128 && !seen[c] {
+			seen[c] = true
+			distinct++
+		}
+		switch {
+		case (c|0x20) >= 'a' && (c|0x20) <= 'z':
+			letters++
+		case c >= '0' && c <= '9', c == ' ', strings.IndexByte(`.:/\-_%@,=`, c) >= 0:
+		default:
+			odd++
+		}
+	}
+	if odd > 0 && len(s) < 8 {
+		return false
+	}
+	return distinct >= 3 && letters*2 >= len(s) && odd*5 <= len(s)
+}
+
+func stackASCIIStrings(b []byte, minLen int) []string {
+	var out []string
+	start := -1
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && b[i] >= 0x20 && b[i] <= 0x7E {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLen && plausibleStackText(string(b[start:i])) {
+			out = append(out, string(b[start:i]))
+		}
+		start = -1
+	}
+	return out
+}
+
+// stackWideStrings only accepts aligned UTF-16LE so that ASCII stack strings
+// are not reported twice.
+func stackWideStrings(b []byte, minLen int) []string {
+	var out []string
+	var cur []uint16
+	flush := func() {
+		if len(cur) >= minLen {
+			if s := string(utf16.Decode(cur)); plausibleStackText(s) {
+				out = append(out, s)
+			}
+		}
+		cur = cur[:0]
+	}
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c >= 0x20 && c <= 0x7E {
+			cur = append(cur, c)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return out
+}
+
+// guessFunctionStart walks back from off to the nearest padding boundary
+// (int3/nop fill or a preceding ret) that is followed by a common prologue.
+func guessFunctionStart(code []byte, off int) int {
+	lo := off - 0x4000
+	if lo < 1 {
+		lo = 1
+	}
+	for p := off; p >= lo; p-- {
+		prev := code[p-1]
+		if prev != 0xCC && prev != 0x90 && prev != 0xC3 {
+			continue
+		}
+		if hasPrologue(code[p:]) {
+			return p
+		}
+	}
+	return -1
+}
+
+func hasPrologue(b []byte) bool {
+	prologues := [][]byte{
+		{0x55, 0x8B, 0xEC},       // push ebp; mov ebp, esp
+		{0x55, 0x89, 0xE5},       // push ebp; mov ebp, esp (AT&T encoding)
+		{0x8B, 0xFF, 0x55},       // mov edi, edi; push ebp
+		{0x55, 0x48, 0x8B, 0xEC}, // push rbp; mov rbp, rsp
+		{0x55, 0x48, 0x89, 0xE5}, // push rbp; mov rbp, rsp
+		{0x48, 0x89, 0x5C, 0x24}, // mov [rsp+x], rbx
+		{0x48, 0x89, 0x4C, 0x24}, // mov [rsp+x], rcx
+		{0x48, 0x8B, 0xC4},       // mov rax, rsp
+		{0x48, 0x83, 0xEC},       // sub rsp, imm8
+		{0x48, 0x81, 0xEC},       // sub rsp, imm32
+		{0x40, 0x53},             // push rbx
+		{0x40, 0x55},             // push rbp
+		{0x40, 0x56},             // push rsi
+		{0x40, 0x57},             // push rdi
+	}
+	for _, p := range prologues {
+		if len(b) >= len(p) && string(b[:len(p)]) == string(p) {
+			return true
+		}
+	}
+	return false
+}
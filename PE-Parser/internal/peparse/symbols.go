@@ -0,0 +1,114 @@
+package peparse
+
+import (
+	"debug/pe"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"PE-Parser/internal/pdb"
+)
+
+type SymbolInfo struct {
+	RVA  uint32
+	Name string
+	Size uint32
+	Kind string // pdb.KindPublic, pdb.KindFunction or pdb.KindData
+	Code bool
+}
+
+type SymbolReport struct {
+	PDBPath string
+	GUID    string
+	Age     uint32
+	Symbols []SymbolInfo // sorted by RVA
+	Note    string
+}
+
+// CodeNames maps RVAs to code symbol names. Procedure records win over
+// public symbols since their names are undecorated.
+func (s SymbolReport) CodeNames() map[uint32]string {
+	m := make(map[uint32]string)
+	for _, sym := range s.Symbols {
+		if !sym.Code {
+			continue
+		}
+		if _, ok := m[sym.RVA]; !ok || sym.Kind == pdb.KindFunction {
+			m[sym.RVA] = sym.Name
+		}
+	}
+	return m
+}
+
+// loadSymbols finds the PDB named by the CodeView record under dir, checks
+// that its GUID and age match, and reads its symbols. dir may be a symstore
+// tree (name.pdb/<GUID><age>/name.pdb) or a flat directory of PDBs.
+func loadSymbols(f *pe.File, dbg DebugReport, dir string) SymbolReport {
+	var r SymbolReport
+	cv := dbg.CodeView()
+	if cv == nil {
+		r.Note = "no CodeView record"
+		return r
+	}
+	if cv.PortablePDB {
+		r.Note = "portable PDBs are not supported"
+		return r
+	}
+	name := filepath.Base(strings.ReplaceAll(cv.PDBPath, `\`, "/"))
+	candidates := []string{
+		filepath.Join(dir, name, cv.SymbolKey, name),
+		filepath.Join(dir, name),
+	}
+	var p *pdb.File
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err != nil {
+			if _, err := os.Stat(strings.TrimSuffix(c, ".pdb") + ".pd_"); err == nil {
+				r.Note = appendNote(r.Note, "compressed "+filepath.Base(c)+" found; expand it first")
+			}
+			continue
+		}
+		got, err := pdb.Open(c)
+		if err != nil {
+			r.Note = appendNote(r.Note, c+": "+err.Error())
+			continue
+		}
+		if !pdbMatches(got, cv) {
+			r.Note = appendNote(r.Note, c+": GUID/age does not match the image")
+			continue
+		}
+		r.PDBPath, p = c, got
+		break
+	}
+	if p == nil {
+		if r.Note == "" {
+			r.Note = "no matching PDB for " + name + " (" + cv.SymbolKey + ")"
+		}
+		return r
+	}
+	r.GUID, r.Age = p.GUIDString(), p.Age
+
+	secs := make([]pdb.Section, len(f.Sections))
+	for i, s := range f.Sections {
+		secs[i] = pdb.Section{Name: s.Name, VirtualAddress: s.VirtualAddress}
+	}
+	for _, s := range p.Symbols {
+		rva, ok := p.RVA(s.Segment, s.Offset, secs)
+		if !ok || s.Name == "" {
+			continue
+		}
+		r.Symbols = append(r.Symbols, SymbolInfo{RVA: rva, Name: s.Name, Size: s.Size, Kind: s.Kind, Code: s.Code})
+	}
+	sort.SliceStable(r.Symbols, func(i, j int) bool { return r.Symbols[i].RVA < r.Symbols[j].RVA })
+	return r
+}
+
+func pdbMatches(p *pdb.File, cv *CodeViewInfo) bool {
+	if p.Age != cv.Age {
+		return false
+	}
+	if cv.Format == "NB10" {
+		return p.Signature == cv.Signature
+	}
+	return p.GUIDString() == cv.GUID
+}
@@ -0,0 +1,146 @@
+package peparse
+
+import (
+	"debug/pe"
+	"fmt"
+	"strings"
+)
+
+const maxTLSCallbacks = 64
+
+type TLSCallback struct {
+	VA         uint64
+	RVA        uint32
+	Section    string
+	Executable bool
+}
+
+// TLSReport is IMAGE_TLS_DIRECTORY32/64. The address fields are VAs based on
+// the preferred ImageBase, as stored in the file.
+type TLSReport struct {
+	Present               bool
+	DirRVA                uint32
+	StartAddressOfRawData uint64
+	EndAddressOfRawData   uint64
+	AddressOfIndex        uint64
+	AddressOfCallBacks    uint64
+	SizeOfZeroFill        uint32
+	Characteristics       uint32
+	Callbacks             []TLSCallback
+	Finding               string // set when callbacks run before the entry point
+	Note                  string
+}
+
+// parseTLS reads the TLS directory and walks the callback array.
+func parseTLS(f *pe.File, as *addrSpace, bin []byte) TLSReport {
+	var r TLSReport
+	is64, oh32, oh64 := getOptional(f)
+	var imageBase uint64
+	switch {
+	case oh32 != nil:
+		imageBase = uint64(oh32.ImageBase)
+	case oh64 != nil:
+		imageBase = oh64.ImageBase
+	default:
+		return r
+	}
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_TLS)
+	if dir.VirtualAddress == 0 {
+		return r
+	}
+	r.Present = true
+	r.DirRVA = dir.VirtualAddress
+	if _, err := resolveRVA(as, dir.VirtualAddress); err != nil {
+		r.Note = "TLS directory " + err.Error()
+		return r
+	}
+
+	d := dir.VirtualAddress
+	ptr := func(i uint32) uint64 {
+		if is64 {
+			v, _ := memU64(as, bin, d+i*8)
+			return v
+		}
+		v, _ := memU32(as, bin, d+i*4)
+		return uint64(v)
+	}
+	tail := uint32(16)
+	if is64 {
+		tail = 32
+	}
+	if _, ok := memU32(as, bin, d+tail+4); !ok {
+		r.Note = "truncated TLS directory"
+		return r
+	}
+	r.StartAddressOfRawData = ptr(0)
+	r.EndAddressOfRawData = ptr(1)
+	r.AddressOfIndex = ptr(2)
+	r.AddressOfCallBacks = ptr(3)
+	r.SizeOfZeroFill, _ = memU32(as, bin, d+tail)
+	r.Characteristics, _ = memU32(as, bin, d+tail+4)
+
+	if r.AddressOfCallBacks == 0 {
+		return r
+	}
+	if r.AddressOfCallBacks <= imageBase {
+		r.Note = fmt.Sprintf("AddressOfCallBacks 0x%X is below ImageBase", r.AddressOfCallBacks)
+		return r
+	}
+	if _, ok := vaToOff(as, r.AddressOfCallBacks, imageBase); !ok {
+		if r.AddressOfCallBacks-imageBase >= as.end() {
+			r.Note = fmt.Sprintf("callback array at 0x%X is outside the image", r.AddressOfCallBacks)
+		} else {
+			r.Note = "callback array outside all sections (may be filled at run time)"
+		}
+		return r
+	}
+	arr := uint32(r.AddressOfCallBacks - imageBase)
+	for i := 0; ; i++ {
+		if i == maxTLSCallbacks {
+			r.Note = fmt.Sprintf("callback array truncated at %d entries", maxTLSCallbacks)
+			break
+		}
+		var va uint64
+		var ok bool
+		if is64 {
+			va, ok = memU64(as, bin, arr+uint32(i*8))
+		} else {
+			var v uint32
+			v, ok = memU32(as, bin, arr+uint32(i*4))
+			va = uint64(v)
+		}
+		if !ok || va == 0 {
+			break
+		}
+		cb := TLSCallback{VA: va}
+		if va > imageBase && va-imageBase < 1<<32 {
+			cb.RVA = uint32(va - imageBase)
+			if s := sectionAt(as, cb.RVA); s != nil {
+				cb.Section = strings.TrimRight(s.Name, "\x00")
+				cb.Executable = s.Characteristics&(scnCntCode|scnMemExecute) != 0
+			}
+		}
+		r.Callbacks = append(r.Callbacks, cb)
+	}
+	if len(r.Callbacks) > 0 {
+		r.Finding = fmt.Sprintf("%d TLS callback(s) execute before the entry point", len(r.Callbacks))
+		for _, cb := range r.Callbacks {
+			if cb.Section == "" {
+				r.Finding += "; a callback points outside the image"
+				break
+			}
+		}
+	}
+	return r
+}
+
+// tlsCallbacks returns the RVAs of the TLS callbacks that lie in the image.
+func tlsCallbacks(f *pe.File, as *addrSpace, bin []byte) []uint32 {
+	var out []uint32
+	for _, cb := range parseTLS(f, as, bin).Callbacks {
+		if cb.Section != "" {
+			out = append(out, cb.RVA)
+		}
+	}
+	return out
+}
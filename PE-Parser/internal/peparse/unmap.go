@@ -0,0 +1,543 @@
+package peparse
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	scnCntUninitialized = 0x80
+	maxBaseCandidates   = 4096
+)
+
+// Layout says how the sections of an input are laid out: "file" for an
+// on-disk image, "mapped" for a dump of a loaded module with each section
+// at its RVA.
+type Layout struct {
+	Mapped   bool
+	Evidence []string
+}
+
+// UnmapOptions controls Unmap. Rebase restores ImageBase by undoing the
+// relocations the loader applied; the target is Base when set, otherwise
+// the ImageBase recorded in the dumped headers. LoadBase is the address
+// the module was dumped from; when zero it is inferred from relocations.
+type UnmapOptions struct {
+	Base     uint64
+	LoadBase uint64
+	Rebase   bool
+	Force    bool // unmap even if the input does not look mapped
+}
+
+// UnmappedImage is a memory dump realigned to a valid file layout.
+type UnmappedImage struct {
+	Data      []byte
+	Layout    Layout
+	LoadBase  uint64 // base the dump was relocated to, when inferred
+	ImageBase uint64
+	Applied   int // relocations undone
+	Notes     []string
+}
+
+// openImage reads path and opens it as a PE. A dump of a loaded module is
+// detected and realigned to file layout first, so the rest of the parser
+// can translate RVAs through PointerToRawData as usual.
+func openImage(path string) (*pe.File, []byte, Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, Layout{}, fmt.Errorf("read: %w", err)
+	}
+	f, err := newPEFile(data)
+	if err != nil {
+		return nil, nil, Layout{}, fmt.Errorf("open pe: %w", err)
+	}
+	lay := detectLayout(f, newAddrSpace(f), data)
+	if !lay.Mapped {
+		return f, data, lay, nil
+	}
+	fixed := unmapLayout(f, data)
+	uf, err := pe.NewFile(bytes.NewReader(fixed))
+	if err != nil {
+		return nil, nil, lay, fmt.Errorf("open unmapped pe: %w", err)
+	}
+	return uf, fixed, lay, nil
+}
+
+// newPEFile opens data as a PE. The COFF symbol table is addressed by file
+// offset, so in a dump PointerToSymbolTable points at unrelated bytes and
+// long section names ("/15") cannot be resolved. When debug/pe rejects the
+// input, the pointer is moved to where its section was mapped, and failing
+// that the table is dropped and long names are kept as written. ReadyToRun
+// images built for another OS have an OS value XORed into Machine, which
+// debug/pe rejects, so that is undone first.
+func newPEFile(data []byte) (*pe.File, error) {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err == nil {
+		return f, nil
+	}
+	lfanew, ok := readU32(data, 0x3C)
+	if !ok || uint64(lfanew)+24 > uint64(len(data)) {
+		return nil, err
+	}
+	cp := append([]byte(nil), data...)
+	if m, _ := readU16(cp, lfanew+4); r2rOS(m) != "" {
+		binary.LittleEndian.PutUint16(cp[lfanew+4:], m^r2rOSOverrides[r2rOS(m)])
+		if f, err := pe.NewFile(bytes.NewReader(cp)); err == nil {
+			return f, nil
+		}
+	}
+	if p, _ := readU32(cp, lfanew+12); p != 0 {
+		secTable, n := rawSectionTable(cp, lfanew)
+		for i := uint32(0); i < n; i++ {
+			h := secTable + i*40
+			va, _ := readU32(cp, h+12)
+			size, _ := readU32(cp, h+16)
+			raw, _ := readU32(cp, h+20)
+			if p >= raw && p-raw < size {
+				binary.LittleEndian.PutUint32(cp[lfanew+12:], va+(p-raw))
+				if f, err := pe.NewFile(bytes.NewReader(cp)); err == nil {
+					return f, nil
+				}
+				break
+			}
+		}
+	}
+	clearSymbolTable(cp, lfanew)
+	secTable, n := rawSectionTable(cp, lfanew)
+	for i := uint32(0); i < n; i++ {
+		if h := secTable + i*40; cp[h] == '/' {
+			cp[h] = '_' // keep the offset visible without a string table
+		}
+	}
+	if f, err := pe.NewFile(bytes.NewReader(cp)); err == nil {
+		return f, nil
+	}
+	return nil, err
+}
+
+// rawSectionTable returns the offset and count of the section headers,
+// bounded by len(b).
+func rawSectionTable(b []byte, lfanew uint32) (uint32, uint32) {
+	nsec, _ := readU16(b, lfanew+6)
+	optSize, _ := readU16(b, lfanew+20)
+	secTable := lfanew + 24 + uint32(optSize)
+	n := uint32(nsec)
+	for n > 0 && uint64(secTable)+uint64(n)*40 > uint64(len(b)) {
+		n--
+	}
+	return secTable, n
+}
+
+// clearSymbolTable zeroes PointerToSymbolTable and NumberOfSymbols.
+func clearSymbolTable(b []byte, lfanew uint32) {
+	binary.LittleEndian.PutUint32(b[lfanew+12:], 0)
+	binary.LittleEndian.PutUint32(b[lfanew+16:], 0)
+}
+
+// detectLayout votes per section: data present at the RVA but missing or
+// zero at PointerToRawData suggests a mapped dump, and the reverse suggests
+// a file. The import and relocation directories vote for whichever layout
+// they parse under, and a file too short for its raw layout that still
+// covers SizeOfImage also counts as mapped.
+func detectLayout(f *pe.File, as *addrSpace, bin []byte) Layout {
+	var lay Layout
+	empty := func(off, n uint32) bool {
+		if uint64(off)+uint64(n) > uint64(len(bin)) {
+			return true
+		}
+		for _, b := range bin[off : off+n] {
+			if b != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	var mapped, file, differ int
+	var rawEnd uint64
+	for _, s := range f.Sections {
+		if end := uint64(s.Offset) + uint64(s.Size); s.Size != 0 && end > rawEnd {
+			rawEnd = end
+		}
+		if s.Size == 0 || s.Offset == s.VirtualAddress {
+			continue
+		}
+		differ++
+		n := s.Size
+		if s.VirtualSize != 0 && s.VirtualSize < n {
+			n = s.VirtualSize
+		}
+		if n > 0x200 {
+			n = 0x200 // stay inside the padding that precedes the next region
+		}
+		rawEmpty, vaEmpty := empty(s.Offset, n), empty(s.VirtualAddress, n)
+		switch {
+		case rawEmpty && !vaEmpty:
+			mapped++
+		case vaEmpty && !rawEmpty:
+			file++
+		}
+	}
+	if differ == 0 {
+		return lay // both layouts coincide
+	}
+	if mapped > 0 {
+		lay.Evidence = append(lay.Evidence, fmt.Sprintf("%d of %d sections have data at their RVA but not at PointerToRawData", mapped, differ))
+	}
+	_, oh32, oh64 := getOptional(f)
+	var sizeOfImage uint32
+	switch {
+	case oh32 != nil:
+		sizeOfImage = oh32.SizeOfImage
+	case oh64 != nil:
+		sizeOfImage = oh64.SizeOfImage
+	}
+
+	fileOff := func(rva uint32) (uint32, bool) { return rvaToOff(as, rva) }
+	memOff := func(rva uint32) (uint32, bool) { return rva, rva < uint32(len(bin)) }
+	for _, d := range []struct {
+		name  string
+		valid func(off func(uint32) (uint32, bool)) bool
+	}{
+		{"import", func(off func(uint32) (uint32, bool)) bool { return validImportDir(f, bin, off) }},
+		{"relocation", func(off func(uint32) (uint32, bool)) bool { return validRelocDir(f, bin, off, sizeOfImage) }},
+	} {
+		inFile, inMem := d.valid(fileOff), d.valid(memOff)
+		switch {
+		case inMem && !inFile:
+			mapped++
+			lay.Evidence = append(lay.Evidence, "the "+d.name+" directory parses only at its RVA")
+		case inFile && !inMem:
+			file++
+		}
+	}
+	if uint64(len(bin)) < rawEnd && uint64(len(bin)) >= uint64(sizeOfImage) {
+		mapped++
+		lay.Evidence = append(lay.Evidence, fmt.Sprintf("file size 0x%X is short of the raw layout (0x%X) but covers SizeOfImage", len(bin), rawEnd))
+	}
+	lay.Mapped = mapped > file
+	if !lay.Mapped {
+		lay.Evidence = nil
+	}
+	return lay
+}
+
+// validImportDir checks that the first import descriptor, located through
+// off, names a DLL whose name is located the same way.
+func validImportDir(f *pe.File, bin []byte, off func(uint32) (uint32, bool)) bool {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_IMPORT)
+	if dir.VirtualAddress == 0 {
+		return false
+	}
+	d, ok := off(dir.VirtualAddress)
+	if !ok {
+		return false
+	}
+	nameRVA, ok := readU32(bin, d+12)
+	if !ok || nameRVA == 0 {
+		return false
+	}
+	n, ok := off(nameRVA)
+	if !ok {
+		return false
+	}
+	end := n
+	for end < uint32(len(bin)) && end-n < 256 && bin[end] != 0 {
+		end++
+	}
+	name := string(bin[n:end])
+	return len(name) > 0 && isPrintableASCII(name) && strings.Contains(name, ".")
+}
+
+// validRelocDir checks that the first base relocation block, located
+// through off, has a page-aligned RVA inside the image and a sane size.
+func validRelocDir(f *pe.File, bin []byte, off func(uint32) (uint32, bool), sizeOfImage uint32) bool {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_BASERELOC)
+	if dir.VirtualAddress == 0 || dir.Size < 8 {
+		return false
+	}
+	b, ok := off(dir.VirtualAddress)
+	if !ok {
+		return false
+	}
+	page, ok1 := readU32(bin, b)
+	size, ok2 := readU32(bin, b+4)
+	return ok1 && ok2 && page&0xFFF == 0 && page < sizeOfImage && size >= 8 && size%2 == 0 && size <= dir.Size
+}
+
+// unmapLayout copies each section from its RVA in a mapped dump to a new
+// file-aligned raw offset, sized from VirtualSize, and rewrites
+// PointerToRawData and SizeOfRawData to match.
+func unmapLayout(f *pe.File, bin []byte) []byte {
+	_, oh32, oh64 := getOptional(f)
+	var sizeOfHeaders, fileAlign uint32
+	switch {
+	case oh32 != nil:
+		sizeOfHeaders, fileAlign = oh32.SizeOfHeaders, oh32.FileAlignment
+	case oh64 != nil:
+		sizeOfHeaders, fileAlign = oh64.SizeOfHeaders, oh64.FileAlignment
+	}
+	if fileAlign == 0 || fileAlign&(fileAlign-1) != 0 {
+		fileAlign = 0x200
+	}
+	lfanew, _ := readU32(bin, 0x3C)
+	secTable := lfanew + 24 + uint32(f.FileHeader.SizeOfOptionalHeader)
+	if end := secTable + uint32(len(f.Sections))*40; sizeOfHeaders < end {
+		sizeOfHeaders = end
+	}
+	if sizeOfHeaders > uint32(len(bin)) {
+		sizeOfHeaders = uint32(len(bin))
+	}
+
+	out := make([]byte, alignUp(sizeOfHeaders, fileAlign))
+	copy(out, bin[:sizeOfHeaders])
+	// The COFF symbol table survives only if a section carried it (Go
+	// binaries keep it in .symtab); it then moves with that section.
+	symPtr, _ := readU32(bin, lfanew+12)
+	symNew := uint32(0)
+	for i, s := range f.Sections {
+		n := s.VirtualSize
+		if n == 0 {
+			n = s.Size
+		}
+		if s.Size == 0 && s.Characteristics&scnCntUninitialized != 0 {
+			n = 0 // pure BSS stays without raw data
+		}
+		if uint64(s.VirtualAddress) >= uint64(len(bin)) {
+			n = 0 // nothing of it made it into the dump
+		} else if uint64(s.VirtualAddress)+uint64(n) > uint64(len(bin)) {
+			n = uint32(len(bin)) - s.VirtualAddress
+		}
+		var ptr uint32
+		if n != 0 {
+			ptr = uint32(len(out))
+			out = append(out, bin[s.VirtualAddress:s.VirtualAddress+n]...)
+			out = append(out, make([]byte, alignUp(n, fileAlign)-n)...)
+			if symPtr >= s.Offset && symPtr-s.Offset < n {
+				symNew = ptr + (symPtr - s.Offset)
+			}
+		}
+		hdr := secTable + uint32(i)*40
+		if hdr+24 <= uint32(len(out)) {
+			binary.LittleEndian.PutUint32(out[hdr+16:], alignUp(n, fileAlign))
+			binary.LittleEndian.PutUint32(out[hdr+20:], ptr)
+		}
+	}
+	if uint64(lfanew)+20 <= uint64(len(out)) {
+		if symNew != 0 {
+			binary.LittleEndian.PutUint32(out[lfanew+12:], symNew)
+		} else {
+			clearSymbolTable(out, lfanew)
+		}
+	}
+	return out
+}
+
+// inferLoadBase finds the 64K-aligned base the dump was relocated to. The
+// loader writes that base into the mapped headers, so prefer (the dumped
+// ImageBase) is taken when nine in ten absolute pointers named by
+// relocations fall inside a section from it. Otherwise the base lies at
+// most SizeOfImage below the median pointer, and candidates are ranked by
+// how many pointers land exactly on a known function start (.pdata,
+// exports, entry point), then inside a section, then on another relocation
+// site or on code right after padding. At least half must fit.
+func inferLoadBase(f *pe.File, as *addrSpace, img []byte, rel RelocReport, sizeOfImage uint32, prefer uint64) (uint64, bool) {
+	var vals []uint64
+	sites := rel.absoluteRVAs()
+	for _, b := range rel.Blocks {
+		for _, e := range b.Entries {
+			switch e.Type {
+			case relBasedHighLow:
+				if v, ok := readU32(img, e.RVA); ok {
+					vals = append(vals, uint64(v))
+				}
+			case relBasedDir64:
+				if v, ok := readU64(img, e.RVA); ok {
+					vals = append(vals, v)
+				}
+			}
+		}
+	}
+	if len(vals) == 0 || sizeOfImage == 0 {
+		return 0, false
+	}
+	anchors := functionStarts(f, img)
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	med := vals[len(vals)/2]
+	type result struct{ anchor, inSection, site int }
+	score := func(base uint64) result {
+		var r result
+		for _, v := range vals {
+			if v < base || v-base >= uint64(sizeOfImage) {
+				continue
+			}
+			rva := uint32(v - base)
+			s := sectionAt(as, rva)
+			if s == nil {
+				continue
+			}
+			r.inSection++
+			switch {
+			case anchors[rva]:
+				r.anchor++
+			case sites[rva]:
+				r.site++
+			case s.Characteristics&scnMemExecute != 0 && (rva == s.VirtualAddress || isPadding(img, rva-1)):
+				r.site++
+			}
+		}
+		return r
+	}
+	if r := score(prefer); r.inSection*10 >= len(vals)*9 {
+		return prefer, true
+	}
+	better := func(a, b result) bool {
+		if a.anchor != b.anchor {
+			return a.anchor > b.anchor
+		}
+		if a.inSection != b.inSection {
+			return a.inSection > b.inSection
+		}
+		return a.site > b.site
+	}
+	var best uint64
+	var bestScore result
+	for c, i := med&^0xFFFF, 0; i < maxBaseCandidates && med-c < uint64(sizeOfImage); c, i = c-0x10000, i+1 {
+		if r := score(c); better(r, bestScore) {
+			best, bestScore = c, r
+		}
+		if c < 0x10000 {
+			break
+		}
+	}
+	if bestScore.inSection*2 < len(vals) {
+		return 0, false
+	}
+	return best, true
+}
+
+// functionStarts collects RVAs known to start functions without relying
+// on relocated data: .pdata BeginAddress entries, exports and the entry
+// point. img is in memory layout.
+func functionStarts(f *pe.File, img []byte) map[uint32]bool {
+	m := make(map[uint32]bool)
+	_, oh32, oh64 := getOptional(f)
+	switch {
+	case oh32 != nil:
+		m[oh32.AddressOfEntryPoint] = true
+	case oh64 != nil:
+		m[oh64.AddressOfEntryPoint] = true
+	}
+	stride := uint32(0)
+	switch f.FileHeader.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		stride = 12
+	case pe.IMAGE_FILE_MACHINE_ARM64, pe.IMAGE_FILE_MACHINE_ARMNT:
+		stride = 8
+	}
+	if dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION); stride != 0 && dir.VirtualAddress != 0 {
+		for off := dir.VirtualAddress; off+stride <= dir.VirtualAddress+dir.Size; off += stride {
+			if v, ok := readU32(img, off); ok && v != 0 {
+				m[v] = true
+			}
+		}
+	}
+	if dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_EXPORT); dir.VirtualAddress != 0 {
+		n, _ := readU32(img, dir.VirtualAddress+20)
+		eat, _ := readU32(img, dir.VirtualAddress+28)
+		for i := uint32(0); i < n && i < 0x10000; i++ {
+			if v, ok := readU32(img, eat+i*4); ok && v != 0 {
+				m[v] = true
+			}
+		}
+	}
+	delete(m, 0)
+	return m
+}
+
+// isPadding reports whether the byte at off typically precedes a function.
+func isPadding(img []byte, off uint32) bool {
+	if int(off) >= len(img) {
+		return false
+	}
+	switch img[off] {
+	case 0xCC, 0x90, 0xC3, 0x00:
+		return true
+	}
+	return false
+}
+
+// Unmap converts a dump of a loaded module at path back to file layout. See
+// UnmapOptions for restoring the original ImageBase.
+func Unmap(path string, opts UnmapOptions) (*UnmappedImage, error) {
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	f, err := newPEFile(bin)
+	if err != nil {
+		return nil, fmt.Errorf("open pe: %w", err)
+	}
+	as := newAddrSpace(f)
+	u := &UnmappedImage{Layout: detectLayout(f, as, bin)}
+	if !u.Layout.Mapped && !opts.Force {
+		return nil, fmt.Errorf("input does not look like a mapped image (use -force to unmap anyway)")
+	}
+	is64, oh32, oh64 := getOptional(f)
+	var sizeOfImage uint32
+	switch {
+	case oh32 != nil:
+		u.ImageBase, sizeOfImage = uint64(oh32.ImageBase), oh32.SizeOfImage
+	case oh64 != nil:
+		u.ImageBase, sizeOfImage = oh64.ImageBase, oh64.SizeOfImage
+	default:
+		return nil, fmt.Errorf("no optional header")
+	}
+	u.Data = unmapLayout(f, bin)
+	if !opts.Rebase {
+		return u, nil
+	}
+
+	// Relocations are read from the realigned copy, then undone on the
+	// dump where RVAs are offsets, and the result realigned again.
+	uf, err := pe.NewFile(bytes.NewReader(u.Data))
+	if err != nil {
+		return nil, fmt.Errorf("open unmapped pe: %w", err)
+	}
+	rel := parseRelocs(uf, newAddrSpace(uf), u.Data)
+	if rel.Total == 0 {
+		return nil, fmt.Errorf("image has no base relocations; ImageBase cannot be restored")
+	}
+	target := u.ImageBase
+	if opts.Base != 0 {
+		target = opts.Base
+	}
+	load := opts.LoadBase
+	if load == 0 {
+		var ok bool
+		load, ok = inferLoadBase(f, as, bin, rel, sizeOfImage, u.ImageBase)
+		if !ok {
+			return nil, fmt.Errorf("relocated pointers do not fit a single load base; pass -loadbase")
+		}
+		if load != u.ImageBase {
+			u.Notes = append(u.Notes, fmt.Sprintf("load base 0x%X is a best guess from pointer targets; pass -loadbase if the output looks wrong", load))
+		}
+	}
+	u.LoadBase = load
+	if load == target {
+		u.Notes = append(u.Notes, fmt.Sprintf("pointers already match ImageBase 0x%X; pass -base with the original base to rebase", target))
+		return u, nil
+	}
+	work := append([]byte(nil), bin...)
+	var notes []string
+	u.Applied, notes = applyRelocs(work, rel, target-load)
+	u.Notes = append(u.Notes, notes...)
+	u.Data = unmapLayout(f, work)
+	setImageBase(u.Data, target, is64)
+	u.ImageBase = target
+	return u, nil
+}
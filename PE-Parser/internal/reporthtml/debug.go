@@ -0,0 +1,86 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeDebug(sb *strings.Builder, r *peparse.Report) {
+	d := r.Debug
+	sb.WriteString(`<section id="debug" class="card"><h2>Debug Directory</h2><div class="content">`)
+	if d.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(d.Note) + `</p>`)
+	}
+	if r.Symbols.Note != "" {
+		sb.WriteString(`<p class="note">Symbols: ` + html.EscapeString(r.Symbols.Note) + `</p>`)
+	}
+	if len(d.Entries) == 0 {
+		sb.WriteString(`<p class="badge">No debug directory</p></div></section>`)
+		return
+	}
+	if cv := d.CodeView(); cv != nil {
+		sb.WriteString(`<div class="kv">`)
+		sb.WriteString(`<div>PDB path</div><div><code>` + html.EscapeString(cv.PDBPath) + `</code></div>`)
+		if cv.User != "" {
+			sb.WriteString(`<div>User</div><div><span class="badge">` + html.EscapeString(cv.User) + `</span></div>`)
+		}
+		if cv.GUID != "" {
+			sb.WriteString(`<div>GUID</div><div><code>` + cv.GUID + `</code></div>`)
+		} else {
+			sb.WriteString(fmt.Sprintf(`<div>Signature</div><div><code>0x%08X</code></div>`, cv.Signature))
+		}
+		sb.WriteString(fmt.Sprintf(`<div>Age</div><div>%d</div>`, cv.Age))
+		sb.WriteString(`<div>Symbol server key</div><div><code>` + html.EscapeString(cv.SymbolKey) + `</code></div>`)
+		if sr := r.Symbols; sr.PDBPath != "" {
+			sb.WriteString(fmt.Sprintf(`<div>Symbols</div><div>%d loaded from <code>%s</code></div>`, len(sr.Symbols), html.EscapeString(sr.PDBPath)))
+		}
+		sb.WriteString(`</div>`)
+	}
+
+	sb.WriteString(`<table><thead><tr><th>Type</th><th>Size</th><th>RVA</th><th>Raw</th><th>Details</th></tr></thead><tbody>`)
+	for _, e := range d.Entries {
+		sb.WriteString(fmt.Sprintf(`<tr><td>%s</td><td>%d</td><td><code>0x%08X</code></td><td><code>0x%08X</code></td><td>%s</td></tr>`,
+			html.EscapeString(e.TypeName), e.SizeOfData, e.AddressOfRawData, e.PointerToRawData, debugDetails(e)))
+	}
+	sb.WriteString(`</tbody></table></div></section>`)
+}
+
+func debugDetails(e peparse.DebugEntry) string {
+	var parts []string
+	if cv := e.CodeView; cv != nil {
+		s := cv.Format + ` <code>` + html.EscapeString(cv.PDBPath) + `</code>`
+		if cv.PortablePDB {
+			s += ` <span class="badge">portable PDB</span>`
+		}
+		parts = append(parts, s)
+	}
+	if len(e.POGO) > 0 {
+		names := make([]string, len(e.POGO))
+		for i, p := range e.POGO {
+			names[i] = html.EscapeString(p.Name)
+		}
+		parts = append(parts, html.EscapeString(e.POGOSignature)+`: <code>`+strings.Join(names, " ")+`</code>`)
+	}
+	if v := e.VCFeature; v != nil {
+		parts = append(parts, fmt.Sprintf("Pre-VC++ 11.00: %d, C/C++: %d, /GS: %d, /sdl: %d, guardN: %d", v.PreVC11, v.CCpp, v.GS, v.SDL, v.GuardN))
+	}
+	if e.ReproHash != "" {
+		parts = append(parts, `<code>`+e.ReproHash+`</code>`)
+	}
+	for _, n := range e.ExDllNames {
+		parts = append(parts, `<span class="badge">`+n+`</span>`)
+	}
+	if len(e.FPO) > 0 {
+		parts = append(parts, fmt.Sprintf("%d FPO records", len(e.FPO)))
+	}
+	if p := e.EmbeddedPDB; p != nil {
+		parts = append(parts, fmt.Sprintf("%d bytes uncompressed", p.UncompressedSize))
+	}
+	if e.Note != "" {
+		parts = append(parts, `<span class="note">`+html.EscapeString(e.Note)+`</span>`)
+	}
+	return strings.Join(parts, "<br>")
+}
@@ -0,0 +1,39 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeDecoded(sb *strings.Builder, r *peparse.Report) {
+	sb.WriteString(`<section id="decoded" class="card"><h2>Decoded Blobs</h2><div class="content">`)
+	if r.Decoded.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(r.Decoded.Note) + `</p>`)
+	}
+	if len(r.Decoded.Items) == 0 {
+		sb.WriteString(`<p class="badge">No encoded blobs decoded</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+	sb.WriteString(`<table><thead><tr><th>#</th><th>Chain</th><th>Source</th><th>Size</th><th>Magic</th><th>Decoded</th></tr></thead><tbody>`)
+	for i, d := range r.Decoded.Items {
+		sb.WriteString(fmt.Sprintf(`<tr><td>%d</td><td><code>%s</code></td><td>%s</td><td>%d</td><td>%s</td><td>`,
+			i+1, html.EscapeString(strings.Join(d.Chain, " → ")), html.EscapeString(d.Source), d.Size, html.EscapeString(d.Magic)))
+		sb.WriteString(`<details><summary><code>` + html.EscapeString(d.Preview) + `</code></summary><div class="content">`)
+		sb.WriteString(`<p>Input <button class="copy" data-copy="` + html.EscapeString(d.Input) + `">Copy</button></p>`)
+		sb.WriteString(`<pre>` + html.EscapeString(d.Input) + `</pre>`)
+		if len(d.Strings) > 0 {
+			sb.WriteString(`<p>Strings</p><pre>`)
+			for _, s := range d.Strings {
+				sb.WriteString(html.EscapeString(s))
+				sb.WriteByte('\n')
+			}
+			sb.WriteString(`</pre>`)
+		}
+		sb.WriteString(`</div></details></td></tr>`)
+	}
+	sb.WriteString(`</tbody></table></div></section>`)
+}
@@ -0,0 +1,57 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeDeps(sb *strings.Builder, r *peparse.Report) {
+	t := r.Deps
+	sb.WriteString(`<section id="deps" class="card"><h2>Dependencies</h2><div class="content">`)
+	if t.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(t.Note) + `</p>`)
+	}
+	sb.WriteString(`<p>Search path: <code>` + html.EscapeString(strings.Join(t.Dirs, "; ")) + `</code></p>`)
+	sb.WriteString(fmt.Sprintf(`<p><span class="badge">%d missing modules</span> <span class="badge">%d missing functions</span></p>`, len(t.MissingModules), t.MissingFuncs))
+	if len(t.MissingModules) > 0 {
+		sb.WriteString(`<p>Missing: <code>` + html.EscapeString(strings.Join(t.MissingModules, ", ")) + `</code></p>`)
+	}
+	sb.WriteString(`<ul class="deptree">`)
+	writeDepNode(sb, t.Root)
+	sb.WriteString(`</ul></div></section>`)
+}
+
+func writeDepNode(sb *strings.Builder, m *peparse.DepModule) {
+	sb.WriteString(`<li><code>` + html.EscapeString(m.Name) + `</code>`)
+	if m.Host != "" {
+		sb.WriteString(` &rarr; <code>` + html.EscapeString(m.Host) + `</code>`)
+	}
+	for _, tag := range []struct {
+		on   bool
+		text string
+	}{{m.Delay, "delay"}, {m.Forward, "forwarded"}, {m.Missing, "missing"}, {m.Repeat, "see above"}} {
+		if tag.on {
+			sb.WriteString(` <span class="badge">` + tag.text + `</span>`)
+		}
+	}
+	if m.Path != "" {
+		sb.WriteString(` <small>` + html.EscapeString(m.Path) + `</small>`)
+	}
+	if m.Note != "" {
+		sb.WriteString(` <span class="note">` + html.EscapeString(m.Note) + `</span>`)
+	}
+	if len(m.MissingFuncs) > 0 {
+		sb.WriteString(`<div class="note">Missing functions: <code>` + html.EscapeString(strings.Join(m.MissingFuncs, ", ")) + `</code></div>`)
+	}
+	if len(m.Children) > 0 {
+		sb.WriteString(`<ul>`)
+		for _, c := range m.Children {
+			writeDepNode(sb, c)
+		}
+		sb.WriteString(`</ul>`)
+	}
+	sb.WriteString(`</li>`)
+}
@@ -0,0 +1,42 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeDisasm(sb *strings.Builder, r *peparse.Report) {
+	sb.WriteString(`<section id="disasm" class="card"><h2>Disassembly</h2><div class="content">`)
+	if r.Disasm.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(r.Disasm.Note) + `</p>`)
+	}
+	if len(r.Disasm.Listings) == 0 {
+		sb.WriteString(`<p class="badge">No listings</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+	for i, l := range r.Disasm.Listings {
+		open := ""
+		if i == 0 {
+			open = " open"
+		}
+		sb.WriteString(fmt.Sprintf(`<details id="disasm-%08X"%s><summary>%s &nbsp; <code>RVA 0x%08X</code> <code>VA 0x%X</code></summary><div class="content">`,
+			l.RVA, open, html.EscapeString(l.Label), l.RVA, l.VA))
+		if l.Note != "" {
+			sb.WriteString(`<p class="note">` + html.EscapeString(l.Note) + `</p>`)
+		}
+		if len(l.Lines) > 0 {
+			sb.WriteString(`<table class="asm"><thead><tr><th>VA</th><th>Bytes</th><th>Instruction</th><th>Comment</th></tr></thead><tbody>`)
+			for _, ln := range l.Lines {
+				sb.WriteString(fmt.Sprintf(`<tr><td>0x%X</td><td>%s</td><td>%s</td><td class="note">%s</td></tr>`,
+					ln.VA, html.EscapeString(ln.Bytes), html.EscapeString(ln.Text), html.EscapeString(ln.Comment)))
+			}
+			sb.WriteString(`</tbody></table>`)
+		}
+		sb.WriteString(`</div></details>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
@@ -0,0 +1,165 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+const maxDotNetRows = 2000
+
+// writeDotNet writes the CLR header, assembly identity, references,
+// P/Invoke declarations, user strings and the type inventory; the full
+// inventory goes to -dotnetjson.
+func writeDotNet(sb *strings.Builder, r *peparse.Report) {
+	d := r.DotNet
+	sb.WriteString(`<section id="dotnet" class="card"><h2>.NET</h2><div class="content">`)
+	if d.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(d.Note) + `</p>`)
+	}
+	if !d.Present {
+		sb.WriteString(`<p class="badge">Not a .NET image</p></div></section>`)
+		return
+	}
+	sb.WriteString(`<div class="kv">`)
+	if a := d.Assembly; a != nil {
+		sb.WriteString(`<div>Assembly</div><div><code>` + html.EscapeString(a.Name) + `</code> ` + html.EscapeString(a.Version) + `</div>`)
+		if a.PublicKeyToken != "" {
+			sb.WriteString(`<div>Public key token</div><div><code>` + a.PublicKeyToken + `</code></div>`)
+		}
+	}
+	if d.Module != "" {
+		sb.WriteString(`<div>Module</div><div><code>` + html.EscapeString(d.Module) + `</code> MVID <code>` + d.MVID + `</code></div>`)
+	}
+	sb.WriteString(`<div>Runtime</div><div>CLR header ` + html.EscapeString(d.RuntimeVersion) + `, metadata <code>` + html.EscapeString(d.MetadataVersion) + `</code></div>`)
+	if d.TargetFramework != "" {
+		sb.WriteString(`<div>Target framework</div><div><code>` + html.EscapeString(d.TargetFramework) + `</code></div>`)
+	}
+	if len(d.Flags) > 0 {
+		var badges []string
+		for _, fl := range d.Flags {
+			badges = append(badges, `<span class="badge">`+fl+`</span>`)
+		}
+		sb.WriteString(`<div>Flags</div><div>` + strings.Join(badges, " ") + `</div>`)
+	}
+	if d.EntryPoint != "" {
+		sb.WriteString(`<div>Entry point</div><div><code>` + html.EscapeString(d.EntryPoint) + `</code></div>`)
+	}
+	if d.TypeRefHash != "" {
+		sb.WriteString(`<div>TypeRef hash</div><div><code>` + d.TypeRefHash + `</code> <button class="copy" data-copy="` + d.TypeRefHash + `">Copy</button></div>`)
+	}
+	var streams []string
+	for _, s := range d.Streams {
+		streams = append(streams, fmt.Sprintf(`<code>%s</code> %d`, html.EscapeString(s.Name), s.Size))
+	}
+	sb.WriteString(`<div>Streams</div><div>` + strings.Join(streams, " &nbsp; ") + `</div>`)
+	sb.WriteString(fmt.Sprintf(`<div>Inventory</div><div>%d types, %d methods, %d type refs, %d member refs</div>`,
+		len(d.Types), d.Methods, len(d.TypeRefs), len(d.MemberRefs)))
+	sb.WriteString(`</div>`)
+	writeAnomalies(sb, d.Anomalies)
+	for _, o := range d.Obfuscators {
+		sb.WriteString(`<p><span class="badge">` + html.EscapeString(o.Name) + `</span></p><ul>`)
+		for _, e := range o.Evidence {
+			sb.WriteString(`<li>` + html.EscapeString(e) + `</li>`)
+		}
+		sb.WriteString(`</ul>`)
+	}
+
+	if len(d.References) > 0 {
+		sb.WriteString(`<h3>Referenced assemblies</h3><table><thead><tr><th>Name</th><th>Version</th><th>Public key token</th></tr></thead><tbody>`)
+		for _, a := range d.References {
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td><code>%s</code></td></tr>`,
+				html.EscapeString(a.Name), html.EscapeString(a.Version), a.PublicKeyToken))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+	if len(d.Resources) > 0 {
+		sb.WriteString(`<h3>Manifest resources</h3><table><thead><tr><th>Name</th><th>Source</th><th>Visibility</th><th>Size</th></tr></thead><tbody>`)
+		for _, res := range d.Resources {
+			vis := "private"
+			if res.Public {
+				vis = "public"
+			}
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td>%d</td></tr>`,
+				html.EscapeString(res.Name), html.EscapeString(res.Source), vis, res.Size))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+
+	if len(d.PInvokes) > 0 {
+		sb.WriteString(`<h3>P/Invoke</h3><table><thead><tr><th>DLL</th><th>Entry point</th><th>Method</th><th>Flags</th></tr></thead><tbody>`)
+		for _, p := range d.PInvokes {
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td><code>%s</code></td><td><code>%s</code></td><td>%s</td></tr>`,
+				html.EscapeString(p.DLL), html.EscapeString(p.EntryPoint), html.EscapeString(p.Method), html.EscapeString(strings.Join(p.Flags, " "))))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+	if len(d.UserStrings) > 0 {
+		sb.WriteString(fmt.Sprintf(`<details><summary>User strings (#US, %d)</summary><div class="content"><pre>`, len(d.UserStrings)))
+		for _, u := range d.UserStrings {
+			sb.WriteString(html.EscapeString(fmt.Sprintf("%q", u)) + "\n")
+		}
+		sb.WriteString(`</pre></div></details>`)
+	}
+	if d.RankNote != "" || len(d.Ranked) > 0 {
+		sb.WriteString(`<details open><summary>Ranked user strings (StringSifter)</summary><div class="content">`)
+		if d.RankNote != "" {
+			sb.WriteString(`<p class="note">` + html.EscapeString(d.RankNote) + `</p>`)
+		}
+		if len(d.Ranked) > 0 {
+			sb.WriteString(`<table><thead><tr><th>#</th><th>Score</th><th>String</th></tr></thead><tbody>`)
+			for i, rnk := range d.Ranked {
+				score := "—"
+				if rnk.Score != nil {
+					score = fmt.Sprintf("%.6f", *rnk.Score)
+				}
+				sb.WriteString(fmt.Sprintf(`<tr><td>%d</td><td><code>%s</code></td><td><code>%s</code></td></tr>`, i+1, score, html.EscapeString(rnk.Text)))
+			}
+			sb.WriteString(`</tbody></table>`)
+		}
+		sb.WriteString(`</div></details>`)
+	}
+
+	types := d.Types
+	sb.WriteString(fmt.Sprintf(`<details><summary>Types and methods (%d)</summary><div class="content">`, len(types)))
+	if len(types) > maxDotNetRows {
+		sb.WriteString(fmt.Sprintf(`<p class="note">Showing the first %d types.</p>`, maxDotNetRows))
+		types = types[:maxDotNetRows]
+	}
+	sb.WriteString(`<table><thead><tr><th>Type</th><th>Visibility</th><th>Extends</th><th>Methods</th></tr></thead><tbody>`)
+	for _, t := range types {
+		var methods []string
+		for _, m := range t.Methods {
+			s := html.EscapeString(m.Name)
+			if m.RVA != 0 {
+				s += fmt.Sprintf(` <small>0x%08X</small>`, m.RVA)
+			}
+			methods = append(methods, `<code>`+s+`</code>`)
+		}
+		name := html.EscapeString(t.Name)
+		if len(t.Flags) > 0 {
+			name += ` <small>` + strings.Join(t.Flags, " ") + `</small>`
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td><code>%s</code></td><td>%s</td></tr>`,
+			name, t.Visibility, html.EscapeString(t.Extends), strings.Join(methods, "<br>")))
+	}
+	sb.WriteString(`</tbody></table></div></details>`)
+
+	if len(d.TypeRefs) > 0 {
+		sb.WriteString(fmt.Sprintf(`<details><summary>Type references (%d)</summary><div class="content"><ul>`, len(d.TypeRefs)))
+		for _, t := range d.TypeRefs {
+			sb.WriteString(`<li><code>` + html.EscapeString(t) + `</code></li>`)
+		}
+		sb.WriteString(`</ul></div></details>`)
+	}
+	if len(d.MemberRefs) > 0 {
+		sb.WriteString(fmt.Sprintf(`<details><summary>Member references (%d)</summary><div class="content"><ul>`, len(d.MemberRefs)))
+		for _, m := range d.MemberRefs {
+			sb.WriteString(`<li><code>` + html.EscapeString(m.Parent+"::"+m.Name) + `</code></li>`)
+		}
+		sb.WriteString(`</ul></div></details>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
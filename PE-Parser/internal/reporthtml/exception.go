@@ -0,0 +1,65 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+const maxUnwindRows = 2000
+
+// writeException writes the handler summary and the function table; the
+// unwind codes of every entry go to -pdatajson.
+func writeException(sb *strings.Builder, r *peparse.Report) {
+	ex := r.Exception
+	sb.WriteString(`<section id="exception" class="card"><h2>Exception Directory</h2><div class="content">`)
+	if ex.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(ex.Note) + `</p>`)
+	}
+	if len(ex.Functions) == 0 {
+		sb.WriteString(`<p class="badge">No unwind data</p></div></section>`)
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<p>%s: %d functions, %d chained (directory RVA <code>0x%08X</code>, %d bytes)</p>`,
+		html.EscapeString(ex.Machine), len(ex.Functions), ex.Chained, ex.DirRVA, ex.DirSize))
+	writeAnomalies(sb, ex.Anomalies)
+	if len(ex.Handlers) > 0 {
+		sb.WriteString(`<table><thead><tr><th>Handler</th><th>Name</th><th>Functions</th></tr></thead><tbody>`)
+		for _, h := range ex.Handlers {
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>0x%08X</code></td><td><code>%s</code></td><td>%d</td></tr>`,
+				h.RVA, html.EscapeString(h.Name), h.Functions))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+
+	fns := ex.Functions
+	sb.WriteString(fmt.Sprintf(`<details><summary>Functions (%d)</summary><div class="content">`, len(fns)))
+	if len(fns) > maxUnwindRows {
+		sb.WriteString(fmt.Sprintf(`<p class="note">Showing the first %d entries.</p>`, maxUnwindRows))
+		fns = fns[:maxUnwindRows]
+	}
+	sb.WriteString(`<table><thead><tr><th>Start</th><th>End</th><th>Prolog</th><th>Frame</th><th>Stack</th><th>Saved</th><th>Flags</th><th>Handler</th></tr></thead><tbody>`)
+	for _, fn := range fns {
+		frame := fn.FrameReg
+		if frame != "" && fn.FrameOffset != 0 {
+			frame += fmt.Sprintf("+0x%X", fn.FrameOffset)
+		}
+		flags := strings.Join(fn.Flags, " ")
+		if fn.ChainedTo != 0 {
+			flags += fmt.Sprintf(" &rarr; 0x%08X", fn.ChainedTo)
+		}
+		handler := ""
+		if fn.HandlerRVA != 0 {
+			handler = fmt.Sprintf(`<code>0x%08X</code> %s`, fn.HandlerRVA, html.EscapeString(fn.Handler))
+			if len(fn.Scopes) > 0 {
+				handler += fmt.Sprintf(` (%d scopes)`, len(fn.Scopes))
+			}
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>0x%08X</code></td><td><code>0x%08X</code></td><td>%d</td><td><code>%s</code></td><td>0x%X</td><td>%s</td><td>%s</td><td>%s</td></tr>`,
+			fn.Begin, fn.End, fn.PrologSize, html.EscapeString(frame), fn.StackSize,
+			html.EscapeString(strings.Join(fn.SavedRegs, " ")), flags, handler))
+	}
+	sb.WriteString(`</tbody></table></div></details></div></section>`)
+}
@@ -0,0 +1,50 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+// writeAnomalies lists anomalies under an "Anomalies" heading.
+func writeAnomalies(sb *strings.Builder, anomalies []string) {
+	if len(anomalies) == 0 {
+		return
+	}
+	sb.WriteString(`<h3>Anomalies</h3><ul>`)
+	for _, a := range anomalies {
+		sb.WriteString(`<li>` + html.EscapeString(a) + `</li>`)
+	}
+	sb.WriteString(`</ul>`)
+}
+
+func writeExportTable(sb *strings.Builder, syms []peparse.ExportSymbol) {
+	fwd, ordOnly := 0, 0
+	for _, s := range syms {
+		if s.Forwarder != "" {
+			fwd++
+		}
+		if s.OrdinalOnly {
+			ordOnly++
+		}
+	}
+	if fwd > 0 || ordOnly > 0 {
+		sb.WriteString(fmt.Sprintf(`<p>%d forwarded, %d exported by ordinal only</p>`, fwd, ordOnly))
+	}
+	sb.WriteString(`<table><thead><tr><th>#</th><th>Ordinal</th><th>Name</th><th>RVA</th><th>Section / Forwarder</th></tr></thead><tbody>`)
+	for i, s := range syms {
+		name := `<code>` + html.EscapeString(s.Name) + `</code>`
+		if s.OrdinalOnly {
+			name = `<span class="badge">ordinal only</span>`
+		}
+		target := html.EscapeString(s.Section)
+		if s.Forwarder != "" {
+			target = `&rarr; <code>` + html.EscapeString(s.Forwarder) + `</code>`
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td>%d</td><td><code>%d</code></td><td>%s</td><td><code>0x%08X</code></td><td>%s</td></tr>`,
+			i+1, s.Ordinal, name, s.RVA, target))
+	}
+	sb.WriteString(`</tbody></table>`)
+}
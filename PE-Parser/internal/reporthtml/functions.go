@@ -0,0 +1,74 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+const maxFunctionRows = 2000
+
+func writeFunctions(sb *strings.Builder, r *peparse.Report) {
+	g := r.Functions
+	sb.WriteString(`<section id="functions" class="card"><h2>Functions &amp; Call Graph</h2><div class="content">`)
+	if g.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(g.Note) + `</p>`)
+	}
+	if len(g.Functions) == 0 {
+		sb.WriteString(`<p class="badge">No functions discovered</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<p class="badge">%d functions</p>`, len(g.Functions)))
+
+	if uses := g.APICallers(); len(uses) > 0 {
+		sb.WriteString(`<details open><summary>Imported API &rarr; calling functions</summary><div class="content"><table><thead><tr><th>API</th><th>Callers</th></tr></thead><tbody>`)
+		for _, u := range uses {
+			links := make([]string, 0, len(u.Callers))
+			for _, rva := range u.Callers {
+				if fn, ok := g.Lookup(rva); ok {
+					links = append(links, funcLink(g, fn))
+				}
+			}
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td></tr>`, html.EscapeString(u.API), strings.Join(links, ", ")))
+		}
+		sb.WriteString(`</tbody></table></div></details>`)
+	}
+
+	sb.WriteString(`<details><summary>All functions</summary><div class="content">`)
+	rows := g.Functions
+	if len(rows) > maxFunctionRows {
+		sb.WriteString(fmt.Sprintf(`<p class="note">Showing the first %d functions; use -graphjson for the full list.</p>`, maxFunctionRows))
+		rows = rows[:maxFunctionRows]
+	}
+	sb.WriteString(`<table><thead><tr><th>Function</th><th>RVA</th><th>Size</th><th>Found via</th><th>Calls</th><th>APIs</th><th>Strings</th></tr></thead><tbody>`)
+	for _, fn := range rows {
+		callees := make([]string, 0, len(fn.Callees))
+		for _, rva := range fn.Callees {
+			if c, ok := g.Lookup(rva); ok {
+				callees = append(callees, funcLink(g, c))
+			}
+		}
+		strs := make([]string, 0, len(fn.Strings))
+		for _, s := range fn.Strings {
+			strs = append(strs, `<code>`+html.EscapeString(s)+`</code>`)
+		}
+		sb.WriteString(fmt.Sprintf(`<tr id="fn-%08X"><td><code>%s</code></td><td>0x%08X</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>`,
+			fn.RVA, html.EscapeString(fn.Name), fn.RVA, fn.Size, html.EscapeString(strings.Join(fn.Seeds, ", ")),
+			strings.Join(callees, ", "), html.EscapeString(strings.Join(fn.APIs, ", ")), strings.Join(strs, "<br>")))
+	}
+	sb.WriteString(`</tbody></table></div></details>`)
+	sb.WriteString(`</div></section>`)
+}
+
+// funcLink links to the row of fn in the function table, or names it in
+// plain text when the table was cut off before it. Functions are sorted by
+// RVA, so the rendered rows are the lowest ones.
+func funcLink(g peparse.FunctionReport, fn peparse.Function) string {
+	if len(g.Functions) > maxFunctionRows && fn.RVA > g.Functions[maxFunctionRows-1].RVA {
+		return html.EscapeString(fn.Name)
+	}
+	return fmt.Sprintf(`<a href="#fn-%08X">%s</a>`, fn.RVA, html.EscapeString(fn.Name))
+}
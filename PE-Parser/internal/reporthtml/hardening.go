@@ -0,0 +1,28 @@
+package reporthtml
+
+import (
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeHardening(sb *strings.Builder, r *peparse.Report) {
+	h := r.Hardening
+	sb.WriteString(`<section id="hardening" class="card"><h2>Hardening</h2><div class="content">`)
+	if h.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(h.Note) + `</p>`)
+	}
+	if len(h.Checks) > 0 {
+		sb.WriteString(`<table><thead><tr><th>Check</th><th>Outcome</th><th>Detail</th></tr></thead><tbody>`)
+		for _, c := range h.Checks {
+			outcome := c.Outcome
+			if c.Outcome == peparse.HardenFail {
+				outcome = `<strong>` + outcome + `</strong>`
+			}
+			sb.WriteString(`<tr><td>` + c.Name + `</td><td><span class="badge">` + outcome + `</span></td><td>` + html.EscapeString(c.Detail) + `</td></tr>`)
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
@@ -0,0 +1,57 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeHijack(sb *strings.Builder, r *peparse.Report) {
+	h := r.Hijack
+	sb.WriteString(`<section id="hijack" class="card"><h2>DLL Hijacking &amp; Side-Loading</h2><div class="content">`)
+	if h.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(h.Note) + `</p>`)
+	}
+	sb.WriteString(`<p>KnownDLLs: <code>` + html.EscapeString(h.KnownDLLs) + `</code></p>`)
+	if len(h.Candidates) == 0 {
+		sb.WriteString(`<p class="badge">No imported DLLs outside KnownDLLs</p>`)
+	} else {
+		sb.WriteString(`<p>These imports are not KnownDLLs or API sets, so the loader looks for them in the application directory first.</p>`)
+		sb.WriteString(`<table><thead><tr><th>DLL</th><th>Import</th><th>Application directory</th></tr></thead><tbody>`)
+		for _, c := range h.Candidates {
+			kind := "static"
+			if c.Delay {
+				kind = "delay"
+			}
+			present := ""
+			if c.InAppDir {
+				present = `<span class="badge">present</span>`
+			}
+			sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td>%s</td></tr>`, html.EscapeString(c.DLL), kind, present))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+
+	if sl := h.SideLoad; sl != nil {
+		sb.WriteString(`<h3>Export comparison</h3><div class="kv">`)
+		sb.WriteString(`<div>Reference</div><div><code>` + html.EscapeString(sl.Reference) + `</code></div>`)
+		sb.WriteString(`<div>Verdict</div><div><strong>` + html.EscapeString(sl.Verdict) + `</strong></div>`)
+		sb.WriteString(fmt.Sprintf(`<div>Exports</div><div>%d (reference %d)</div>`, sl.Exports, sl.RefExports))
+		sb.WriteString(fmt.Sprintf(`<div>Forwarded</div><div>%d %s</div>`, sl.Forwarded, html.EscapeString(strings.Join(sl.ForwardTargets, ", "))))
+		sb.WriteString(`</div>`)
+		for _, l := range []struct {
+			title string
+			names []string
+		}{{"Missing from this DLL", sl.Missing}, {"Not in the reference", sl.Extra}} {
+			if len(l.names) == 0 {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf(`<div class="details"><details><summary>%s (%d)</summary><div class="content"><pre>`, l.title, len(l.names)))
+			sb.WriteString(html.EscapeString(strings.Join(l.names, "\n")))
+			sb.WriteString(`</pre></div></details></div>`)
+		}
+	}
+	sb.WriteString(`</div></section>`)
+}
@@ -0,0 +1,94 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeDelayImports(sb *strings.Builder, r *peparse.Report) {
+	if len(r.Imports.Delay) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<h3>Delay-loaded imports (%d DLLs)</h3>`, len(r.Imports.Delay)))
+	for _, d := range r.Imports.Delay {
+		sb.WriteString(`<div class="subcard">`)
+		sb.WriteString(`<h3>` + html.EscapeString(d.Name) + hostSuffix(d.Host) + ` <span class="badge">delay</span></h3>`)
+		kind := "RVA-based"
+		if !d.RVABased {
+			kind = "VA-based (legacy)"
+		}
+		sb.WriteString(`<div class="kv">`)
+		sb.WriteString(fmt.Sprintf(`<div>Attributes</div><div>0x%08X %s</div>`, d.Attributes, kind))
+		sb.WriteString(fmt.Sprintf(`<div>Module handle</div><div>0x%08X</div>`, d.ModuleHandleRVA))
+		sb.WriteString(fmt.Sprintf(`<div>IAT</div><div>0x%08X</div>`, d.IATRVA))
+		sb.WriteString(fmt.Sprintf(`<div>INT</div><div>0x%08X</div>`, d.INTRVA))
+		sb.WriteString(fmt.Sprintf(`<div>Bound IAT</div><div>0x%08X</div>`, d.BoundIATRVA))
+		sb.WriteString(fmt.Sprintf(`<div>Unload IAT</div><div>0x%08X</div>`, d.UnloadIATRVA))
+		sb.WriteString(fmt.Sprintf(`<div>TimeDateStamp</div><div>0x%08X</div>`, d.TimeDateStamp))
+		sb.WriteString(`</div>`)
+		if len(d.Functions) == 0 {
+			sb.WriteString(`<p class="badge">No named imports</p>`)
+		} else {
+			writeImportTable(sb, d.Functions, r.Header.Is64)
+		}
+		sb.WriteString(`</div>`)
+	}
+}
+
+// writeImportTable lists INT/IAT entries; the slot VA is what shows up in
+// call [addr] operands in a debugger or disassembler.
+func writeImportTable(sb *strings.Builder, funcs []peparse.ImportFunc, is64 bool) {
+	sb.WriteString(`<table><thead><tr><th>Name</th><th>Hint</th><th>Ordinal</th><th>Thunk</th><th>IAT RVA</th><th>IAT VA</th></tr></thead><tbody>`)
+	for _, fn := range funcs {
+		hint, ord := fmt.Sprintf("%d", fn.Hint), ""
+		if fn.ByOrdinal {
+			hint, ord = "", fmt.Sprintf(`%d <span class="badge">by ordinal</span>`, fn.Ordinal)
+			if fn.Resolved {
+				ord += ` <span class="badge">resolved</span>`
+			}
+		}
+		thunk, va := fmt.Sprintf("0x%08X", fn.Thunk), fmt.Sprintf("0x%08X", fn.IATVA)
+		if is64 {
+			thunk, va = fmt.Sprintf("0x%016X", fn.Thunk), fmt.Sprintf("0x%016X", fn.IATVA)
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td><code>%s</code></td><td><code>0x%08X</code></td><td><code>%s</code></td></tr>`,
+			html.EscapeString(fn.Name), hint, ord, thunk, fn.IATRVA, va))
+	}
+	sb.WriteString(`</tbody></table>`)
+}
+
+// hostSuffix shows the DLL an API-set import resolves to.
+func hostSuffix(host string) string {
+	if host == "" {
+		return ""
+	}
+	return ` &rarr; <code>` + html.EscapeString(host) + `</code> <span class="badge">API set</span>`
+}
+
+func writeBoundImports(sb *strings.Builder, r *peparse.Report) {
+	if len(r.Imports.Bound) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<h3>Bound imports (%d DLLs)</h3>`, len(r.Imports.Bound)))
+	sb.WriteString(`<table><thead><tr><th>DLL</th><th>TimeDateStamp</th><th>Forwarder refs</th></tr></thead><tbody>`)
+	for _, b := range r.Imports.Bound {
+		fwds := make([]string, 0, len(b.Forwarders))
+		for _, fw := range b.Forwarders {
+			fwds = append(fwds, fmt.Sprintf(`<code>%s</code> (%s)`, html.EscapeString(fw.Name), html.EscapeString(stampText(fw.TimeDateStamp))))
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%s</td><td>%s</td></tr>`,
+			html.EscapeString(b.Name), html.EscapeString(stampText(b.TimeDateStamp)), strings.Join(fwds, "<br>")))
+	}
+	sb.WriteString(`</tbody></table>`)
+}
+
+func stampText(ts uint32) string {
+	if ts == 0 || ts == 0xFFFFFFFF {
+		return fmt.Sprintf("0x%08X", ts)
+	}
+	return fmt.Sprintf("0x%08X %s", ts, time.Unix(int64(ts), 0).UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,59 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeIndicators(sb *strings.Builder, r *peparse.Report) {
+	sb.WriteString(`<section id="indicators" class="card"><h2>Indicators</h2><div class="content">`)
+	if r.Indicators.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(r.Indicators.Note) + `</p>`)
+	}
+	if len(r.Indicators.Items) == 0 {
+		sb.WriteString(`<p class="badge">No indicators extracted</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+
+	var all []string
+	var kinds []string
+	byKind := make(map[string][]peparse.Indicator)
+	for _, it := range r.Indicators.Items {
+		if _, ok := byKind[it.Kind]; !ok {
+			kinds = append(kinds, it.Kind)
+		}
+		byKind[it.Kind] = append(byKind[it.Kind], it)
+		all = append(all, it.Defanged)
+	}
+
+	sb.WriteString(fmt.Sprintf(`<p><span class="badge">%d indicators</span> `, len(r.Indicators.Items)))
+	sb.WriteString(`<button class="copy" data-copy="` + html.EscapeString(strings.Join(all, "\n")) + `">Copy all (defanged)</button></p>`)
+
+	for _, kind := range kinds {
+		items := byKind[kind]
+		var vals []string
+		for _, it := range items {
+			vals = append(vals, it.Defanged)
+		}
+		sb.WriteString(`<div class="subcard">`)
+		sb.WriteString(fmt.Sprintf(`<h3>%s <span class="badge">%d</span> `, html.EscapeString(kind), len(items)))
+		sb.WriteString(`<button class="copy" data-copy="` + html.EscapeString(strings.Join(vals, "\n")) + `">Copy</button></h3>`)
+		sb.WriteString(`<table><thead><tr><th>Value (defanged)</th><th>Tags</th><th>Source</th><th></th></tr></thead><tbody>`)
+		for _, it := range items {
+			sb.WriteString(`<tr><td><code>` + html.EscapeString(it.Defanged) + `</code></td>`)
+			sb.WriteString(`<td>`)
+			for _, t := range it.Tags {
+				sb.WriteString(`<span class="badge">` + html.EscapeString(t) + `</span> `)
+			}
+			sb.WriteString(`</td><td>` + html.EscapeString(strings.Join(it.Sources, "; ")) + `</td>`)
+			sb.WriteString(`<td><button class="copy" data-copy="` + html.EscapeString(it.Defanged) + `">Copy</button> `)
+			sb.WriteString(`<button class="copy" data-copy="` + html.EscapeString(it.Value) + `">Copy raw</button></td></tr>`)
+		}
+		sb.WriteString(`</tbody></table></div>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
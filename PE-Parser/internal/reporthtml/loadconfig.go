@@ -0,0 +1,93 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+const maxGuardRows = 2000
+
+func writeLoadConfig(sb *strings.Builder, r *peparse.Report) {
+	lc := r.LoadConfig
+	sb.WriteString(`<section id="loadconfig" class="card"><h2>Load Config</h2><div class="content">`)
+	if lc.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(lc.Note) + `</p>`)
+	}
+	if !lc.Present {
+		sb.WriteString(`<p class="badge">No load config directory</p></div></section>`)
+		return
+	}
+	sb.WriteString(`<div class="kv">`)
+	kv := func(k string, v uint64) {
+		if v != 0 {
+			sb.WriteString(fmt.Sprintf(`<div>%s</div><div><code>0x%X</code></div>`, k, v))
+		}
+	}
+	kv("Directory RVA", uint64(lc.DirRVA))
+	kv("Size", uint64(lc.Size))
+	kv("SecurityCookie", lc.SecurityCookie)
+	kv("SEHandlerTable", lc.SEHandlerTable)
+	kv("SEHandlerCount", lc.SEHandlerCount)
+	kv("GuardCFCheckFunctionPointer", lc.GuardCFCheckFunctionPointer)
+	kv("GuardCFDispatchFunctionPointer", lc.GuardCFDispatchFunctionPointer)
+	kv("GuardCFFunctionTable", lc.GuardCFFunctionTable)
+	kv("GuardCFFunctionCount", lc.GuardCFFunctionCount)
+	kv("GuardLongJumpTargetTable", lc.GuardLongJumpTargetTable)
+	kv("GuardEHContinuationTable", lc.GuardEHContinuationTable)
+	kv("CHPEMetadataPointer", lc.CHPEMetadataPointer)
+	sb.WriteString(fmt.Sprintf(`<div>GuardFlags</div><div><code>0x%08X</code>`, lc.GuardFlags))
+	for _, n := range lc.GuardFlagNames {
+		sb.WriteString(` <span class="badge">` + n + `</span>`)
+	}
+	sb.WriteString(`</div></div>`)
+
+	writeGuardTable(sb, "CFG function targets", lc.GuardCFTargets)
+	writeGuardTable(sb, "Long jump targets", lc.LongJumpTargets)
+	writeGuardTable(sb, "EH continuation targets", lc.EHContinuationTargets)
+	if len(lc.SEHandlers) > 0 {
+		sb.WriteString(fmt.Sprintf(`<details><summary>SafeSEH handlers (%d)</summary><div class="content"><pre>`, len(lc.SEHandlers)))
+		for _, h := range lc.SEHandlers {
+			sb.WriteString(fmt.Sprintf("0x%08X\n", h))
+		}
+		sb.WriteString(`</pre></div></details>`)
+	}
+
+	if d := lc.DynamicRelocs; d != nil {
+		sb.WriteString(fmt.Sprintf(`<h3>Dynamic value relocations</h3><p>RVA <code>0x%08X</code>, version %d, %d bytes</p>`, d.RVA, d.Version, d.Size))
+		if len(d.Entries) > 0 {
+			sb.WriteString(`<table><thead><tr><th>Kind</th><th>Fixup bytes</th></tr></thead><tbody>`)
+			for _, e := range d.Entries {
+				sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%d</td></tr>`, html.EscapeString(e.Kind), e.Size))
+			}
+			sb.WriteString(`</tbody></table>`)
+		}
+	}
+	if v := lc.Volatile; v != nil {
+		sb.WriteString(`<h3>Volatile metadata</h3><div class="kv">`)
+		sb.WriteString(fmt.Sprintf(`<div>VA</div><div><code>0x%X</code></div><div>Version</div><div>%d</div>`, v.VA, v.Version))
+		sb.WriteString(fmt.Sprintf(`<div>Access table</div><div><code>0x%08X</code> (%d bytes)</div>`, v.AccessTableRVA, v.AccessTableSize))
+		sb.WriteString(fmt.Sprintf(`<div>Info range table</div><div><code>0x%08X</code> (%d bytes)</div>`, v.InfoRangeTableRVA, v.InfoRangeTableSize))
+		sb.WriteString(`</div>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
+
+func writeGuardTable(sb *strings.Builder, title string, targets []peparse.GuardTarget) {
+	if len(targets) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<details><summary>%s (%d)</summary><div class="content">`, title, len(targets)))
+	if len(targets) > maxGuardRows {
+		sb.WriteString(fmt.Sprintf(`<p class="note">Showing the first %d entries.</p>`, maxGuardRows))
+		targets = targets[:maxGuardRows]
+	}
+	sb.WriteString(`<table><thead><tr><th>RVA</th><th>Flags</th><th>Name</th></tr></thead><tbody>`)
+	for _, t := range targets {
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>0x%08X</code></td><td><code>0x%02X</code></td><td><code>%s</code></td></tr>`,
+			t.RVA, t.Flags, html.EscapeString(t.Name)))
+	}
+	sb.WriteString(`</tbody></table></div></details>`)
+}
@@ -0,0 +1,42 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+// writeRelocs writes a per-section summary; the full listing goes to
+// -relocjson.
+func writeRelocs(sb *strings.Builder, r *peparse.Report) {
+	rel := r.Relocs
+	sb.WriteString(`<section id="relocs" class="card"><h2>Base Relocations</h2><div class="content">`)
+	if rel.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(rel.Note) + `</p>`)
+	}
+	if rel.Total == 0 {
+		sb.WriteString(`<p class="badge">No base relocations</p></div></section>`)
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<p>%d relocations in %d blocks (directory RVA <code>0x%08X</code>, %d bytes)</p>`,
+		rel.Total, len(rel.Blocks), rel.DirRVA, rel.DirSize))
+	writeAnomalies(sb, rel.Anomalies)
+	sb.WriteString(`<table><thead><tr><th>Section</th><th>Total</th><th>Types</th></tr></thead><tbody>`)
+	for _, sc := range rel.PerSection {
+		names := make([]string, 0, len(sc.Types))
+		for n := range sc.Types {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		var parts []string
+		for _, n := range names {
+			parts = append(parts, fmt.Sprintf(`<span class="badge">%s</span> %d`, html.EscapeString(n), sc.Types[n]))
+		}
+		sb.WriteString(fmt.Sprintf(`<tr><td><code>%s</code></td><td>%d</td><td>%s</td></tr>`,
+			html.EscapeString(sc.Section), sc.Total, strings.Join(parts, " &nbsp; ")))
+	}
+	sb.WriteString(`</tbody></table></div></section>`)
+}
@@ -49,6 +49,10 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 		sb.WriteString(fmt.Sprintf(`<div>EntryPoint VA</div><div>0x%08X</div>`, uint32(r.Header.EntryPointVA)))
 	}
 	sb.WriteString(fmt.Sprintf(`<div>SizeOfImage</div><div>0x%X bytes</div>`, r.Header.SizeOfImage))
+	if r.Header.Layout.Mapped {
+		sb.WriteString(`<div>Layout</div><div><span class="badge">Mapped</span> memory dump, realigned to file layout for parsing: ` +
+			html.EscapeString(strings.Join(r.Header.Layout.Evidence, "; ")) + `</div>`)
+	}
 	sb.WriteString(`</div>`)
 	println("String Sifter:", p.UseSifter, "limit:", p.RankLimit, "min:", p.RankMin)
 	if p.UseSifter {
@@ -59,12 +63,36 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 	sb.WriteString(`</section>`)
 
 	sb.WriteString(`<section class="card"><h2>Contents</h2><div class="content toc"><ul>`)
+	sb.WriteString(`<li><a href="#indicators">Indicators</a></li>`)
+	sb.WriteString(`<li><a href="#decoded">Decoded Blobs</a></li>`)
+	sb.WriteString(`<li><a href="#stackstrings">Stack Strings</a></li>`)
+	sb.WriteString(`<li><a href="#disasm">Disassembly</a></li>`)
+	sb.WriteString(`<li><a href="#functions">Functions &amp; Call Graph</a></li>`)
+	sb.WriteString(`<li><a href="#xrefs">String References</a></li>`)
 	sb.WriteString(`<li><a href="#sec-summary">Sections Summary</a></li>`)
 	sb.WriteString(`<li><a href="#imports">Imports</a></li>`)
+	if r.Deps != nil {
+		sb.WriteString(`<li><a href="#deps">Dependencies</a></li>`)
+	}
+	sb.WriteString(`<li><a href="#hijack">DLL Hijacking</a></li>`)
 	sb.WriteString(`<li><a href="#exports">Exports</a></li>`)
+	sb.WriteString(`<li><a href="#tls">TLS</a></li>`)
+	sb.WriteString(`<li><a href="#loadconfig">Load Config</a></li>`)
+	sb.WriteString(`<li><a href="#debug">Debug Directory</a></li>`)
+	sb.WriteString(`<li><a href="#relocs">Base Relocations</a></li>`)
+	sb.WriteString(`<li><a href="#exception">Exception Directory</a></li>`)
+	sb.WriteString(`<li><a href="#hardening">Hardening</a></li>`)
 	sb.WriteString(`<li><a href="#resources">Resources</a></li>`)
+	sb.WriteString(`<li><a href="#dotnet">.NET</a></li>`)
 	sb.WriteString(`</ul></div></section>`)
 
+	writeIndicators(&sb, r)
+	writeDecoded(&sb, r)
+	writeStackStrings(&sb, r)
+	writeDisasm(&sb, r)
+	writeFunctions(&sb, r)
+	writeStringXrefs(&sb, r)
+
 	sb.WriteString(`<section id="sec-summary" class="card"><h2>Sections Summary</h2><div class="content"><table><thead><tr>`)
 	sb.WriteString(`<th>#</th><th>Name</th><th>PtrRaw</th><th>SizeRaw</th><th>VirtualSize</th><th>VirtualAddress (RVA)</th></tr></thead><tbody>`)
 	for _, s := range r.Sections {
@@ -75,6 +103,7 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 	}
 	sb.WriteString(`</tbody></table></div></section>`)
 
+	counts := xrefCounts(r)
 	for _, s := range r.Sections {
 		sb.WriteString(`<section class="card">`)
 		sb.WriteString(fmt.Sprintf(`<h3 id="sec-%02X"><code>#%.2X</code> %s</h3>`, s.Index, s.Index, html.EscapeString(s.Name)))
@@ -105,13 +134,20 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 		} else {
 			sb.WriteString(`<pre>`)
 			for _, line := range s.Strings {
-				sb.WriteString(html.EscapeString(line))
-				sb.WriteByte('\n')
+				writeStringLine(&sb, counts, s.Name, line, false)
 			}
 			sb.WriteString(`</pre>`)
 		}
 		sb.WriteString(`</div></details></div>`)
 
+		if len(s.WideStrings) > 0 {
+			sb.WriteString(`<div class="details"><details><summary>Strings (wide)</summary><div class="content"><pre>`)
+			for _, line := range s.WideStrings {
+				writeStringLine(&sb, counts, s.Name, line, true)
+			}
+			sb.WriteString(`</pre></div></details></div>`)
+		}
+
 		// sb.WriteString(`<div class="details"><details open><summary>Ranked Strings (StringSifter)</summary><div class="content">`)
 		// if s.RankNote != "" {
 		// 	sb.WriteString(`<p class="note">` + html.EscapeString(s.RankNote) + `</p>`)
@@ -143,41 +179,46 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 	} else {
 		for _, d := range r.Imports.DLLs {
 			sb.WriteString(`<div class="subcard">`)
-			sb.WriteString(`<h3>` + html.EscapeString(d.Name) + `</h3>`)
+			sb.WriteString(`<h3>` + html.EscapeString(d.Name) + hostSuffix(d.Host) + `</h3>`)
 			if len(d.Functions) == 0 {
 				sb.WriteString(`<p class="badge">No named imports</p>`)
 			} else {
-				sb.WriteString(`<pre>`)
-				for _, fn := range d.Functions {
-					sb.WriteString(html.EscapeString(fn))
-					sb.WriteByte('\n')
-				}
-				sb.WriteString(`</pre>`)
+				writeImportTable(&sb, d.Functions, r.Header.Is64)
 			}
 			sb.WriteString(`</div>`)
 		}
 	}
+	writeDelayImports(&sb, r)
+	writeBoundImports(&sb, r)
 	sb.WriteString(`</div></section>`)
 
+	if r.Deps != nil {
+		writeDeps(&sb, r)
+	}
+	writeHijack(&sb, r)
+
 	sb.WriteString(`<section id="exports" class="card"><h2>Exports</h2><div class="content">`)
 	if r.Exports.Note != "" {
 		sb.WriteString(`<p class="note">` + html.EscapeString(r.Exports.Note) + `</p>`)
 	}
+	writeAnomalies(&sb, r.Exports.Anomalies)
 	if len(r.Exports.Symbols) == 0 {
 		sb.WriteString(`<p class="badge">No exports</p>`)
 	} else {
 		if r.Exports.DLLName != "" {
 			sb.WriteString(`<p><span class="badge">Module</span> <code>` + html.EscapeString(r.Exports.DLLName) + `</code></p>`)
 		}
-		sb.WriteString(`<table><thead><tr><th>#</th><th>Ordinal</th><th>Name</th><th>RVA</th></tr></thead><tbody>`)
-		for i, s := range r.Exports.Symbols {
-			sb.WriteString(fmt.Sprintf(`<tr><td>%d</td><td><code>%d</code></td><td><code>%s</code></td><td><code>0x%08X</code></td></tr>`,
-				i+1, s.Ordinal, html.EscapeString(s.Name), s.RVA))
-		}
-		sb.WriteString(`</tbody></table>`)
+		writeExportTable(&sb, r.Exports.Symbols)
 	}
 	sb.WriteString(`</div></section>`)
 
+	writeTLS(&sb, r)
+	writeLoadConfig(&sb, r)
+	writeDebug(&sb, r)
+	writeRelocs(&sb, r)
+	writeException(&sb, r)
+	writeHardening(&sb, r)
+
 	sb.WriteString(`<section id="resources" class="card"><h2>Resources</h2><div class="content">`)
 	if r.Resources.Note != "" {
 		sb.WriteString(`<p class="note">` + html.EscapeString(r.Resources.Note) + `</p>`)
@@ -193,8 +234,11 @@ func WriteHTML(outPath, inputPath string, r *peparse.Report, p Params) error {
 		sb.WriteString(`</tbody></table>`)
 	}
 	sb.WriteString(`</div></section>`)
+	writeDotNet(&sb, r)
 
-	sb.WriteString(`</main></body></html>`)
+	sb.WriteString(`</main>`)
+	sb.WriteString(script())
+	sb.WriteString(`</body></html>`)
 	_, err = f.WriteString(sb.String())
 	return err
 }
@@ -206,6 +250,20 @@ func effLimit(n int) int {
 	return n
 }
 
+func script() string {
+	return `<script>
+document.addEventListener('click',function(e){
+var b=e.target.closest('button[data-copy]');if(!b)return;
+navigator.clipboard.writeText(b.getAttribute('data-copy')).then(function(){var t=b.textContent;b.textContent='Copied';setTimeout(function(){b.textContent=t},1200)});
+});
+window.addEventListener('hashchange',function(){
+var el=document.getElementById(location.hash.slice(1));if(!el)return;
+for(var d=el.closest('details');d;d=d.parentElement.closest('details'))d.open=true;
+el.scrollIntoView();
+});
+</script>`
+}
+
 func styles() string {
 	return `<style>
 :root{--bg:#0b1020;--panel:#111832;--fg:#e8eef9;--muted:#a9b4cf;--acc:#7aa2f7;}
@@ -234,5 +292,9 @@ details > summary{cursor:pointer;padding:10px 12px;user-select:none}
 .toc a:hover{text-decoration:underline}
 .subcard{border:1px dashed #2a3a7a;border-radius:8px;margin:10px 0;padding:10px}
 .note{color:#f5d67c}
+button.copy{background:#0c1530;color:var(--acc);border:1px solid #2b3b7a;border-radius:6px;padding:2px 8px;font:inherit;font-size:12px;cursor:pointer}
+button.copy:hover{background:#16214a}
+table.asm th,table.asm td{padding:2px 10px;border:none;white-space:pre}
+ul.deptree,ul.deptree ul{list-style:none;margin:0;padding-left:18px;border-left:1px dotted #888}
 </style>`
 }
@@ -0,0 +1,38 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeStackStrings(sb *strings.Builder, r *peparse.Report) {
+	sb.WriteString(`<section id="stackstrings" class="card"><h2>Stack Strings</h2><div class="content">`)
+	n := 0
+	for _, s := range r.Sections {
+		n += len(s.StackStrings)
+	}
+	if n == 0 {
+		sb.WriteString(`<p class="badge">No stack strings recovered</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+	sb.WriteString(`<table><thead><tr><th>Section</th><th>Instruction RVA</th><th>Instruction VA</th><th>Function RVA</th><th>Encoding</th><th>String</th></tr></thead><tbody>`)
+	for _, s := range r.Sections {
+		for _, ss := range s.StackStrings {
+			enc := "ascii"
+			if ss.Wide {
+				enc = "wide"
+			}
+			fn := "—"
+			if ss.FuncRVA != 0 {
+				fn = fmt.Sprintf("0x%08X", ss.FuncRVA)
+			}
+			sb.WriteString(fmt.Sprintf(`<tr><td><a href="#sec-%02X"><code>%s</code></a></td><td><code>0x%08X</code></td><td><code>0x%X</code></td><td><code>%s</code></td><td>%s</td><td><code>%s</code></td></tr>`,
+				s.Index, html.EscapeString(s.Name), ss.RVA, ss.VA, fn, enc, html.EscapeString(ss.Text)))
+		}
+	}
+	sb.WriteString(`</tbody></table></div></section>`)
+}
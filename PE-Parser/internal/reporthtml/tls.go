@@ -0,0 +1,53 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+func writeTLS(sb *strings.Builder, r *peparse.Report) {
+	t := r.TLS
+	sb.WriteString(`<section id="tls" class="card"><h2>TLS</h2><div class="content">`)
+	if t.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(t.Note) + `</p>`)
+	}
+	if !t.Present {
+		sb.WriteString(`<p class="badge">No TLS directory</p></div></section>`)
+		return
+	}
+	if t.Finding != "" {
+		sb.WriteString(`<p><span class="badge">High priority</span> <strong>` + html.EscapeString(t.Finding) + `</strong></p>`)
+	}
+	sb.WriteString(`<div class="kv">`)
+	kv := func(k string, v uint64) {
+		sb.WriteString(fmt.Sprintf(`<div>%s</div><div><code>0x%X</code></div>`, k, v))
+	}
+	kv("Directory RVA", uint64(t.DirRVA))
+	kv("StartAddressOfRawData", t.StartAddressOfRawData)
+	kv("EndAddressOfRawData", t.EndAddressOfRawData)
+	kv("AddressOfIndex", t.AddressOfIndex)
+	kv("AddressOfCallBacks", t.AddressOfCallBacks)
+	kv("SizeOfZeroFill", uint64(t.SizeOfZeroFill))
+	kv("Characteristics", uint64(t.Characteristics))
+	sb.WriteString(`</div>`)
+
+	if len(t.Callbacks) > 0 {
+		sb.WriteString(`<table><thead><tr><th>#</th><th>VA</th><th>RVA</th><th>Section</th></tr></thead><tbody>`)
+		for i, cb := range t.Callbacks {
+			sec := html.EscapeString(cb.Section)
+			switch {
+			case cb.Section == "":
+				sec = `<span class="badge">outside image</span>`
+			case !cb.Executable:
+				sec += ` <span class="badge">not executable</span>`
+			}
+			sb.WriteString(fmt.Sprintf(`<tr><td>%d</td><td><code>0x%X</code></td><td><code>0x%08X</code></td><td>%s</td></tr>`,
+				i, cb.VA, cb.RVA, sec))
+		}
+		sb.WriteString(`</tbody></table>`)
+	}
+	sb.WriteString(`</div></section>`)
+}
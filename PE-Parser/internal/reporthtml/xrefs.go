@@ -0,0 +1,87 @@
+package reporthtml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"PE-Parser/internal/peparse"
+)
+
+const maxXrefRows = 5000
+
+func writeStringXrefs(sb *strings.Builder, r *peparse.Report) {
+	x := r.StringXrefs
+	sb.WriteString(`<section id="xrefs" class="card"><h2>String References</h2><div class="content">`)
+	if x.Note != "" {
+		sb.WriteString(`<p class="note">` + html.EscapeString(x.Note) + `</p>`)
+	}
+	if len(x.Items) == 0 {
+		sb.WriteString(`<p class="badge">No strings referenced from code</p>`)
+		sb.WriteString(`</div></section>`)
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<p><span class="badge">%d referenced</span> <span class="badge">%d unreferenced</span></p>`, len(x.Items), x.Unreferenced))
+	items := x.Items
+	if len(items) > maxXrefRows {
+		sb.WriteString(fmt.Sprintf(`<p class="note">Showing the first %d strings.</p>`, maxXrefRows))
+		items = items[:maxXrefRows]
+	}
+	sb.WriteString(`<table><thead><tr><th>RVA</th><th>Section</th><th>String</th><th>Refs</th><th>Referenced from</th></tr></thead><tbody>`)
+	for _, s := range items {
+		kind := ""
+		if s.Wide {
+			kind = ` <span class="badge">wide</span>`
+		}
+		sites := make([]string, 0, len(s.Sites))
+		for _, site := range s.Sites {
+			name := ""
+			if fn, ok := r.Functions.Lookup(site.FuncRVA); ok {
+				name = " " + funcLink(r.Functions, fn)
+			}
+			sites = append(sites, fmt.Sprintf(`<code>0x%X</code>%s`, site.VA, name))
+		}
+		if s.Count > len(s.Sites) {
+			sites = append(sites, fmt.Sprintf("… %d more", s.Count-len(s.Sites)))
+		}
+		sb.WriteString(fmt.Sprintf(`<tr id="xref-%08X"><td>0x%08X</td><td>%s</td><td><code>%s</code>%s</td><td>%d</td><td>%s</td></tr>`,
+			s.RVA, s.RVA, html.EscapeString(s.Section), html.EscapeString(s.Text), kind, s.Count, strings.Join(sites, "<br>")))
+	}
+	sb.WriteString(`</tbody></table>`)
+	sb.WriteString(`</div></section>`)
+}
+
+type xrefKey struct {
+	section string
+	text    string
+	wide    bool
+}
+
+type xrefCount struct {
+	rva   uint32
+	count int
+}
+
+// xrefCounts sums references per section string so the plain string lists
+// can show them inline.
+func xrefCounts(r *peparse.Report) map[xrefKey]xrefCount {
+	m := make(map[xrefKey]xrefCount)
+	for _, s := range r.StringXrefs.Items {
+		k := xrefKey{s.Section, s.Text, s.Wide}
+		c, ok := m[k]
+		if !ok {
+			c.rva = s.RVA
+		}
+		c.count += s.Count
+		m[k] = c
+	}
+	return m
+}
+
+func writeStringLine(sb *strings.Builder, counts map[xrefKey]xrefCount, section, line string, wide bool) {
+	sb.WriteString(html.EscapeString(line))
+	if c, ok := counts[xrefKey{section, line, wide}]; ok {
+		sb.WriteString(fmt.Sprintf(`  <a class="badge" href="#xref-%08X">referenced by %d locations</a>`, c.rva, c.count))
+	}
+	sb.WriteByte('\n')
+}